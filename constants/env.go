@@ -3,6 +3,8 @@ package constants
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,14 +13,44 @@ type Config struct {
 	Port string
 	Env  string
 
-	DbHost         string
-	DbUser         string
-	DbPassword     string
-	DbName         string
-	DbPort         string
-	SSLMode        string
+	DbHost      string
+	DbUser      string
+	DbPassword  string
+	DbName      string
+	DbPort      string
+	DbURL       string
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+
+	DbMaxConns          int
+	DbMinConns          int
+	DbMaxConnLifetime   time.Duration
+	DbMaxConnIdleTime   time.Duration
+	DbHealthCheckPeriod time.Duration
+
+	Storage        string
+	VectorMetric   string
+	MemorySnapshot string
+
 	OpenAIKey      string
 	EmbeddingModel string
+	ChatModel      string
+	LLMProvider    string
+	LLMBaseURL     string
+	JobWorkers     int
+
+	RetrievalLimit int
+	RRFK           int
+	VectorWeight   float64
+	KeywordWeight  float64
+	SubjectLimit   int
+	MMRLambda      float64
+	ContextTokens  int
+
+	DuplicateThreshold float64
+	UnsupportedAnswers string
 }
 
 func init() {
@@ -38,15 +70,45 @@ func New() *Config {
 		Port: getEnv("PORT", "8000"),
 		Env:  getEnv("ENV", "development"),
 
-		DbHost:     getEnv("POSTGRES_HOST", ""),
-		DbUser:     getEnv("POSTGRES_USER", ""),
-		DbPassword: getEnv("POSTGRES_PASSWORD", ""),
-		DbName:     getEnv("POSTGRES_NAME", ""),
-		DbPort:     getEnv("POSTGRES_PORT", ""),
-		SSLMode:    getEnv("SSL_MODE", "disable"),
+		DbHost:      getEnv("POSTGRES_HOST", ""),
+		DbUser:      getEnv("POSTGRES_USER", ""),
+		DbPassword:  getEnv("POSTGRES_PASSWORD", ""),
+		DbName:      getEnv("POSTGRES_NAME", ""),
+		DbPort:      getEnv("POSTGRES_PORT", ""),
+		DbURL:       getEnv("DATABASE_URL", ""),
+		SSLMode:     getEnv("SSL_MODE", "disable"),
+		SSLRootCert: getEnv("SSL_ROOT_CERT", ""),
+		SSLCert:     getEnv("SSL_CERT", ""),
+		SSLKey:      getEnv("SSL_KEY", ""),
+
+		DbMaxConns:          getEnvInt("POSTGRES_MAX_CONNS", 10),
+		DbMinConns:          getEnvInt("POSTGRES_MIN_CONNS", 0),
+		DbMaxConnLifetime:   getEnvDuration("POSTGRES_MAX_CONN_LIFETIME", 5*time.Minute),
+		DbMaxConnIdleTime:   getEnvDuration("POSTGRES_MAX_CONN_IDLE_TIME", 30*time.Minute),
+		DbHealthCheckPeriod: getEnvDuration("POSTGRES_HEALTH_CHECK_PERIOD", time.Minute),
+
+		Storage:        getEnv("STORAGE", "postgres"),
+		VectorMetric:   getEnv("VECTOR_METRIC", "cosine"),
+		MemorySnapshot: getEnv("MEMORY_SNAPSHOT", ""),
 
 		OpenAIKey:      getEnv("OPENAI_API_KEY", ""),
-		EmbeddingModel: getEnv("EMBEDDING_MODEL", ""),
+		EmbeddingModel: getEnv("EMBEDDING_MODEL", "text-embedding-ada-002"),
+		ChatModel:      getEnv("CHAT_MODEL", "gpt-4o-mini"),
+		LLMProvider:    getEnv("LLM_PROVIDER", "openai"),
+		LLMBaseURL:     getEnv("LLM_BASE_URL", ""),
+
+		JobWorkers: getEnvInt("JOB_WORKERS", 2),
+
+		RetrievalLimit: getEnvInt("RETRIEVAL_LIMIT", 5),
+		RRFK:           getEnvInt("RRF_K", 60),
+		VectorWeight:   getEnvFloat("VECTOR_WEIGHT", 1),
+		KeywordWeight:  getEnvFloat("KEYWORD_WEIGHT", 1),
+		SubjectLimit:   getEnvInt("SUBJECT_LIMIT", 3),
+		MMRLambda:      getEnvFloat("MMR_LAMBDA", 0.7),
+		ContextTokens:  getEnvInt("CONTEXT_TOKENS", 3000),
+
+		DuplicateThreshold: getEnvFloat("DUPLICATE_THRESHOLD", 0.9),
+		UnsupportedAnswers: getEnv("UNSUPPORTED_ANSWERS", "drop"),
 	}
 }
 
@@ -58,3 +120,54 @@ func getEnv(key string, defaultVal string) string {
 
 	return defaultVal
 }
+
+// getEnvInt reads an integer environment variable, falling back to the default
+// when it is missing or malformed
+func getEnvInt(key string, defaultVal int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultVal
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("invalid %s %q, using %d", key, value, defaultVal)
+		return defaultVal
+	}
+
+	return number
+}
+
+// getEnvFloat reads a decimal environment variable, falling back to the
+// default when it is missing or malformed
+func getEnvFloat(key string, defaultVal float64) float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultVal
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("invalid %s %q, using %g", key, value, defaultVal)
+		return defaultVal
+	}
+
+	return number
+}
+
+// getEnvDuration reads a duration such as "90s" from the environment, falling
+// back to the default when it is missing or malformed
+func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultVal
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("invalid %s %q, using %s", key, value, defaultVal)
+		return defaultVal
+	}
+
+	return duration
+}
@@ -1,84 +1,115 @@
 package database
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
+	"log"
 	"net/url"
 	"time"
 
-	_ "github.com/jackc/pgconn"
-	_ "github.com/jackc/pgx/v5"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
-type DB struct {
-	SQL *sql.DB
-}
-
+// Config is where the database is and how connections to it are pooled. URL,
+// when set, takes the place of the host, port, credentials and name. Pool
+// settings left at zero keep the pgxpool defaults.
 type Config struct {
+	URL      string
 	Host     string
 	Port     string
 	Password string
 	User     string
 	DBName   string
-	SSLMode  string
-}
 
-var dbConn = &DB{}
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
 
-const maxOpenDBConn = 10
-const maxIdleDBConn = 5
-const maxDBLifetime = 5 * time.Minute
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+}
 
-func testDB(d *sql.DB) error {
-	rows, err := d.Query("SELECT version()")
-	if err != nil {
-		panic(err)
+// DSN returns the connection string for the config, including its TLS
+// settings.
+func (c *Config) DSN() string {
+	if c.URL != "" {
+		return c.URL
 	}
 
-	for rows.Next() {
-		var result string
-		err = rows.Scan(&result)
-		if err != nil {
-			panic(err)
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"sslmode":     c.SSLMode,
+		"sslrootcert": c.SSLRootCert,
+		"sslcert":     c.SSLCert,
+		"sslkey":      c.SSLKey,
+	} {
+		if value != "" {
+			query.Set(key, value)
 		}
-		fmt.Printf("Version: %s\n", result)
 	}
-	return nil
-}
 
-func ConnectSQL(config *Config) (*DB, error) {
-	dsn := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=%s", config.User, config.Password, config.Host, config.Port, config.DBName, config.SSLMode)
+	dsn := url.URL{
+		Scheme:   "postgresql",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     c.Host + ":" + c.Port,
+		Path:     c.DBName,
+		RawQuery: query.Encode(),
+	}
+	return dsn.String()
+}
 
-	d, err := NewDatabase(dsn)
+// Connect migrates the database and opens a pool of connections to it that
+// understand pgvector's vector type. The pool checks the health of idle
+// connections every HealthCheckPeriod.
+func Connect(ctx context.Context, config *Config) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(config.DSN())
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	if config.MaxConns > 0 {
+		poolConfig.MaxConns = config.MaxConns
+	}
+	if config.MinConns > 0 {
+		poolConfig.MinConns = config.MinConns
+	}
+	if config.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = config.MaxConnLifetime
 	}
-	d.SetMaxOpenConns(maxOpenDBConn)
-	d.SetMaxIdleConns(maxIdleDBConn)
-	d.SetConnMaxLifetime(maxDBLifetime)
-	dbConn.SQL = d
-	err = testDB(d)
+	if config.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = config.MaxConnIdleTime
+	}
+	if config.HealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = config.HealthCheckPeriod
+	}
+
+	// The vector type only exists once the migrations have created the
+	// extension, so they run before the pool registers it.
+	migrations := stdlib.OpenDB(*poolConfig.ConnConfig.Copy())
+	err = Migrate(migrations)
+	migrations.Close()
 	if err != nil {
 		return nil, err
 	}
 
-	RunManualMigration(dsn)
-
-	return dbConn, nil
-}
-
-func NewDatabase(dsn string) (*sql.DB, error) {
-
-	conn, _ := url.Parse(dsn)
-	conn.RawQuery = "sslmode=verify-ca;sslrootcert=ca.pem"
-
-	db, err := sql.Open("pgx", conn.String())
+	poolConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		return RegisterVector(ctx, conn)
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, err
 	}
-	if err = db.Ping(); err != nil {
+
+	var version string
+	if err := pool.QueryRow(ctx, "select version()").Scan(&version); err != nil {
+		pool.Close()
 		return nil, err
 	}
-	return db, nil
+	log.Printf("Version: %s", version)
+
+	return pool, nil
 }
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestDSN(t *testing.T) {
+	config := &Config{
+		Host:     "db.example.com",
+		Port:     "5432",
+		User:     "maker",
+		Password: "p@ss word",
+		DBName:   "tests",
+		SSLMode:  "verify-full",
+	}
+
+	parsed, err := pgxpool.ParseConfig(config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := parsed.ConnConfig
+	if conn.Host != "db.example.com" || conn.Port != 5432 || conn.User != "maker" || conn.Password != "p@ss word" || conn.Database != "tests" {
+		t.Errorf("expected the connection settings to round trip, got %+v", conn.Config)
+	}
+	if conn.TLSConfig == nil || conn.TLSConfig.ServerName != "db.example.com" {
+		t.Errorf("expected verify-full to check the server name, got %+v", conn.TLSConfig)
+	}
+
+	config.SSLRootCert = "ca.pem"
+	if dsn := config.DSN(); !strings.Contains(dsn, "sslrootcert=ca.pem") {
+		t.Errorf("expected the root certificate in %s", dsn)
+	}
+
+	config.URL = "postgresql://other@localhost/other"
+	if got := config.DSN(); got != config.URL {
+		t.Errorf("expected the URL to take precedence, got %s", got)
+	}
+}
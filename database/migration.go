@@ -3,50 +3,48 @@ package database
 import (
 	"database/sql"
 	"embed"
+	"errors"
 	"io/fs"
 	"log"
 
 	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/pgx/v5"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
-	_ "github.com/lib/pq"
 )
 
 //go:embed migrations/*
 var migrationFiles embed.FS
 
-func RunManualMigration(dbURL string) {
-	// Open a connection to the database
-	db, err := sql.Open("postgres", dbURL)
+// Migrate applies the embedded migrations that have not run yet.
+func Migrate(db *sql.DB) error {
+	m, err := newMigrate(db)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		return err
 	}
-	defer db.Close()
 
-	// Use the embedded migration files
-	migrations, err := fs.Sub(migrationFiles, "migrations")
-	if err != nil {
-		log.Fatal("Failed to create sub filesystem for migrations:", err)
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
 	}
 
-	d, err := iofs.New(migrations, ".")
-	if err != nil {
-		log.Fatal("Failed to create iofs driver:", err)
-	}
+	log.Println("Completed DB migration")
+	return nil
+}
 
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+func newMigrate(db *sql.DB) (*migrate.Migrate, error) {
+	migrations, err := fs.Sub(migrationFiles, "migrations")
 	if err != nil {
-		log.Fatal("Failed to create postgres driver:", err)
+		return nil, err
 	}
 
-	m, err := migrate.NewWithInstance("iofs", d, "postgres", driver)
+	d, err := iofs.New(migrations, ".")
 	if err != nil {
-		log.Fatal("Failed to create migrate instance:", err)
+		return nil, err
 	}
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		log.Fatal("Failed to run migrations:", err)
+	driver, err := pgx.WithInstance(db, &pgx.Config{})
+	if err != nil {
+		return nil, err
 	}
 
-	log.Println("Completed DB migration")
+	return migrate.NewWithInstance("iofs", d, "pgx5", driver)
 }
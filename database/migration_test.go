@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestMigrationFiles(t *testing.T) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pattern := regexp.MustCompile(`^(\d{4})_\w+\.(up|down)\.sql$`)
+	directions := map[int]map[string]bool{}
+	for _, entry := range entries {
+		match := pattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			t.Errorf("unexpected file %s", entry.Name())
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		if directions[version] == nil {
+			directions[version] = map[string]bool{}
+		}
+		directions[version][match[2]] = true
+	}
+
+	for version := 1; version <= len(directions); version++ {
+		if !directions[version]["up"] || !directions[version]["down"] {
+			t.Errorf("expected migration %04d to go up and down, got %v", version, directions[version])
+		}
+	}
+}
+
+// TestMigrations runs every migration up and down in a schema of its own on
+// the database at TEST_DATABASE_URL, and is skipped when it is not set.
+func TestMigrations(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := stdlib.OpenDB(*config.Copy())
+	defer admin.Close()
+	for _, stmt := range []string{"drop schema if exists migration_test cascade", "create schema migration_test"} {
+		if _, err := admin.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer admin.ExecContext(ctx, "drop schema migration_test cascade")
+
+	config.RuntimeParams["search_path"] = "migration_test, public"
+	db := stdlib.OpenDB(*config)
+	defer db.Close()
+
+	m, err := newMigrate(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	checkSchema(t, db)
+	checkCascade(t, db)
+
+	if err := m.Down(); err != nil {
+		t.Fatal(err)
+	}
+	var tables int
+	err = db.QueryRowContext(ctx, `
+		select count(*) from information_schema.tables
+		where table_schema = 'migration_test' and table_name <> 'schema_migrations'
+	`).Scan(&tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("expected the down migrations to drop every table, %d are left", tables)
+	}
+
+	if err := m.Up(); err != nil {
+		t.Errorf("expected the migrations to run up again after going down: %s", err)
+	}
+}
+
+func checkSchema(t *testing.T, db *sql.DB) {
+	t.Helper()
+
+	rows, err := db.Query(`
+		select table_name from information_schema.table_constraints
+		where table_schema = 'migration_test' and constraint_type = 'PRIMARY KEY'
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string]bool{}
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			t.Fatal(err)
+		}
+		keys[table] = true
+	}
+	rows.Close()
+	for _, table := range []string{"documents", "chunks", "jobs", "tests", "questions"} {
+		if !keys[table] {
+			t.Errorf("expected %s to have a primary key", table)
+		}
+	}
+
+	rows, err = db.Query(`
+		select constraint_name, delete_rule from information_schema.referential_constraints
+		where constraint_schema = 'migration_test'
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := map[string]string{}
+	for rows.Next() {
+		var name, rule string
+		if err := rows.Scan(&name, &rule); err != nil {
+			t.Fatal(err)
+		}
+		rules[name] = rule
+	}
+	rows.Close()
+	for _, name := range []string{"chunks_document_fkey", "tests_document_fkey", "questions_test_fkey"} {
+		if rules[name] != "CASCADE" {
+			t.Errorf("expected %s to cascade deletes, got %q", name, rules[name])
+		}
+	}
+
+	var deferrable, deferred string
+	err = db.QueryRow(`
+		select is_deferrable, initially_deferred from information_schema.table_constraints
+		where table_schema = 'migration_test' and constraint_name = 'questions_test_position_key' and constraint_type = 'UNIQUE'
+	`).Scan(&deferrable, &deferred)
+	if err != nil {
+		t.Errorf("expected question positions to be unique within a test: %s", err)
+	} else if deferrable != "YES" || deferred != "YES" {
+		t.Errorf("expected the question position key to be checked at commit, got deferrable %s and deferred %s", deferrable, deferred)
+	}
+
+	var indexed bool
+	err = db.QueryRow(`
+		select exists (select 1 from pg_indexes where schemaname = 'migration_test' and indexname = 'chunks_document_idx')
+	`).Scan(&indexed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !indexed {
+		t.Error("expected chunks to be indexed by document")
+	}
+}
+
+func checkCascade(t *testing.T, db *sql.DB) {
+	t.Helper()
+	document, now := uuid.New(), time.Now()
+
+	for _, stmt := range []struct {
+		query string
+		args  []any
+	}{
+		{`insert into documents (id, url, created_at) values ($1, 'cells.pdf', $2)`, []any{document, now}},
+		{`insert into chunks (id, document, chunk) values ($1, $2, 'Mitochondria produce energy.')`, []any{uuid.New(), document}},
+		{`insert into tests (id, document, title, created_at, updated_at) values ($1, $2, 'Cells', $3, $3)`, []any{uuid.New(), document, now}},
+		{`delete from documents where id = $1`, []any{document}},
+	} {
+		if _, err := db.Exec(stmt.query, stmt.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, table := range []string{"chunks", "tests"} {
+		var left int
+		if err := db.QueryRow(fmt.Sprintf("select count(*) from %s where document = $1", table), document).Scan(&left); err != nil {
+			t.Fatal(err)
+		}
+		if left != 0 {
+			t.Errorf("expected deleting the document to delete its %s, %d are left", table, left)
+		}
+	}
+
+	_, err := db.Exec(`insert into chunks (id, document, chunk) values ($1, $2, 'orphan')`, uuid.New(), uuid.New())
+	if err == nil {
+		t.Error("expected a chunk without a document to be rejected")
+	}
+}
@@ -1 +1,2 @@
-DROP TABLE IF EXISTS chunks;
\ No newline at end of file
+DROP TABLE IF EXISTS chunks;
+DROP TABLE IF EXISTS documents;
@@ -0,0 +1,14 @@
+CREATE TABLE jobs (
+    id UUID NOT NULL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    result JSONB,
+    error TEXT NOT NULL DEFAULT '',
+    pages_done INTEGER NOT NULL DEFAULT 0,
+    pages_total INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX jobs_status_created_at_idx ON jobs (status, created_at);
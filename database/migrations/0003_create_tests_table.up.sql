@@ -0,0 +1,22 @@
+CREATE TABLE tests (
+    id UUID NOT NULL PRIMARY KEY,
+    document UUID NOT NULL,
+    title TEXT NOT NULL,
+    subjects JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX tests_document_idx ON tests (document);
+
+CREATE TABLE questions (
+    id UUID NOT NULL PRIMARY KEY,
+    test UUID NOT NULL REFERENCES tests (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    content JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX questions_test_position_idx ON questions (test, position);
@@ -0,0 +1,7 @@
+ALTER TABLE chunks
+    DROP COLUMN IF EXISTS page_from,
+    DROP COLUMN IF EXISTS page_to,
+    DROP COLUMN IF EXISTS char_start,
+    DROP COLUMN IF EXISTS char_end,
+    DROP COLUMN IF EXISTS strategy,
+    DROP COLUMN IF EXISTS heading;
@@ -0,0 +1,9 @@
+-- Chunks embedded before provenance was tracked were single sentences with
+-- no known page.
+ALTER TABLE chunks
+    ADD COLUMN page_from INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN page_to INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN char_start INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN char_end INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN strategy TEXT NOT NULL DEFAULT 'sentence',
+    ADD COLUMN heading TEXT NOT NULL DEFAULT '';
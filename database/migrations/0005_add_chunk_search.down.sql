@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS chunks_chunk_search_idx;
+
+ALTER TABLE chunks DROP COLUMN IF EXISTS chunk_search;
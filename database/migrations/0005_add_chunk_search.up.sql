@@ -0,0 +1,4 @@
+ALTER TABLE chunks
+    ADD COLUMN chunk_search TSVECTOR GENERATED ALWAYS AS (to_tsvector('english', chunk)) STORED;
+
+CREATE INDEX chunks_chunk_search_idx ON chunks USING GIN (chunk_search);
@@ -0,0 +1,2 @@
+ALTER TABLE chunks DROP CONSTRAINT IF EXISTS chunks_pkey;
+ALTER TABLE documents DROP CONSTRAINT IF EXISTS documents_pkey;
@@ -0,0 +1,7 @@
+-- Nothing stopped the same row from being inserted twice. Keep one copy of
+-- each so the keys can be added.
+DELETE FROM documents a USING documents b WHERE a.id = b.id AND a.ctid > b.ctid;
+DELETE FROM chunks a USING chunks b WHERE a.id = b.id AND a.ctid > b.ctid;
+
+ALTER TABLE documents ADD CONSTRAINT documents_pkey PRIMARY KEY (id);
+ALTER TABLE chunks ADD CONSTRAINT chunks_pkey PRIMARY KEY (id);
@@ -0,0 +1,2 @@
+ALTER TABLE tests DROP CONSTRAINT IF EXISTS tests_document_fkey;
+ALTER TABLE chunks DROP CONSTRAINT IF EXISTS chunks_document_fkey;
@@ -0,0 +1,9 @@
+-- Chunks and tests could outlive their document. They can no longer be
+-- reached, so they are dropped rather than kept without one.
+DELETE FROM chunks WHERE document NOT IN (SELECT id FROM documents);
+DELETE FROM tests WHERE document NOT IN (SELECT id FROM documents);
+
+ALTER TABLE chunks
+    ADD CONSTRAINT chunks_document_fkey FOREIGN KEY (document) REFERENCES documents (id) ON DELETE CASCADE;
+ALTER TABLE tests
+    ADD CONSTRAINT tests_document_fkey FOREIGN KEY (document) REFERENCES documents (id) ON DELETE CASCADE;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS chunks_document_idx;
@@ -0,0 +1,2 @@
+-- Every search and cascading delete looks chunks up by their document.
+CREATE INDEX chunks_document_idx ON chunks (document);
@@ -0,0 +1,3 @@
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS questions_test_position_key;
+
+CREATE INDEX IF NOT EXISTS questions_test_position_idx ON questions (test, position);
@@ -0,0 +1,15 @@
+-- Questions appended concurrently could share a position. Number them again
+-- in their current order so the key can be added.
+UPDATE questions q SET position = ordered.position
+FROM (
+    SELECT id, row_number() OVER (PARTITION BY test ORDER BY position, created_at, id) AS position
+    FROM questions
+) ordered
+WHERE q.id = ordered.id AND q.position <> ordered.position;
+
+-- Deferred to commit, since deleting and reordering questions shift positions
+-- through each other.
+ALTER TABLE questions
+    ADD CONSTRAINT questions_test_position_key UNIQUE (test, position) DEFERRABLE INITIALLY DEFERRED;
+
+DROP INDEX IF EXISTS questions_test_position_idx;
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RegisterVector teaches the connection the binary format of the pgvector
+// vector type, so embeddings are sent as []float32 instead of text.
+func RegisterVector(ctx context.Context, conn *pgx.Conn) error {
+	if _, ok := conn.TypeMap().TypeForName("vector"); ok {
+		return nil
+	}
+
+	var oid uint32
+	if err := conn.QueryRow(ctx, "select 'vector'::regtype::oid").Scan(&oid); err != nil {
+		return err
+	}
+	conn.TypeMap().RegisterType(&pgtype.Type{Name: "vector", OID: oid, Codec: vectorCodec{}})
+
+	return nil
+}
+
+// appendVector encodes v the way pgvector's vector_send does: the number of
+// dimensions and an unused word, both 16 bits, then each float big endian.
+func appendVector(buf []byte, v []float32) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(v)))
+	buf = binary.BigEndian.AppendUint16(buf, 0)
+	for _, f := range v {
+		buf = binary.BigEndian.AppendUint32(buf, math.Float32bits(f))
+	}
+	return buf
+}
+
+func decodeVector(src []byte) ([]float32, error) {
+	if len(src) < 4 {
+		return nil, fmt.Errorf("vector of %d bytes is too short", len(src))
+	}
+	dims := int(binary.BigEndian.Uint16(src))
+	if len(src) != 4+4*dims {
+		return nil, fmt.Errorf("vector of %d dimensions has %d bytes", dims, len(src))
+	}
+
+	v := make([]float32, dims)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.BigEndian.Uint32(src[4+4*i:]))
+	}
+	return v, nil
+}
+
+// formatVector writes v as a pgvector text literal.
+func formatVector(v []float32) string {
+	parts := make([]string, len(v))
+	for i, f := range v {
+		parts[i] = strconv.FormatFloat(float64(f), 'g', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// vectorCodec encodes []float32 to and from the binary format of pgvector.
+type vectorCodec struct{}
+
+func (vectorCodec) FormatSupported(format int16) bool {
+	return format == pgx.BinaryFormatCode
+}
+
+func (vectorCodec) PreferredFormat() int16 {
+	return pgx.BinaryFormatCode
+}
+
+func (vectorCodec) PlanEncode(m *pgtype.Map, oid uint32, format int16, value any) pgtype.EncodePlan {
+	if _, ok := value.([]float32); ok && format == pgx.BinaryFormatCode {
+		return encodeVectorPlan{}
+	}
+	return nil
+}
+
+func (vectorCodec) PlanScan(m *pgtype.Map, oid uint32, format int16, target any) pgtype.ScanPlan {
+	if _, ok := target.(*[]float32); ok && format == pgx.BinaryFormatCode {
+		return scanVectorPlan{}
+	}
+	return nil
+}
+
+func (vectorCodec) DecodeDatabaseSQLValue(m *pgtype.Map, oid uint32, format int16, src []byte) (driver.Value, error) {
+	if src == nil {
+		return nil, nil
+	}
+	v, err := decodeVector(src)
+	if err != nil {
+		return nil, err
+	}
+	return formatVector(v), nil
+}
+
+func (vectorCodec) DecodeValue(m *pgtype.Map, oid uint32, format int16, src []byte) (any, error) {
+	if src == nil {
+		return nil, nil
+	}
+	return decodeVector(src)
+}
+
+type encodeVectorPlan struct{}
+
+func (encodeVectorPlan) Encode(value any, buf []byte) ([]byte, error) {
+	v := value.([]float32)
+	if v == nil {
+		return nil, nil
+	}
+	return appendVector(buf, v), nil
+}
+
+type scanVectorPlan struct{}
+
+func (scanVectorPlan) Scan(src []byte, target any) error {
+	dst := target.(*[]float32)
+	if src == nil {
+		*dst = nil
+		return nil
+	}
+
+	v, err := decodeVector(src)
+	if err != nil {
+		return err
+	}
+	*dst = v
+	return nil
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestVectorEncoding(t *testing.T) {
+	v := []float32{1, -0.5, 0.25}
+	encoded := appendVector(nil, v)
+
+	want := []byte{0, 3, 0, 0, 0x3f, 0x80, 0, 0, 0xbf, 0, 0, 0, 0x3e, 0x80, 0, 0}
+	if !bytes.Equal(encoded, want) {
+		t.Errorf("expected % x, got % x", want, encoded)
+	}
+
+	decoded, err := decodeVector(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(decoded) != fmt.Sprint(v) {
+		t.Errorf("expected %v back, got %v", v, decoded)
+	}
+
+	if _, err := decodeVector(encoded[:10]); err == nil {
+		t.Error("expected an error for a truncated vector")
+	}
+	if got := formatVector(v); got != "[1,-0.5,0.25]" {
+		t.Errorf("expected a pgvector literal, got %s", got)
+	}
+}
+
+func embedding(dims int) []float32 {
+	v := make([]float32, dims)
+	for i := range v {
+		v[i] = float32(i%97) / 97
+	}
+	return v
+}
+
+// BenchmarkVectorEncoding compares the binary encoding used to copy chunks
+// with the text literal they used to be inserted as.
+func BenchmarkVectorEncoding(b *testing.B) {
+	v := embedding(1536)
+
+	b.Run("binary", func(b *testing.B) {
+		buf := make([]byte, 0, 4+4*len(v))
+		for i := 0; i < b.N; i++ {
+			buf = appendVector(buf[:0], v)
+		}
+	})
+	b.Run("text", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = fmt.Sprintf("[%s]", strings.Trim(strings.Replace(fmt.Sprint(v), " ", ",", -1), "[]"))
+		}
+	})
+}
@@ -1,13 +1,78 @@
 package bootstrap
 
-import "database/sql"
+import (
+	"context"
+
+	"github.com/bjorndonald/test-maker-service/internal/chunker"
+	"github.com/bjorndonald/test-maker-service/internal/jobs"
+	"github.com/bjorndonald/test-maker-service/internal/llm"
+	"github.com/bjorndonald/test-maker-service/internal/repository"
+	"github.com/bjorndonald/test-maker-service/internal/retrieval"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Repositories are where the service keeps its documents, jobs and tests.
+type Repositories struct {
+	Documents repository.DocumentInterface
+	Jobs      repository.JobInterface
+	Tests     repository.TestInterface
+}
+
+// PostgresRepositories keeps everything in the database.
+func PostgresRepositories(conn *pgxpool.Pool) Repositories {
+	return Repositories{
+		Documents: repository.NewPostgresRepo(conn),
+		Jobs:      repository.NewPostgresJobRepo(conn),
+		Tests:     repository.NewPostgresTestRepo(conn),
+	}
+}
+
+// MemoryRepositories keeps everything in memory, so the service runs without
+// a database.
+func MemoryRepositories(repo *repository.MemoryRepo) Repositories {
+	return Repositories{
+		Documents: repo,
+		Jobs:      repo,
+		Tests:     repo,
+	}
+}
 
 type AppDependencies struct {
-	DatabaseService *sql.DB
+	Database           *pgxpool.Pool
+	Repositories       Repositories
+	Embedder           llm.Embedder
+	ChatCompleter      llm.ChatCompleter
+	Tokenizer          chunker.Tokenizer
+	Retrieval          retrieval.Options
+	DuplicateThreshold float64
+	FlagUnsupported    bool
+	Jobs               *jobs.Runner
 }
 
-func InitializeDependencies(conn *sql.DB) *AppDependencies {
+// InitializeDependencies wires the service together. conn is nil when the
+// repositories are not backed by the database.
+func InitializeDependencies(conn *pgxpool.Pool, repos Repositories, embedder llm.Embedder, completer llm.ChatCompleter, tokenizer chunker.Tokenizer, retrievalOptions retrieval.Options, duplicateThreshold float64, flagUnsupported bool, jobWorkers int) *AppDependencies {
+	runner := jobs.NewRunner(repos.Jobs, jobWorkers)
+	jobs.NewIngestion(repos.Documents, embedder, tokenizer).Register(runner)
+
 	return &AppDependencies{
-		DatabaseService: conn,
+		Database:           conn,
+		Repositories:       repos,
+		Embedder:           embedder,
+		ChatCompleter:      completer,
+		Tokenizer:          tokenizer,
+		Retrieval:          retrievalOptions,
+		DuplicateThreshold: duplicateThreshold,
+		FlagUnsupported:    flagUnsupported,
+		Jobs:               runner,
+	}
+}
+
+// Health reports whether the database answers, and is always healthy when
+// the repositories are kept in memory.
+func (d *AppDependencies) Health(ctx context.Context) error {
+	if d.Database == nil {
+		return nil
 	}
+	return d.Database.Ping(ctx)
 }
@@ -0,0 +1,260 @@
+// Package chunker splits the text of PDF pages into chunks sized in tokens of
+// the embedding model, so each chunk carries enough context to be retrieved
+// on its own without wasting embedding calls on single sentences.
+package chunker
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Strategy string
+
+const (
+	// StrategyFixed slides a window of Size tokens over the page, each window
+	// repeating the last Overlap tokens of the one before it.
+	StrategyFixed Strategy = "fixed"
+	// StrategyParagraph packs whole paragraphs into chunks of up to Size
+	// tokens, falling back to windows for paragraphs that do not fit.
+	StrategyParagraph Strategy = "paragraph"
+	// StrategyHeading chunks paragraphs like StrategyParagraph but never across
+	// a heading of the PDF outline, and labels each chunk with its headings.
+	StrategyHeading Strategy = "heading"
+)
+
+const (
+	DefaultSize    = 256
+	DefaultOverlap = 32
+	// MaxSize is the input limit of the OpenAI embedding models.
+	MaxSize = 8191
+)
+
+var ErrOverlap = errors.New("chunk overlap must be smaller than the chunk size")
+
+// Options configure how pages are chunked. Depth limits StrategyHeading to
+// outline levels up to it, zero meaning every level.
+type Options struct {
+	Strategy Strategy `json:"strategy"`
+	Size     int      `json:"size"`
+	Overlap  int      `json:"overlap"`
+	Depth    int      `json:"depth,omitempty"`
+}
+
+func (o Options) withDefaults() Options {
+	if o.Strategy == "" {
+		o.Strategy = StrategyParagraph
+	}
+	if o.Size == 0 {
+		o.Size = DefaultSize
+	}
+	return o
+}
+
+// Chunk is a piece of a page. Start and End are character offsets of Text in
+// the page text, and Heading is the outline path of the section it sits in.
+type Chunk struct {
+	Page     int
+	Start    int
+	End      int
+	Text     string
+	Tokens   int
+	Heading  string
+	Strategy Strategy
+}
+
+type Chunker struct {
+	tokenizer Tokenizer
+	options   Options
+	outline   []Heading
+}
+
+// New returns a chunker for the given options. The outline is only used by
+// StrategyHeading, which chunks like StrategyParagraph when it is empty.
+func New(tokenizer Tokenizer, options Options, outline []Heading) (*Chunker, error) {
+	options = options.withDefaults()
+	switch options.Strategy {
+	case StrategyFixed, StrategyParagraph, StrategyHeading:
+	default:
+		return nil, fmt.Errorf("unknown chunking strategy %q", options.Strategy)
+	}
+	if options.Size < 1 || options.Size > MaxSize {
+		return nil, fmt.Errorf("chunk size must be between 1 and %d", MaxSize)
+	}
+	if options.Overlap < 0 || options.Overlap >= options.Size {
+		return nil, ErrOverlap
+	}
+
+	return &Chunker{
+		tokenizer: tokenizer,
+		options:   options,
+		outline:   outline,
+	}, nil
+}
+
+// span is a byte range of the page text.
+type span struct {
+	start   int
+	end     int
+	heading string
+}
+
+// Split chunks the text of a page.
+func (c *Chunker) Split(page int, text string) []Chunk {
+	var spans []span
+	switch c.options.Strategy {
+	case StrategyFixed:
+		spans = c.windows(text, span{end: len(text)})
+	case StrategyParagraph:
+		spans = c.paragraphs(text, span{end: len(text)})
+	case StrategyHeading:
+		for _, section := range c.sections(page, text) {
+			spans = append(spans, c.paragraphs(text, section)...)
+		}
+	}
+
+	chunks := []Chunk{}
+	for _, s := range spans {
+		raw := text[s.start:s.end]
+		start := s.start + len(raw) - len(strings.TrimLeftFunc(raw, unicode.IsSpace))
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" {
+			continue
+		}
+
+		offset := utf8.RuneCountInString(text[:start])
+		chunks = append(chunks, Chunk{
+			Page:     page,
+			Start:    offset,
+			End:      offset + utf8.RuneCountInString(trimmed),
+			Text:     trimmed,
+			Tokens:   len(c.tokenizer.Split(trimmed)),
+			Heading:  s.heading,
+			Strategy: c.options.Strategy,
+		})
+	}
+	return chunks
+}
+
+// windows covers a span with windows of Size tokens overlapping by Overlap.
+func (c *Chunker) windows(text string, s span) []span {
+	tokens := c.tokenizer.Split(text[s.start:s.end])
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	offsets := make([]int, len(tokens)+1)
+	offsets[0] = s.start
+	for i, token := range tokens {
+		offsets[i+1] = offsets[i] + len(token)
+	}
+
+	spans := []span{}
+	step := c.options.Size - c.options.Overlap
+	for i := 0; ; i += step {
+		j := min(i+c.options.Size, len(tokens))
+		spans = append(spans, span{start: runeStart(text, offsets[i]), end: runeStart(text, offsets[j]), heading: s.heading})
+		if j == len(tokens) {
+			return spans
+		}
+	}
+}
+
+// runeStart moves a byte offset forward to the start of a character, as
+// byte pair tokens can end halfway through one.
+func runeStart(text string, offset int) int {
+	for offset < len(text) && !utf8.RuneStart(text[offset]) {
+		offset++
+	}
+	return offset
+}
+
+var paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+
+// paragraphs packs the paragraphs of a span into chunks of up to Size tokens.
+func (c *Chunker) paragraphs(text string, s span) []span {
+	var paragraphs []span
+	start := s.start
+	for _, brk := range paragraphBreak.FindAllStringIndex(text[s.start:s.end], -1) {
+		paragraphs = append(paragraphs, span{start: start, end: s.start + brk[0], heading: s.heading})
+		start = s.start + brk[1]
+	}
+	paragraphs = append(paragraphs, span{start: start, end: s.end, heading: s.heading})
+
+	spans := []span{}
+	var current *span
+	flush := func() {
+		if current != nil {
+			spans = append(spans, *current)
+			current = nil
+		}
+	}
+
+	for _, p := range paragraphs {
+		if strings.TrimSpace(text[p.start:p.end]) == "" {
+			continue
+		}
+		if c.count(text[p.start:p.end]) > c.options.Size {
+			flush()
+			spans = append(spans, c.windows(text, p)...)
+			continue
+		}
+		if current != nil && c.count(text[current.start:p.end]) <= c.options.Size {
+			current.end = p.end
+			continue
+		}
+		flush()
+		current = &span{start: p.start, end: p.end, heading: s.heading}
+	}
+	flush()
+
+	return spans
+}
+
+func (c *Chunker) count(text string) int {
+	return len(c.tokenizer.Split(text))
+}
+
+// sections splits a page at the outline headings that start on it. Text
+// before the first of them belongs to the last heading of an earlier page.
+func (c *Chunker) sections(page int, text string) []span {
+	var path []string
+	sections := []span{}
+	start, pos := 0, 0
+
+	for _, heading := range c.outline {
+		if heading.Page > page || (c.options.Depth > 0 && heading.Level > c.options.Depth) {
+			continue
+		}
+
+		if heading.Page == page {
+			// Headings missing from the extracted text start where the last
+			// one ended
+			if loc := titlePattern(heading.Title).FindStringIndex(text[pos:]); loc != nil {
+				pos += loc[0]
+			}
+			sections = append(sections, span{start: start, end: pos, heading: strings.Join(path, " > ")})
+			start = pos
+		}
+
+		if heading.Level-1 < len(path) {
+			path = path[:heading.Level-1]
+		}
+		path = append(path, heading.Title)
+	}
+
+	return append(sections, span{start: start, end: len(text), heading: strings.Join(path, " > ")})
+}
+
+// titlePattern matches a heading title regardless of case and of how the
+// extracted text breaks it into lines.
+func titlePattern(title string) *regexp.Regexp {
+	words := strings.Fields(title)
+	for i, word := range words {
+		words[i] = regexp.QuoteMeta(word)
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(words, `\s+`))
+}
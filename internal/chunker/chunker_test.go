@@ -0,0 +1,224 @@
+package chunker
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bjorndonald/test-maker-service/internal/helpers"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+func numbered(prefix string, n int) string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return strings.Join(words, " ")
+}
+
+func split(t *testing.T, options Options, outline []Heading, page int, text string) []Chunk {
+	t.Helper()
+	c, err := New(Words{}, options, outline)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := c.Split(page, text)
+	runes := []rune(text)
+	for _, chunk := range chunks {
+		if got := string(runes[chunk.Start:chunk.End]); got != chunk.Text {
+			t.Errorf("offsets %d:%d give %q, want %q", chunk.Start, chunk.End, got, chunk.Text)
+		}
+		if chunk.Tokens > c.options.Size {
+			t.Errorf("chunk has %d tokens, more than %d: %q", chunk.Tokens, c.options.Size, chunk.Text)
+		}
+		if chunk.Page != page || chunk.Strategy != c.options.Strategy {
+			t.Errorf("unexpected chunk %+v", chunk)
+		}
+	}
+	return chunks
+}
+
+func TestWordsSplitsBackToText(t *testing.T) {
+	text := "  Photosynthesis, in plants; uses CO₂.\n\nÉtude 2 "
+	tokens := Words{}.Split(text)
+	if got := strings.Join(tokens, ""); got != text {
+		t.Errorf("tokens join to %q", got)
+	}
+	if len(tokens) != 11 {
+		t.Errorf("expected 11 tokens, got %d: %q", len(tokens), tokens)
+	}
+}
+
+func TestFixedWindowsOverlap(t *testing.T) {
+	text := numbered("w", 50)
+	chunks := split(t, Options{Strategy: StrategyFixed, Size: 20, Overlap: 5}, nil, 3, text)
+
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	for i := 1; i < len(chunks); i++ {
+		previous := strings.Fields(chunks[i-1].Text)
+		current := strings.Fields(chunks[i].Text)
+		if strings.Join(previous[len(previous)-5:], " ") != strings.Join(current[:5], " ") {
+			t.Errorf("chunk %d does not repeat the last 5 tokens of the one before: %q", i, chunks[i].Text)
+		}
+	}
+	if !strings.HasSuffix(chunks[2].Text, "w49") {
+		t.Errorf("last chunk stops early: %q", chunks[2].Text)
+	}
+}
+
+func TestFixedWindowsKeepCharactersWhole(t *testing.T) {
+	text := strings.Repeat("é", 10)
+	c, _ := New(bytesTokenizer{}, Options{Strategy: StrategyFixed, Size: 3, Overlap: 0}, nil)
+	for _, chunk := range c.Split(1, text) {
+		if strings.ContainsRune(chunk.Text, '�') || chunk.Text == "" {
+			t.Errorf("chunk splits a character: %q", chunk.Text)
+		}
+	}
+}
+
+// bytesTokenizer makes a token of every byte, like byte pair encodings do for
+// rare characters.
+type bytesTokenizer struct{}
+
+func (bytesTokenizer) Split(text string) []string {
+	tokens := make([]string, len(text))
+	for i := range text {
+		tokens[i] = text[i : i+1]
+	}
+	return tokens
+}
+
+func TestParagraphs(t *testing.T) {
+	text := "The cell.\n\nIts membrane.\n \nIts nucleus.\n\n" + numbered("long", 30) + "\n\nThe end."
+	chunks := split(t, Options{Strategy: StrategyParagraph, Size: 12, Overlap: 2}, nil, 1, text)
+
+	if chunks[0].Text != "The cell.\n\nIts membrane.\n \nIts nucleus." {
+		t.Errorf("expected the short paragraphs to be packed together, got %q", chunks[0].Text)
+	}
+	if !strings.HasPrefix(chunks[1].Text, "long0 ") || !strings.HasSuffix(chunks[len(chunks)-2].Text, "long29") {
+		t.Errorf("expected the long paragraph to be split into windows, got %q", chunks[1:len(chunks)-1])
+	}
+	if chunks[len(chunks)-1].Text != "The end." {
+		t.Errorf("expected the last paragraph on its own, got %q", chunks[len(chunks)-1].Text)
+	}
+}
+
+var outline = []Heading{
+	{Title: "Cells", Level: 1, Page: 1},
+	{Title: "Membranes", Level: 2, Page: 1},
+	{Title: "Organelles", Level: 2, Page: 2},
+	{Title: "Nucleus", Level: 3, Page: 2},
+	{Title: "Tissues", Level: 1, Page: 2},
+	{Title: "Organs", Level: 1, Page: 3},
+}
+
+func TestHeadings(t *testing.T) {
+	text := "lipids form a bilayer.\n\nORGANELLES\nsmall organs.\n\nNucleus holds DNA.\n\nTissues group cells."
+	chunks := split(t, Options{Strategy: StrategyHeading, Size: 100}, outline, 2, text)
+
+	want := []struct{ heading, text string }{
+		{"Cells > Membranes", "lipids form a bilayer."},
+		{"Cells > Organelles", "ORGANELLES\nsmall organs."},
+		{"Cells > Organelles > Nucleus", "Nucleus holds DNA."},
+		{"Tissues", "Tissues group cells."},
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("expected %d chunks, got %+v", len(want), chunks)
+	}
+	for i, w := range want {
+		if chunks[i].Heading != w.heading || chunks[i].Text != w.text {
+			t.Errorf("chunk %d is %q under %q, want %q under %q", i, chunks[i].Text, chunks[i].Heading, w.text, w.heading)
+		}
+	}
+
+	chunks = split(t, Options{Strategy: StrategyHeading, Size: 100, Depth: 1}, outline, 2, text)
+	if len(chunks) != 2 || chunks[0].Heading != "Cells" || chunks[1].Heading != "Tissues" {
+		t.Errorf("expected only top level headings to split, got %+v", chunks)
+	}
+}
+
+func TestNewRejectsBadOptions(t *testing.T) {
+	for _, options := range []Options{
+		{Strategy: "sentence"},
+		{Size: MaxSize + 1},
+		{Size: 10, Overlap: 10},
+		{Overlap: -1},
+	} {
+		if _, err := New(Words{}, options, nil); err == nil {
+			t.Errorf("expected %+v to be rejected", options)
+		}
+	}
+}
+
+func TestOutline(t *testing.T) {
+	description := `{"paper": "A4P", "origin": "LowerLeft", "pages": {
+		"1": {"content": {"text": [
+			{"value": "Cells", "pos": [50, 780], "font": {"name": "Helvetica", "size": 12}},
+			{"value": "All living things are made of cells.", "pos": [50, 750], "font": {"name": "Helvetica", "size": 12}},
+			{"value": "Membranes", "pos": [50, 700], "font": {"name": "Helvetica", "size": 12}},
+			{"value": "A membrane surrounds the cell.", "pos": [50, 686], "font": {"name": "Helvetica", "size": 12}}
+		]}},
+		"2": {"content": {"text": [
+			{"value": "Tissues", "pos": [50, 780], "font": {"name": "Helvetica", "size": 12}}
+		]}}
+	}}`
+
+	var created bytes.Buffer
+	if err := api.Create(nil, strings.NewReader(description), &created, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var marked bytes.Buffer
+	bookmarks := []pdfcpu.Bookmark{
+		{Title: "Cells", PageFrom: 1, Kids: []pdfcpu.Bookmark{{Title: "Membranes", PageFrom: 1}}},
+		{Title: "Tissues", PageFrom: 2},
+	}
+	if err := api.AddBookmarks(bytes.NewReader(created.Bytes()), &marked, bookmarks, true, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "book.pdf")
+	if err := os.WriteFile(path, marked.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	headings, err := Outline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Heading{{"Cells", 1, 1}, {"Membranes", 2, 1}, {"Tissues", 1, 2}}
+	if fmt.Sprint(headings) != fmt.Sprint(want) {
+		t.Fatalf("expected outline %v, got %v", want, headings)
+	}
+
+	text, err := helpers.ExtractPDFText(path, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := split(t, Options{Strategy: StrategyHeading, Size: 100}, headings, 1, text)
+	if len(chunks) != 2 || chunks[0].Heading != "Cells" || chunks[1].Heading != "Cells > Membranes" {
+		t.Fatalf("unexpected chunks %+v of %q", chunks, text)
+	}
+	if chunks[1].Text != "Membranes\nA membrane surrounds the cell." {
+		t.Errorf("expected lines of a paragraph to be kept, got %q", chunks[1].Text)
+	}
+}
+
+func TestTiktokenMatchesModel(t *testing.T) {
+	tokenizer, err := NewTiktoken("text-embedding-ada-002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := tokenizer.Split("hello world")
+	if len(tokens) != 2 || strings.Join(tokens, "") != "hello world" {
+		t.Errorf("unexpected tokens %q", tokens)
+	}
+}
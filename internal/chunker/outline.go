@@ -0,0 +1,43 @@
+package chunker
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Heading is an entry of a PDF outline. Level 1 headings are the top level
+// bookmarks, their children are level 2 and so on.
+type Heading struct {
+	Title string
+	Level int
+	Page  int
+}
+
+// Outline reads the bookmarks of a PDF in document order. A PDF without
+// bookmarks has an empty outline.
+func Outline(path string) ([]Heading, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file: %w", err)
+	}
+	defer file.Close()
+
+	bookmarks, err := api.Bookmarks(file, model.NewDefaultConfiguration())
+	if err != nil {
+		return nil, fmt.Errorf("could not read outline: %w", err)
+	}
+
+	return flatten(nil, bookmarks, 1), nil
+}
+
+func flatten(headings []Heading, bookmarks []pdfcpu.Bookmark, level int) []Heading {
+	for _, bookmark := range bookmarks {
+		headings = append(headings, Heading{Title: bookmark.Title, Level: level, Page: bookmark.PageFrom})
+		headings = flatten(headings, bookmark.Kids, level+1)
+	}
+	return headings
+}
@@ -0,0 +1,77 @@
+package chunker
+
+import (
+	"log"
+	"regexp"
+
+	"github.com/bjorndonald/test-maker-service/constants"
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+func init() {
+	// Read vocabularies from the ones embedded in the binary rather than
+	// downloading them, so the service starts without network access.
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// Tokenizer splits text into the tokens the embedding model sees. The tokens
+// concatenate back to the text, so windows of tokens map to offsets in it.
+type Tokenizer interface {
+	Split(text string) []string
+}
+
+// NewTokenizer returns the tokenizer of the embedding model selected by
+// EMBEDDING_MODEL. The fake provider counts words instead.
+func NewTokenizer(config *constants.Config) (Tokenizer, error) {
+	if config.LLMProvider == "fake" {
+		return Words{}, nil
+	}
+
+	tokenizer, err := NewTiktoken(config.EmbeddingModel)
+	if err != nil {
+		return nil, err
+	}
+	return tokenizer, nil
+}
+
+// Tiktoken tokenizes text with the byte pair encoding of an OpenAI model.
+// The vocabularies are embedded in the binary.
+type Tiktoken struct {
+	encoding *tiktoken.Tiktoken
+}
+
+func NewTiktoken(model string) (*Tiktoken, error) {
+	encoding, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		// Models served by compatible providers are unknown to tiktoken,
+		// cl100k_base is the closest vocabulary most of them share
+		log.Printf("no tiktoken encoding for model %s, counting tokens with %s", model, tiktoken.MODEL_CL100K_BASE)
+		encoding, err = tiktoken.GetEncoding(tiktoken.MODEL_CL100K_BASE)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Tiktoken{encoding: encoding}, nil
+}
+
+func (t *Tiktoken) Split(text string) []string {
+	ids := t.encoding.EncodeOrdinary(text)
+	tokens := make([]string, 0, len(ids))
+	for _, id := range ids {
+		// A token may hold part of a multi-byte character, which is fine as
+		// long as the tokens are only ever joined back together
+		tokens = append(tokens, t.encoding.Decode([]int{id}))
+	}
+	return tokens
+}
+
+var words = regexp.MustCompile(`\s*[\p{L}\p{N}]+|\s*[^\s\p{L}\p{N}]|\s+`)
+
+// Words approximates a model tokenizer offline by treating every word and
+// every punctuation mark as a token, along with the spaces before it.
+type Words struct{}
+
+func (Words) Split(text string) []string {
+	return words.FindAllString(text, -1)
+}
@@ -0,0 +1,125 @@
+// Package dedup rejects generated questions that mean the same as one already
+// asked, comparing the embeddings of their text.
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/bjorndonald/test-maker-service/internal/llm"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+)
+
+// DefaultThreshold is the cosine similarity above which two questions are
+// taken to be the same.
+const DefaultThreshold = 0.9
+
+type Deduplicator struct {
+	embedder  llm.Embedder
+	threshold float64
+}
+
+// New returns a Deduplicator rejecting questions more similar than threshold,
+// or than DefaultThreshold when it is not between 0 and 1.
+func New(embedder llm.Embedder, threshold float64) *Deduplicator {
+	if threshold <= 0 || threshold > 1 {
+		threshold = DefaultThreshold
+	}
+	return &Deduplicator{
+		embedder:  embedder,
+		threshold: threshold,
+	}
+}
+
+// Set holds the questions new ones are compared against.
+type Set struct {
+	dedup   *Deduplicator
+	vectors [][]float32
+}
+
+// NewSet starts a set from questions that were already asked.
+func (d *Deduplicator) NewSet(ctx context.Context, existing []models.Question) (*Set, error) {
+	set := &Set{dedup: d}
+	if len(existing) == 0 {
+		return set, nil
+	}
+
+	vectors, err := d.embed(ctx, existing)
+	if err != nil {
+		return nil, err
+	}
+	set.vectors = vectors
+	return set, nil
+}
+
+// Unique keeps up to limit of the questions that are not too similar to any in
+// the set or to each other, adding them to the set, and returns the ones it
+// kept and the ones it rejected as duplicates.
+func (s *Set) Unique(ctx context.Context, questions []models.Question, limit int) ([]models.Question, []models.Question, error) {
+	kept := []models.Question{}
+	rejected := []models.Question{}
+	if len(questions) == 0 {
+		return kept, rejected, nil
+	}
+
+	vectors, err := s.dedup.embed(ctx, questions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, q := range questions {
+		if len(kept) == limit {
+			break
+		}
+		if s.contains(vectors[i]) {
+			rejected = append(rejected, q)
+			continue
+		}
+		s.vectors = append(s.vectors, vectors[i])
+		kept = append(kept, q)
+	}
+	return kept, rejected, nil
+}
+
+func (s *Set) contains(vector []float32) bool {
+	for _, other := range s.vectors {
+		if Cosine(vector, other) > s.dedup.threshold {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Deduplicator) embed(ctx context.Context, questions []models.Question) ([][]float32, error) {
+	texts := make([]string, len(questions))
+	for i, q := range questions {
+		texts[i] = q.Question + "\n" + q.Answer
+	}
+
+	vectors, err := d.embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("embedding error: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embedding error: got %d embeddings for %d questions", len(vectors), len(texts))
+	}
+	return vectors, nil
+}
+
+// Cosine is the cosine similarity of two vectors, 0 when either is empty.
+func Cosine(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,81 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bjorndonald/test-maker-service/internal/llm"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+)
+
+func question(text, answer string) models.Question {
+	return models.Question{Question: text, Answer: answer}
+}
+
+func TestUnique(t *testing.T) {
+	ctx := context.Background()
+	set, err := New(llm.NewFake(64), 0).NewSet(ctx, []models.Question{
+		question("What is the capital of France?", "Paris"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept, rejected, err := set.Unique(ctx, []models.Question{
+		question("What is the capital of France?", "Paris."),
+		question("Which river flows through Cairo?", "The Nile"),
+		question("Which river flows through Cairo", "the Nile"),
+		question("How many legs does a spider have?", "Eight"),
+	}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(kept) != 2 || kept[0].Answer != "The Nile" || kept[1].Answer != "Eight" {
+		t.Errorf("expected the new questions to be kept once, got %+v", kept)
+	}
+	if len(rejected) != 2 {
+		t.Errorf("expected a saved and a repeated question to be rejected, got %+v", rejected)
+	}
+
+	kept, _, err = set.Unique(ctx, []models.Question{question("How many legs does a spider have?", "8 legs")}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 1 {
+		t.Errorf("expected a question below the threshold to be kept, got %+v", kept)
+	}
+}
+
+func TestUniqueStopsAtLimit(t *testing.T) {
+	ctx := context.Background()
+	set, _ := New(llm.NewFake(64), 0.99).NewSet(ctx, nil)
+
+	kept, _, err := set.Unique(ctx, []models.Question{
+		question("Name a noble gas.", "Neon"),
+		question("Name an alkali metal.", "Sodium"),
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 1 {
+		t.Fatalf("expected a single question, got %+v", kept)
+	}
+
+	kept, _, _ = set.Unique(ctx, []models.Question{question("Name an alkali metal.", "Sodium")}, 1)
+	if len(kept) != 1 {
+		t.Errorf("expected a question over the limit not to count as seen, got %+v", kept)
+	}
+}
+
+func TestCosine(t *testing.T) {
+	if got := Cosine([]float32{1, 0}, []float32{2, 0}); got != 1 {
+		t.Errorf("expected parallel vectors to score 1, got %v", got)
+	}
+	if got := Cosine([]float32{1, 0}, []float32{0, 3}); got != 0 {
+		t.Errorf("expected orthogonal vectors to score 0, got %v", got)
+	}
+	if got := Cosine(nil, []float32{1}); got != 0 {
+		t.Errorf("expected an empty vector to score 0, got %v", got)
+	}
+}
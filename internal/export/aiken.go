@@ -0,0 +1,62 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+)
+
+func init() {
+	register("aiken", Aiken{})
+}
+
+// Aiken writes the Aiken format, which only knows single answer multiple
+// choice questions. True/false questions are written as two option questions
+// and every other type is left out.
+type Aiken struct{}
+
+func (Aiken) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (Aiken) Extension() string { return "aiken.txt" }
+
+// aikenLine puts text on a single line, as every Aiken entry must be.
+func aikenLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func (Aiken) Export(w io.Writer, test models.Test) error {
+	out := bufio.NewWriter(w)
+
+	for _, q := range test.Questions {
+		var options []string
+		correct := -1
+
+		switch q.Type {
+		case models.MultipleChoice:
+			options = q.Options
+			if q.CorrectIndex != nil {
+				correct = *q.CorrectIndex
+			}
+		case models.TrueFalse:
+			options = []string{"True", "False"}
+			correct = 1
+			if q.IsTrue != nil && *q.IsTrue {
+				correct = 0
+			}
+		}
+		if correct < 0 || correct >= len(options) || len(options) > 26 {
+			continue
+		}
+
+		fmt.Fprintln(out, aikenLine(q.Question))
+		for j, option := range options {
+			fmt.Fprintf(out, "%c. %s\n", 'A'+j, aikenLine(option))
+		}
+		fmt.Fprintf(out, "ANSWER: %c\n\n", 'A'+correct)
+	}
+
+	return out.Flush()
+}
@@ -0,0 +1,59 @@
+package export
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+)
+
+// ErrUnknownFormat is returned by For when no exporter handles the format.
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Exporter serializes a saved test into a format other tools can import.
+type Exporter interface {
+	Export(w io.Writer, test models.Test) error
+	ContentType() string
+	Extension() string
+}
+
+var exporters = map[string]Exporter{}
+
+func register(format string, exporter Exporter) {
+	exporters[format] = exporter
+}
+
+// For returns the exporter registered for format.
+func For(format string) (Exporter, error) {
+	exporter, ok := exporters[strings.ToLower(format)]
+	if !ok {
+		return nil, ErrUnknownFormat
+	}
+	return exporter, nil
+}
+
+// Formats lists the registered export formats.
+func Formats() []string {
+	formats := make([]string, 0, len(exporters))
+	for format := range exporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// category is the name the test's questions are filed under in the target
+// question bank.
+func category(test models.Test) string {
+	if test.Title != "" {
+		return test.Title
+	}
+	return strings.Join(test.Subjects, ", ")
+}
+
+// blanks splits a fill in the blank question around its gaps.
+func blanks(question string) []string {
+	return models.BlankPattern.Split(question, -1)
+}
@@ -0,0 +1,138 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/models/modelstest"
+	"github.com/google/uuid"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// fixture adds text that needs escaping in each format to the shared
+// question of every type.
+func fixture() models.Test {
+	extra := []models.Question{
+		{
+			Type:         models.MultipleChoice,
+			Question:     "Which gas do plants give off? (pick 1: {best} answer)",
+			Options:      []string{"Oxygen = O2", "Nitrogen & argon", "<Helium>"},
+			CorrectIndex: modelstest.Index(0),
+			Feedback:     "It is a by-product of photosynthesis #biology",
+		},
+		{
+			Type:     models.TrueFalse,
+			Question: "Ribosomes are\nmembrane bound.",
+			IsTrue:   modelstest.Boolean(false),
+			Feedback: "They have no membrane.",
+		},
+		{
+			Type:     models.FillInTheBlank,
+			Question: "___ converts light into ___ energy.",
+			Blanks:   []string{"Chlorophyll", "chemical/stored"},
+		},
+		{
+			Type:     models.Matching,
+			Question: "Match each structure to its role.",
+			Pairs: []models.MatchPair{
+				{Left: "Vacuole", Right: "Stores water"},
+				{Left: "Lysosome", Right: "Digests waste ~ debris"},
+				{Left: "Cell wall", Right: "Keeps the cell's shape"},
+			},
+		},
+		{
+			Type:     models.ShortAnswer,
+			Question: "What does DNA stand for?",
+			Answer:   "Deoxyribonucleic acid",
+			Feedback: "It's the cell's genetic code.",
+		},
+	}
+	for i := range extra {
+		extra[i].Normalize()
+	}
+
+	questions := append(modelstest.Questions(), extra...)
+	for i := range questions {
+		questions[i].Id = uuid.MustParse(fmt.Sprintf("00000000-0000-0000-0000-%012d", i+1))
+		questions[i].Position = i + 1
+	}
+
+	return models.Test{
+		Id:         uuid.MustParse("10000000-0000-0000-0000-000000000000"),
+		DocumentId: uuid.MustParse("20000000-0000-0000-0000-000000000000"),
+		Title:      "Biology: cells/organelles",
+		Subjects:   []string{"cells"},
+		Questions:  questions,
+	}
+}
+
+func TestExportersMatchGoldenFiles(t *testing.T) {
+	for _, format := range Formats() {
+		t.Run(format, func(t *testing.T) {
+			exporter, err := For(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := exporter.Export(&buf, fixture()); err != nil {
+				t.Fatal(err)
+			}
+			if exporter.ContentType() == "application/zip" {
+				buf = unzip(t, buf.Bytes())
+			}
+
+			golden := filepath.Join("testdata", format+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("output differs from %s (run with -update to refresh):\n%s", golden, buf.String())
+			}
+		})
+	}
+}
+
+// unzip lists the files of an archive one after another, so packages can be
+// compared as text.
+func unzip(t *testing.T, data []byte) bytes.Buffer {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&buf, "== %s ==\n", file.Name)
+		if _, err := io.Copy(&buf, reader); err != nil {
+			t.Fatal(err)
+		}
+		reader.Close()
+	}
+	return buf
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := For("docx"); err != ErrUnknownFormat {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+}
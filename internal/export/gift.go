@@ -0,0 +1,107 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+)
+
+func init() {
+	register("gift", GIFT{})
+}
+
+// GIFT writes Moodle's GIFT text format. Fill in the blank questions with more
+// than one gap and ordering questions have no GIFT equivalent, so they are
+// written as short answer and matching questions respectively.
+type GIFT struct{}
+
+func (GIFT) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (GIFT) Extension() string { return "gift.txt" }
+
+var giftEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`~`, `\~`,
+	`=`, `\=`,
+	`#`, `\#`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`:`, `\:`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+func escapeGIFT(text string) string {
+	return giftEscaper.Replace(text)
+}
+
+func (GIFT) Export(w io.Writer, test models.Test) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "$CATEGORY: $course$/top/%s\n\n", strings.ReplaceAll(category(test), "/", "//"))
+
+	for i, q := range test.Questions {
+		fmt.Fprintf(out, "::Q%d:: ", i+1)
+
+		feedback := ""
+		if q.Feedback != "" {
+			feedback = "####" + escapeGIFT(q.Feedback)
+		}
+
+		switch q.Type {
+		case models.MultipleChoice:
+			fmt.Fprintf(out, "%s {\n", escapeGIFT(q.Question))
+			for j, option := range q.Options {
+				mark := "~"
+				if q.CorrectIndex != nil && *q.CorrectIndex == j {
+					mark = "="
+				}
+				fmt.Fprintf(out, "\t%s%s\n", mark, escapeGIFT(option))
+			}
+			if feedback != "" {
+				fmt.Fprintf(out, "\t%s\n", feedback)
+			}
+			out.WriteString("}\n")
+		case models.TrueFalse:
+			truth := "FALSE"
+			if q.IsTrue != nil && *q.IsTrue {
+				truth = "TRUE"
+			}
+			fmt.Fprintf(out, "%s {%s%s}\n", escapeGIFT(q.Question), truth, feedback)
+		case models.FillInTheBlank:
+			parts := blanks(q.Question)
+			if len(parts) == 2 && len(q.Blanks) == 1 {
+				fmt.Fprintf(out, "%s{=%s%s}%s\n", escapeGIFT(parts[0]), escapeGIFT(q.Blanks[0]), feedback, escapeGIFT(parts[1]))
+			} else {
+				fmt.Fprintf(out, "%s {=%s%s}\n", escapeGIFT(q.Question), escapeGIFT(q.Answer), feedback)
+			}
+		case models.Matching:
+			fmt.Fprintf(out, "%s {\n", escapeGIFT(q.Question))
+			for _, pair := range q.Pairs {
+				fmt.Fprintf(out, "\t=%s -> %s\n", escapeGIFT(pair.Left), escapeGIFT(pair.Right))
+			}
+			if feedback != "" {
+				fmt.Fprintf(out, "\t%s\n", feedback)
+			}
+			out.WriteString("}\n")
+		case models.Ordering:
+			fmt.Fprintf(out, "%s {\n", escapeGIFT(q.Question))
+			for j, item := range q.Sequence {
+				fmt.Fprintf(out, "\t=%s -> %d\n", escapeGIFT(item), j+1)
+			}
+			if feedback != "" {
+				fmt.Fprintf(out, "\t%s\n", feedback)
+			}
+			out.WriteString("}\n")
+		default:
+			fmt.Fprintf(out, "%s {=%s%s}\n", escapeGIFT(q.Question), escapeGIFT(q.Answer), feedback)
+		}
+
+		out.WriteString("\n")
+	}
+
+	return out.Flush()
+}
@@ -0,0 +1,185 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+)
+
+func init() {
+	register("moodlexml", MoodleXML{})
+}
+
+// MoodleXML writes the Moodle XML question format. Text fields are HTML, so
+// they are HTML escaped before the XML encoder escapes them again.
+type MoodleXML struct{}
+
+func (MoodleXML) ContentType() string { return "application/xml" }
+
+func (MoodleXML) Extension() string { return "xml" }
+
+type moodleQuiz struct {
+	XMLName   xml.Name         `xml:"quiz"`
+	Questions []moodleQuestion `xml:"question"`
+}
+
+type moodleText struct {
+	Format string `xml:"format,attr,omitempty"`
+	Text   string `xml:"text"`
+}
+
+type moodleAnswer struct {
+	Fraction string      `xml:"fraction,attr"`
+	Format   string      `xml:"format,attr,omitempty"`
+	Text     string      `xml:"text"`
+	Feedback *moodleText `xml:"feedback,omitempty"`
+}
+
+type moodleSubquestion struct {
+	Format string     `xml:"format,attr"`
+	Text   string     `xml:"text"`
+	Answer moodleText `xml:"answer"`
+}
+
+type moodleQuestion struct {
+	Type            string              `xml:"type,attr"`
+	Category        *moodleText         `xml:"category,omitempty"`
+	Name            *moodleText         `xml:"name,omitempty"`
+	QuestionText    *moodleText         `xml:"questiontext,omitempty"`
+	GeneralFeedback *moodleText         `xml:"generalfeedback,omitempty"`
+	DefaultGrade    string              `xml:"defaultgrade,omitempty"`
+	Single          string              `xml:"single,omitempty"`
+	ShuffleAnswers  string              `xml:"shuffleanswers,omitempty"`
+	AnswerNumbering string              `xml:"answernumbering,omitempty"`
+	UseCase         string              `xml:"usecase,omitempty"`
+	LayoutType      string              `xml:"layouttype,omitempty"`
+	SelectType      string              `xml:"selecttype,omitempty"`
+	Answers         []moodleAnswer      `xml:"answer"`
+	Subquestions    []moodleSubquestion `xml:"subquestion"`
+}
+
+// escapeHTML escapes text content. Quotes are left alone since they only
+// matter in attributes, and cloze answers escape them their own way.
+var escapeHTML = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+
+func htmlText(text string) *moodleText {
+	return &moodleText{Format: "html", Text: escapeHTML(text)}
+}
+
+func (MoodleXML) Export(w io.Writer, test models.Test) error {
+	quiz := moodleQuiz{
+		Questions: []moodleQuestion{{
+			Type:     "category",
+			Category: &moodleText{Text: "$course$/top/" + strings.ReplaceAll(category(test), "/", "//")},
+		}},
+	}
+
+	for i, q := range test.Questions {
+		question := moodleQuestion{
+			Name:         &moodleText{Text: fmt.Sprintf("Q%d", i+1)},
+			QuestionText: htmlText(q.Question),
+			DefaultGrade: "1",
+		}
+		if q.Feedback != "" {
+			question.GeneralFeedback = htmlText(q.Feedback)
+		}
+
+		switch q.Type {
+		case models.MultipleChoice:
+			question.Type = "multichoice"
+			question.Single = "true"
+			question.ShuffleAnswers = "true"
+			question.AnswerNumbering = "abc"
+			for j, option := range q.Options {
+				fraction := "0"
+				if q.CorrectIndex != nil && *q.CorrectIndex == j {
+					fraction = "100"
+				}
+				question.Answers = append(question.Answers, moodleAnswer{Fraction: fraction, Format: "html", Text: escapeHTML(option)})
+			}
+		case models.TrueFalse:
+			question.Type = "truefalse"
+			truth := q.IsTrue != nil && *q.IsTrue
+			question.Answers = []moodleAnswer{
+				{Fraction: fraction(truth), Format: "moodle_auto_format", Text: "true"},
+				{Fraction: fraction(!truth), Format: "moodle_auto_format", Text: "false"},
+			}
+		case models.FillInTheBlank:
+			question.Type = "cloze"
+			question.QuestionText = htmlText(clozeText(q))
+			question.DefaultGrade = ""
+		case models.Matching:
+			question.Type = "matching"
+			question.ShuffleAnswers = "true"
+			for _, pair := range q.Pairs {
+				question.Subquestions = append(question.Subquestions, moodleSubquestion{
+					Format: "html",
+					Text:   escapeHTML(pair.Left),
+					Answer: moodleText{Text: pair.Right},
+				})
+			}
+		case models.Ordering:
+			question.Type = "ordering"
+			question.LayoutType = "VERTICAL"
+			question.SelectType = "ALL"
+			for j, item := range q.Sequence {
+				question.Answers = append(question.Answers, moodleAnswer{Fraction: fmt.Sprint(j + 1), Format: "html", Text: escapeHTML(item)})
+			}
+		default:
+			question.Type = "shortanswer"
+			question.UseCase = "0"
+			question.Answers = []moodleAnswer{{Fraction: "100", Format: "moodle_auto_format", Text: q.Answer}}
+		}
+
+		quiz.Questions = append(quiz.Questions, question)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(quiz); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func fraction(correct bool) string {
+	if correct {
+		return "100"
+	}
+	return "0"
+}
+
+// clozeText replaces each blank with a Moodle embedded short answer.
+func clozeText(q models.Question) string {
+	parts := blanks(q.Question)
+	var text strings.Builder
+	for i, part := range parts {
+		text.WriteString(part)
+		if i < len(q.Blanks) && i < len(parts)-1 {
+			text.WriteString("{1:SHORTANSWER:=")
+			text.WriteString(escapeCloze(q.Blanks[i]))
+			text.WriteString("}")
+		}
+	}
+	return text.String()
+}
+
+var clozeEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`}`, `\}`,
+	`#`, `\#`,
+	`~`, `\~`,
+	`/`, `\/`,
+	`"`, `\"`,
+)
+
+func escapeCloze(text string) string {
+	return clozeEscaper.Replace(text)
+}
@@ -0,0 +1,27 @@
+package export
+
+import (
+	"io"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/qti"
+)
+
+func init() {
+	register("qti21", QTI{Version: qti.V21})
+	register("qti30", QTI{Version: qti.V30})
+}
+
+// QTI writes an IMS QTI content package, a zip file with a manifest and one
+// assessment item per question.
+type QTI struct {
+	Version qti.Version
+}
+
+func (QTI) ContentType() string { return "application/zip" }
+
+func (QTI) Extension() string { return "zip" }
+
+func (e QTI) Export(w io.Writer, test models.Test) error {
+	return qti.Write(w, test, e.Version)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/bjorndonald/test-maker-service/internal/dedup"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// BlueprintCell asks for Count questions on a subject, of one type, difficulty
+// and level of Bloom's taxonomy. Type defaults to short answer, and an empty
+// difficulty or level is left to the model.
+type BlueprintCell struct {
+	Subject        string                `json:"subject" validate:"required"`
+	Difficulty     models.Difficulty     `json:"difficulty" validate:"omitempty,oneof=easy medium hard"`
+	Type           models.QuestionType   `json:"type" validate:"omitempty,oneof=short_answer multiple_choice true_false fill_in_the_blank matching ordering"`
+	CognitiveLevel models.CognitiveLevel `json:"cognitive_level" validate:"omitempty,oneof=remember understand apply analyze evaluate create"`
+	Count          int                   `json:"count" validate:"required,min=1,max=50"`
+}
+
+// CoverageCell reports how many questions of a blueprint cell were asked for
+// and how many of those produced match it.
+type CoverageCell struct {
+	Subject        string                `json:"subject"`
+	Difficulty     models.Difficulty     `json:"difficulty,omitempty"`
+	Type           models.QuestionType   `json:"type"`
+	CognitiveLevel models.CognitiveLevel `json:"cognitive_level,omitempty"`
+	Requested      int                   `json:"requested"`
+	Produced       int                   `json:"produced"`
+}
+
+// GeneratedTest is a test with the coverage of the blueprint it was generated
+// from, if any, and how many of its questions test each level of Bloom's
+// taxonomy.
+type GeneratedTest struct {
+	models.Test
+	Coverage        []CoverageCell                `json:"coverage,omitempty"`
+	CognitiveLevels map[models.CognitiveLevel]int `json:"cognitive_levels"`
+}
+
+type GeneratedTestResponse struct {
+	Success bool          `json:"success"`
+	Message string        `json:"message"`
+	Data    GeneratedTest `json:"data"`
+}
+
+// blueprintSubjects lists the subjects of the cells, each once.
+func blueprintSubjects(cells []BlueprintCell) []string {
+	subjects := []string{}
+	seen := map[string]bool{}
+	for _, cell := range cells {
+		if !seen[cell.Subject] {
+			seen[cell.Subject] = true
+			subjects = append(subjects, cell.Subject)
+		}
+	}
+	return subjects
+}
+
+// covers reports whether a question is what the cell asked for, going by the
+// tags the classifier settled on.
+func (cell BlueprintCell) covers(q models.Question) bool {
+	return (cell.Difficulty == "" || q.Difficulty == cell.Difficulty) &&
+		(cell.CognitiveLevel == "" || q.CognitiveLevel == cell.CognitiveLevel)
+}
+
+// generateBlueprint fills each cell of the blueprint with its own retrieval
+// and prompt. A question only counts towards its cell when the classifier
+// agrees it has the difficulty and level asked for.
+func (a *Handler) generateBlueprint(c *gin.Context, seen *dedup.Set, documentId string, cells []BlueprintCell) ([]models.Question, []CoverageCell, error) {
+	questions := []models.Question{}
+	coverage := make([]CoverageCell, 0, len(cells))
+
+	for _, cell := range cells {
+		if cell.Type == "" {
+			cell.Type = models.ShortAnswer
+		}
+
+		chunks, err := a.retrieveContext(c, documentId, []string{cell.Subject})
+		if err != nil {
+			return nil, nil, fmt.Errorf("search error: %w", err)
+		}
+
+		generated, err := a.generateType(c, seen, cell.Type, cell.Count, []string{cell.Subject}, chunks, cell.Difficulty, cell.CognitiveLevel, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		questions = append(questions, generated...)
+
+		produced := 0
+		for _, q := range generated {
+			if cell.covers(q) {
+				produced++
+			}
+		}
+
+		coverage = append(coverage, CoverageCell{
+			Subject:        cell.Subject,
+			Difficulty:     cell.Difficulty,
+			Type:           cell.Type,
+			CognitiveLevel: cell.CognitiveLevel,
+			Requested:      cell.Count,
+			Produced:       produced,
+		})
+	}
+
+	return questions, coverage, nil
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bjorndonald/test-maker-service/internal/export"
+	"github.com/bjorndonald/test-maker-service/internal/helpers"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/qti"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Export test
+//
+// @Summary Export test
+// @Description Download a saved test as Moodle XML, GIFT, Aiken or an IMS QTI 2.1/3.0 package
+// @Tags Tests
+// @Produce xml
+// @Produce plain
+// @Produce application/zip
+// @Param id path string true "Test ID"
+// @Param format query string true "moodlexml, gift, aiken, qti21 or qti30"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tests/{id}/export [get]
+func (a *Handler) ExportTest(c *gin.Context) {
+	exporter, err := export.For(c.Query("format"))
+	if err != nil {
+		helpers.ReturnError(c, "Error validating input", fmt.Errorf("format must be one of %s", strings.Join(export.Formats(), ", ")), http.StatusBadRequest)
+		return
+	}
+
+	test, ok := a.loadTest(c)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := exporter.Export(&buf, test); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, test.Id, exporter.Extension()))
+	c.Data(http.StatusOK, exporter.ContentType(), buf.Bytes())
+}
+
+// Import QTI package
+//
+// @Summary Import QTI package
+// @Description Read the questions of an IMS QTI 2.1 or 3.0 package into a new test, or append them to an existing one
+// @Tags Tests
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "QTI content package"
+// @Param document_id formData string false "Document ID of a new test"
+// @Param test_id formData string false "Test ID to append to"
+// @Param title formData string false "Title of a new test"
+// @Success 200 {object} ImportResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tests/import [post]
+func (a *Handler) ImportTest(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		helpers.ReturnError(c, "File is required", err, http.StatusBadRequest)
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	pkg, err := qti.Read(file, header.Size)
+	if err != nil {
+		helpers.ReturnError(c, "Invalid QTI package", err, http.StatusBadRequest)
+		return
+	}
+	if len(pkg.Questions) == 0 {
+		helpers.ReturnError(c, "Invalid QTI package", errors.New("package has no supported questions"), http.StatusBadRequest)
+		return
+	}
+
+	var test models.Test
+	if testId := c.PostForm("test_id"); testId != "" {
+		if _, err := uuid.Parse(testId); err != nil {
+			helpers.ReturnError(c, "Error parsing test ID", err, http.StatusBadRequest)
+			return
+		}
+
+		test, err = a.testRepo.RetrieveTest(c, testId)
+		if errors.Is(err, sql.ErrNoRows) {
+			helpers.ReturnError(c, "Test not found", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+			return
+		}
+	} else {
+		documentId, err := uuid.Parse(c.PostForm("document_id"))
+		if err != nil {
+			helpers.ReturnError(c, "Error parsing document ID", err, http.StatusBadRequest)
+			return
+		}
+		if _, ok := a.loadDocument(c, documentId.String()); !ok {
+			return
+		}
+
+		test = models.Test{
+			Id:         uuid.New(),
+			DocumentId: documentId,
+			Title:      c.PostForm("title"),
+			Subjects:   []string{},
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
+		if test.Title == "" {
+			test.Title = strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
+		}
+
+		if _, err := a.testRepo.InsertTest(c, test); err != nil {
+			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if _, err := a.testRepo.InsertQuestions(c, test.Id.String(), pkg.Questions); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	test.Questions, err = a.testRepo.RetrieveQuestions(c, test.Id.String())
+	if err != nil {
+		helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+		return
+	}
+
+	skipped := pkg.Skipped
+	if skipped == nil {
+		skipped = []string{}
+	}
+	helpers.ReturnJSON(c, "Questions imported succesfully", ImportedTest{Test: test, Skipped: skipped}, http.StatusOK)
+}
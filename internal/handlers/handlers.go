@@ -1,40 +1,54 @@
 package handlers
 
 import (
-	"encoding/json"
+	"database/sql"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/bjorndonald/test-maker-service/internal/chunker"
+	"github.com/bjorndonald/test-maker-service/internal/dedup"
 	"github.com/bjorndonald/test-maker-service/internal/helpers"
+	"github.com/bjorndonald/test-maker-service/internal/jobs"
+	"github.com/bjorndonald/test-maker-service/internal/llm"
 	"github.com/bjorndonald/test-maker-service/internal/models"
 	"github.com/bjorndonald/test-maker-service/internal/repository"
+	"github.com/bjorndonald/test-maker-service/internal/retrieval"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/pdfcpu/pdfcpu/pkg/api"
-	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 )
 
 type Handler struct {
-	docuRepo repository.DocumentInterface
+	docuRepo  repository.DocumentInterface
+	jobRepo   repository.JobInterface
+	testRepo  repository.TestInterface
+	jobs      *jobs.Runner
+	embedder  llm.Embedder
+	completer llm.ChatCompleter
+	retriever *retrieval.Retriever
+	dedup     *dedup.Deduplicator
+	// flagUnsupported keeps questions whose answers the sources don't
+	// support, marked as such, instead of dropping them.
+	flagUnsupported bool
 }
 
-func NewHandler(docuRepo repository.DocumentInterface) *Handler {
+func NewHandler(docuRepo repository.DocumentInterface, jobRepo repository.JobInterface, testRepo repository.TestInterface, runner *jobs.Runner, embedder llm.Embedder, completer llm.ChatCompleter, retriever *retrieval.Retriever, deduplicator *dedup.Deduplicator, flagUnsupported bool) *Handler {
 	return &Handler{
-		docuRepo: docuRepo,
-	}
-}
+		docuRepo:  docuRepo,
+		jobRepo:   jobRepo,
+		testRepo:  testRepo,
+		jobs:      runner,
+		embedder:  embedder,
+		completer: completer,
+		retriever: retriever,
+		dedup:     deduplicator,
 
-type AnalyzedPDF struct {
-	Id            string   `json:"id"`
-	NumberOfPages int      `json:"numberOfPages"`
-	Pdfs          []string `json:"pdfs"`
+		flagUnsupported: flagUnsupported,
+	}
 }
 
 type LinkInput struct {
@@ -47,35 +61,130 @@ type Selection struct {
 }
 
 type PagesInput struct {
-	Id         string      `json:"id" validate:"required"`
-	Selections []Selection `json:"selections" validate:"required"`
+	Id         string         `json:"id" validate:"required"`
+	Selections []Selection    `json:"selections" validate:"required"`
+	Chunking   *ChunkingInput `json:"chunking"`
+}
+
+// ChunkingInput picks how the pages are split before embedding. Size and
+// overlap are counted in tokens of the embedding model.
+type ChunkingInput struct {
+	Strategy chunker.Strategy `json:"strategy" validate:"omitempty,oneof=fixed paragraph heading"`
+	Size     int              `json:"size" validate:"omitempty,min=16,max=8191"`
+	Overlap  *int             `json:"overlap" validate:"omitempty,min=0"`
+	Depth    int              `json:"depth" validate:"omitempty,min=1"`
+}
+
+// Options returns the chunker options, defaulting to paragraphs of
+// chunker.DefaultSize tokens.
+func (c *ChunkingInput) Options() chunker.Options {
+	options := chunker.Options{
+		Strategy: chunker.StrategyParagraph,
+		Size:     chunker.DefaultSize,
+		Overlap:  chunker.DefaultOverlap,
+	}
+	if c == nil {
+		return options
+	}
+
+	if c.Strategy != "" {
+		options.Strategy = c.Strategy
+	}
+	if c.Size != 0 {
+		options.Size = c.Size
+	}
+	if c.Overlap != nil {
+		options.Overlap = *c.Overlap
+	}
+	options.Depth = c.Depth
+	return options
 }
 
 type QuestionInput struct {
-	Id       string   `json:"id" validate:"required"`
-	Num      int      `json:"num" validate:"required"`
-	Subjects []string `json:"subjects" validate:"required"`
+	Id        string                      `json:"id" validate:"required"`
+	TestId    string                      `json:"test_id" validate:"omitempty,uuid"`
+	Title     string                      `json:"title"`
+	Num       int                         `json:"num" validate:"omitempty,min=1,max=50"`
+	Type      models.QuestionType         `json:"type" validate:"omitempty,oneof=short_answer multiple_choice true_false fill_in_the_blank matching ordering"`
+	Types     map[models.QuestionType]int `json:"types" validate:"omitempty,dive,keys,oneof=short_answer multiple_choice true_false fill_in_the_blank matching ordering,endkeys,min=1,max=50"`
+	Subjects  []string                    `json:"subjects" validate:"required_without=Blueprint"`
+	Blueprint []BlueprintCell             `json:"blueprint" validate:"omitempty,max=20,dive"`
+
+	Difficulty     models.Difficulty     `json:"difficulty" validate:"omitempty,oneof=easy medium hard"`
+	CognitiveLevel models.CognitiveLevel `json:"cognitive_level" validate:"omitempty,oneof=remember understand apply analyze evaluate create"`
 }
 
-type AnalyzeResponse struct {
+// Mix returns how many questions of each type were asked for. Types takes
+// precedence over the single Num and Type pair, and a Blueprint over both.
+func (q QuestionInput) Mix() map[models.QuestionType]int {
+	if len(q.Types) > 0 {
+		return q.Types
+	}
+	if q.Num == 0 {
+		return nil
+	}
+	if q.Type == "" {
+		return map[models.QuestionType]int{models.ShortAnswer: q.Num}
+	}
+	return map[models.QuestionType]int{q.Type: q.Num}
+}
+
+type AcceptedJob struct {
+	JobId      string `json:"job_id"`
+	DocumentId string `json:"document_id"`
+}
+
+type JobStatus struct {
+	Id         string           `json:"id"`
+	Kind       models.JobKind   `json:"kind"`
+	Status     models.JobStatus `json:"status"`
+	PagesDone  int              `json:"pages_done"`
+	PagesTotal int              `json:"pages_total"`
+	Error      string           `json:"error,omitempty"`
+	Result     interface{}      `json:"result,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+type AcceptedResponse struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message"`
-	Data    AnalyzedPDF `json:"data"`
+	Data    AcceptedJob `json:"data"`
 }
 
-type Question struct {
-	Question string `json:"question"`
-	Answer   string `json:"answer"`
+type JobResponse struct {
+	Success bool      `json:"success"`
+	Message string    `json:"message"`
+	Data    JobStatus `json:"data"`
 }
 
-type QuestionResult struct {
-	Questions []Question `json:"questions"`
+type QuestionResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    models.Question `json:"data"`
 }
 
-type QuestionResponse struct {
-	Success bool       `json:"success"`
-	Message string     `json:"message"`
-	Data    []Question `json:"data"`
+type TestResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Data    models.Test `json:"data"`
+}
+
+type TestsResponse struct {
+	Success bool          `json:"success"`
+	Message string        `json:"message"`
+	Data    []models.Test `json:"data"`
+}
+
+type ImportedTest struct {
+	Test    models.Test `json:"test"`
+	Skipped []string    `json:"skipped"`
+}
+
+type ImportResponse struct {
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Data    ImportedTest `json:"data"`
 }
 
 type SuccessResponse struct {
@@ -92,11 +201,11 @@ type ErrorResponse struct {
 // Analyze PDF
 //
 // @Summary Analyze pdf to retrieve pages
-// @Description Analyze pdf to retrieve pages
+// @Description Queue a job extracting the pages of an uploaded pdf. Poll /jobs/{id} for the pages.
 // @Tags PDF
 // @Accept json
 // @Produce json
-// @Success 200 {object} AnalyzeResponse
+// @Success 202 {object} AcceptedResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /analyze [post]
@@ -108,88 +217,18 @@ func (a *Handler) AnalyzePdf(c *gin.Context) {
 		return
 	}
 
-	file, err := os.Open(filePath.(string))
-	if !exists {
-		helpers.ReturnError(c, "Issue reading file", err, http.StatusBadRequest)
-		c.Abort()
-		return
-	}
-	defer file.Close()
-
-	numPages, err := api.PageCount(file, model.NewDefaultConfiguration())
-	if err != nil {
-		helpers.ReturnError(c, "Issue getting page number", err, http.StatusBadRequest)
-		c.Abort()
-		return
-	}
-
-	numWorkers := 5
-	numTasks := numPages
-	log.Println(numPages)
-	jobs := make(chan int, numTasks)
-	results := make(chan string, numTasks)
-
-	var wg sync.WaitGroup
-
-	for i := 1; i <= numWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			for pageNum := range jobs {
-				encoded, err := helpers.ExtractPageAsBase64(filePath.(string), pageNum)
-				if err != nil {
-					helpers.ReturnError(c, "Issue reading file", err, http.StatusBadRequest)
-					c.Abort()
-					return
-				}
-				results <- encoded
-			}
-			wg.Done()
-		}()
-	}
-
-	for i := 1; i <= numTasks; i++ {
-		jobs <- i
-		log.Printf("job: %d", i)
-	}
-
-	close(jobs)
-
-	wg.Wait()
-	close(results)
-
-	pdfpages := []string{}
-
-	for result := range results {
-		pdfpages = append(pdfpages, result)
-	}
-
-	id, err := a.docuRepo.InsertDocument(c, models.Document{
-		Id:        uuid.New(),
-		Url:       filePath.(string),
-		CreatedAt: time.Now(),
-	})
-
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
-		return
-	}
-
-	helpers.ReturnJSON(c, "Pdf analyzed succesfully", AnalyzedPDF{
-		Id:            id,
-		NumberOfPages: numPages,
-		Pdfs:          pdfpages,
-	}, http.StatusOK)
+	a.queueAnalysis(c, filePath.(string), "")
 }
 
 // Analyze PDF Link
 //
 // @Summary Analyze pdf link to retrieve pages
-// @Description Analyze pdf link to retrieve pages
+// @Description Queue a job downloading a pdf and extracting its pages. Poll /jobs/{id} for the pages.
 // @Tags PDF
 // @Accept json
 // @Produce json
 // @Param credentials body LinkInput true "PDF Link"
-// @Success 200 {object} AnalyzeResponse
+// @Success 202 {object} AcceptedResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /analyze/link [post]
@@ -208,111 +247,49 @@ func (a *Handler) AnalyzeLink(c *gin.Context) {
 		return
 	}
 
-	newReq, err := http.NewRequest(http.MethodGet, input.Link, nil)
-
-	client := &http.Client{}
-	resp, err := client.Do(newReq)
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
-		return
-	}
-	defer func() {
-		if resp != nil {
-			resp.Body.Close()
-		}
-	}()
-	data, err := ioutil.ReadAll(resp.Body)
+	inputFile := fmt.Sprintf("assets/documents/%s.pdf", uuid.NewString())
+	a.queueAnalysis(c, inputFile, input.Link)
+}
 
+// queueAnalysis records the document and queues the job extracting its
+// pages. When link is set the job downloads the document to path first.
+func (a *Handler) queueAnalysis(c *gin.Context, path string, link string) {
+	id, err := a.docuRepo.InsertDocument(c, models.Document{
+		Id:        uuid.New(),
+		Url:       path,
+		CreatedAt: time.Now(),
+	})
 	if err != nil {
 		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
 		return
 	}
 
-	inputFile := fmt.Sprintf("assets/documents/%s.pdf", uuid.NewString())
-	err = os.WriteFile(inputFile, data, os.ModeAppend)
+	job, err := a.jobs.Enqueue(c, models.AnalyzeJob, jobs.AnalyzePayload{
+		DocumentId: id,
+		Link:       link,
+	}, 0)
 	if err != nil {
 		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
 		return
 	}
 
-	file, err := os.Open(inputFile)
-	if !exists {
-		helpers.ReturnError(c, "Issue reading file", err, http.StatusBadRequest)
-		c.Abort()
-		return
-	}
-	defer file.Close()
-
-	numPages, err := api.PageCount(file, model.NewDefaultConfiguration())
-	if err != nil {
-		helpers.ReturnError(c, "Issue getting page number", err, http.StatusBadRequest)
-		c.Abort()
-		return
-	}
-
-	numWorkers := 5
-	numTasks := numPages
-	log.Println(numPages)
-	jobs := make(chan int, numTasks)
-	results := make(chan string, numTasks)
-
-	var wg sync.WaitGroup
-
-	for i := 1; i <= numWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			for pageNum := range jobs {
-				encoded, err := helpers.ExtractPageAsBase64(inputFile, pageNum)
-				if err != nil {
-					helpers.ReturnError(c, "Issue reading file", err, http.StatusBadRequest)
-					c.Abort()
-					return
-				}
-				results <- encoded
-			}
-			wg.Done()
-		}()
-	}
-
-	for i := 1; i <= numTasks; i++ {
-		jobs <- i
-		log.Printf("job: %d", i)
-	}
-
-	close(jobs)
-
-	wg.Wait()
-	close(results)
-
-	pdfpages := []string{}
-
-	for result := range results {
-		pdfpages = append(pdfpages, result)
-	}
-
-	id, err := a.docuRepo.InsertDocument(c, models.Document{
-		Id:        uuid.New(),
-		Url:       inputFile,
-		CreatedAt: time.Now(),
-	})
-
-	helpers.ReturnJSON(c, "Pdf analyzed succesfully", AnalyzedPDF{
-		Id:            id,
-		NumberOfPages: numPages,
-		Pdfs:          pdfpages,
-	}, http.StatusOK)
+	helpers.ReturnJSON(c, "Pdf analysis queued", AcceptedJob{
+		JobId:      job.Id.String(),
+		DocumentId: id,
+	}, http.StatusAccepted)
 }
 
 // Embed pages of the pdf
 //
 // @Summary Embed PDF
-// @Description Embed PDF
+// @Description Queue a job embedding the selected pages. Poll /jobs/{id} for progress.
 // @Tags PDF
 // @Accept json
 // @Produce json
 // @Param credentials body PagesInput true "PDF pages"
-// @Success 200 {object} SuccessResponse
+// @Success 202 {object} AcceptedResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /embed [post]
 func (a *Handler) EmbedPages(c *gin.Context) {
@@ -330,75 +307,67 @@ func (a *Handler) EmbedPages(c *gin.Context) {
 		return
 	}
 
-	documentId, err := uuid.Parse(pages.Id)
-	if err != nil {
-		helpers.ReturnError(c, "Error parsing document ID", err, http.StatusInternalServerError)
+	if _, err := uuid.Parse(pages.Id); err != nil {
+		helpers.ReturnError(c, "Error parsing document ID", err, http.StatusBadRequest)
 		c.Abort()
 		return
 	}
 
-	doc, err := a.docuRepo.RetrieveDocument(c, pages.Id)
-	if err != nil {
-		helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+	doc, ok := a.loadDocument(c, pages.Id)
+	if !ok {
 		c.Abort()
 		return
 	}
 
-	selectedPages := []int{}
-	for _, selection := range pages.Selections {
-		for i := selection.From; i <= selection.To; i++ {
-			selectedPages = append(selectedPages, i)
-		}
-	}
-
-	text, err := helpers.ExtractPDFText(doc.Url, selectedPages)
-	if err != nil {
-		helpers.ReturnError(c, "Text extraction error", err, http.StatusInternalServerError)
+	if _, err := os.Stat(doc.Url); err != nil {
+		helpers.ReturnError(c, "Document has not been analyzed yet", err, http.StatusConflict)
 		c.Abort()
 		return
 	}
 
-	sentences := helpers.TokenizeSentences(text)
-
-	embeddings, err := helpers.GetOpenAIEmbeddings(sentences)
-	if err != nil {
-		helpers.ReturnError(c, "Embedding error", err, http.StatusInternalServerError)
+	chunking := pages.Chunking.Options()
+	if chunking.Overlap >= chunking.Size {
+		helpers.ReturnError(c, "Error validating input", chunker.ErrOverlap, http.StatusBadRequest)
 		c.Abort()
 		return
 	}
 
-	chunks := []models.Chunk{}
-	log.Println(len(sentences))
-	log.Println(len(embeddings))
-	for i, embedding := range embeddings {
-		chunks = append(chunks, models.Chunk{
-			Id:             uuid.New(),
-			DocumentId:     documentId,
-			Chunk:          sentences[i],
-			ChunkEmbedding: embedding,
-		})
+	selectedPages := []int{}
+	for _, selection := range pages.Selections {
+		for i := selection.From; i <= selection.To; i++ {
+			selectedPages = append(selectedPages, i)
+		}
 	}
 
-	err = a.docuRepo.InsertChunks(c, chunks)
+	job, err := a.jobs.Enqueue(c, models.EmbedJob, jobs.EmbedPayload{
+		DocumentId: pages.Id,
+		Pages:      selectedPages,
+		Chunking:   chunking,
+	}, len(selectedPages))
 	if err != nil {
-		helpers.ReturnError(c, "Embedding error", err, http.StatusInternalServerError)
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
 		c.Abort()
 		return
 	}
 
-	helpers.ReturnJSON(c, "Pages embedded succesfully", nil, http.StatusOK)
+	helpers.ReturnJSON(c, "Page embedding queued", AcceptedJob{
+		JobId:      job.Id.String(),
+		DocumentId: pages.Id,
+	}, http.StatusAccepted)
 }
 
 // Generate questions for the PDF
 //
 // @Summary Generate Questions
-// @Description GenerateQuestions
+// @Description Generate questions and save them to a new test, or append them to test_id. A blueprint sets how many questions to generate for each subject, difficulty, type and level of Bloom's taxonomy, and the response reports the coverage of each of its cells.
 // @Tags PDF
 // @Accept json
 // @Produce json
-// // @Param credentials body QuestionInput true "PDF pages"
-// @Success 200 {object} SuccessResponse
+// @Param credentials body QuestionInput true "PDF pages"
+// @Success 200 {object} GeneratedTestResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /generate [post]
 func (a *Handler) GenerateQuestions(c *gin.Context) {
@@ -416,50 +385,260 @@ func (a *Handler) GenerateQuestions(c *gin.Context) {
 		return
 	}
 
-	prompt := ""
-	if len(question.Subjects) > 0 {
-		prompt = fmt.Sprintf("Please generate a list of %d questions: %s", question.Num, strings.Join(question.Subjects, ", "))
-	} else {
-		prompt = fmt.Sprintf("Please generate a list of %d  questions", question.Num)
+	mix := question.Mix()
+	if len(mix) == 0 && len(question.Blueprint) == 0 {
+		helpers.ReturnError(c, "Error validating input", errors.New("num, types or blueprint is required"), http.StatusBadRequest)
+		return
+	}
+	subjects := question.Subjects
+	if len(subjects) == 0 {
+		subjects = blueprintSubjects(question.Blueprint)
 	}
 
-	embeds, err := helpers.GetOpenAIEmbeddings([]string{prompt})
+	documentId, err := uuid.Parse(question.Id)
 	if err != nil {
-		helpers.ReturnError(c, "Embedding error", err, http.StatusInternalServerError)
-		c.Abort()
+		helpers.ReturnError(c, "Error parsing document ID", err, http.StatusBadRequest)
+		return
+	}
+	if _, ok := a.loadDocument(c, question.Id); !ok {
 		return
 	}
 
-	chunks, err := a.docuRepo.VectorSearch(c, question.Id, embeds[0])
+	test := models.Test{
+		Id:         uuid.New(),
+		DocumentId: documentId,
+		Title:      question.Title,
+		Subjects:   subjects,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	if test.Title == "" {
+		test.Title = defaultTestTitle(subjects)
+	}
+
+	if question.TestId != "" {
+		test, err = a.testRepo.RetrieveTest(c, question.TestId)
+		if errors.Is(err, sql.ErrNoRows) {
+			helpers.ReturnError(c, "Test not found", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+			return
+		}
+		if test.DocumentId != documentId {
+			helpers.ReturnError(c, "Error validating input", errors.New("test belongs to another document"), http.StatusBadRequest)
+			return
+		}
+	}
+
+	seen, err := a.documentQuestions(c, question.Id)
 	if err != nil {
-		helpers.ReturnError(c, "Search error", err, http.StatusInternalServerError)
-		c.Abort()
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
 		return
 	}
 
-	context := ""
-	for _, v := range chunks {
-		context += v.Chunk + "\n"
+	var questions []models.Question
+	var coverage []CoverageCell
+	if len(question.Blueprint) > 0 {
+		questions, coverage, err = a.generateBlueprint(c, seen, question.Id, question.Blueprint)
+		if err != nil {
+			returnGenerationError(c, err)
+			return
+		}
+	} else {
+		chunks, err := a.retrieveContext(c, question.Id, question.Subjects)
+		if err != nil {
+			helpers.ReturnError(c, "Search error", err, http.StatusInternalServerError)
+			c.Abort()
+			return
+		}
+
+		for _, questionType := range models.QuestionTypes {
+			num := mix[questionType]
+			if num == 0 {
+				continue
+			}
+
+			generated, err := a.generateType(c, seen, questionType, num, question.Subjects, chunks, question.Difficulty, question.CognitiveLevel, "")
+			if err != nil {
+				returnGenerationError(c, err)
+				return
+			}
+			questions = append(questions, generated...)
+		}
+	}
+
+	if question.TestId == "" {
+		if _, err := a.testRepo.InsertTest(c, test); err != nil {
+			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if _, err := a.testRepo.InsertQuestions(c, test.Id.String(), questions); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
 	}
 
-	choices, err := helpers.GenerateQuestions(c, prompt, context)
+	test.Questions, err = a.testRepo.RetrieveQuestions(c, test.Id.String())
 	if err != nil {
-		helpers.ReturnError(c, "Generating error", err, http.StatusInternalServerError)
-		c.Abort()
+		helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
 		return
 	}
 
-	var result QuestionResult
+	helpers.ReturnJSON(c, "Questions retrieved succesfully", GeneratedTest{
+		Test:            test,
+		Coverage:        coverage,
+		CognitiveLevels: test.CognitiveLevels(),
+	}, http.StatusOK)
+}
+
+func defaultTestTitle(subjects []string) string {
+	if len(subjects) == 0 {
+		return "Untitled test"
+	}
+	return "Test on " + strings.Join(subjects, ", ")
+}
+
+func subjectsPrompt(subjects []string) string {
+	if len(subjects) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(subjects, ", ")
+}
+
+// retrieveContext finds the chunks of the document relevant to each of the
+// subjects.
+func (a *Handler) retrieveContext(c *gin.Context, documentId string, subjects []string) ([]models.Chunk, error) {
+	return a.retriever.Context(c, documentId, subjects)
+}
+
+// generateType asks for num questions of one type from the retrieved chunks,
+// citing the chunks each question came from. The questions are written for
+// the difficulty and cognitive level when they are set, then a second pass
+// checks and corrects their tags. Every answer is checked against the chunks,
+// and questions whose answers they don't support are dropped unless the
+// handler flags them instead. Dropped questions and those too similar to one
+// in seen are replaced, asking up to MAX_GENERATION_ROUNDS times.
+// instructions, when set, is appended to the prompt.
+func (a *Handler) generateType(c *gin.Context, seen *dedup.Set, questionType models.QuestionType, num int, subjects []string, chunks []models.Chunk, difficulty models.Difficulty, level models.CognitiveLevel, instructions string) ([]models.Question, error) {
+	label := questionType.Label()
+	if difficulty != "" {
+		label = string(difficulty) + " " + label
+	}
+	if level != "" {
+		label += fmt.Sprintf(" questions at the %s level of Bloom's taxonomy", level)
+	} else {
+		label += " questions"
+	}
+
+	context := helpers.FormatContext(chunks)
 
-	log.Println(choices[0].Message.Content)
-	questionRes := strings.ReplaceAll(strings.ReplaceAll(choices[0].Message.Content, "```", ""), "json", "")
-	questionRes = strings.ReplaceAll(questionRes, `'`, `"`)
-	err = json.Unmarshal([]byte(questionRes), &result)
+	generated := []models.Question{}
+	repeated := []string{}
+	for round := 1; round <= helpers.MAX_GENERATION_ROUNDS && len(generated) < num; round++ {
+		prompt := fmt.Sprintf("Please generate a list of %d %s%s", num-len(generated), label, subjectsPrompt(subjects))
+		if instructions != "" {
+			prompt += "\n" + instructions
+		}
+		if len(repeated) > 0 {
+			prompt += "\nThese questions were already asked, ask something different:\n- " + strings.Join(repeated, "\n- ")
+		}
+
+		batch, err := helpers.GenerateQuestions(c, a.completer, questionType, difficulty, level, prompt, chunks)
+		if err != nil && round == 1 {
+			return nil, err
+		}
+		if err != nil {
+			log.Printf("could not generate replacement questions: %s", err)
+			break
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		if err := helpers.VerifyAnswers(c, a.completer, batch, context); err != nil {
+			log.Printf("could not verify answers: %s", err)
+		}
+		grounded := []models.Question{}
+		for _, q := range batch {
+			if q.Grounding == models.Unsupported && !a.flagUnsupported {
+				log.Printf("dropping question %q with an unsupported answer", q.Question)
+				repeated = append(repeated, q.Question)
+				continue
+			}
+			grounded = append(grounded, q)
+		}
+
+		kept, rejected, err := seen.Unique(c, grounded, num-len(generated))
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range rejected {
+			log.Printf("rejecting duplicate question %q", q.Question)
+		}
+		for _, q := range append(kept, rejected...) {
+			repeated = append(repeated, q.Question)
+		}
+		generated = append(generated, kept...)
+	}
+
+	for i := range generated {
+		generated[i].Difficulty = difficulty
+		generated[i].CognitiveLevel = level
+	}
+	if err := helpers.ClassifyQuestions(c, a.completer, generated); err != nil {
+		log.Printf("could not classify questions: %s", err)
+	}
+
+	return generated, nil
+}
+
+// documentQuestions starts a set of the questions already saved for the
+// document, so new ones can be checked against them.
+func (a *Handler) documentQuestions(c *gin.Context, documentId string) (*dedup.Set, error) {
+	tests, err := a.testRepo.ListTests(c, documentId)
 	if err != nil {
-		helpers.ReturnError(c, "Result parsing error", err, http.StatusInternalServerError)
+		return nil, err
+	}
+
+	existing := []models.Question{}
+	for _, test := range tests {
+		questions, err := a.testRepo.RetrieveQuestions(c, test.Id.String())
+		if err != nil {
+			return nil, err
+		}
+		existing = append(existing, questions...)
+	}
+
+	return a.dedup.NewSet(c, existing)
+}
+
+func returnGenerationError(c *gin.Context, err error) {
+	var structuredErr *llm.StructuredOutputError
+	if errors.As(err, &structuredErr) {
+		helpers.ReturnError(c, "The model did not return valid questions", err, http.StatusUnprocessableEntity)
 		c.Abort()
 		return
 	}
 
-	helpers.ReturnJSON(c, "Questions retrieved succesfully", result.Questions, http.StatusOK)
+	helpers.ReturnError(c, "Generating error", err, http.StatusInternalServerError)
+	c.Abort()
+}
+
+// loadDocument retrieves a document, responding with 404 when it does not
+// exist. It reports false when a response has been written.
+func (a *Handler) loadDocument(c *gin.Context, id string) (models.Document, bool) {
+	doc, err := a.docuRepo.RetrieveDocument(c, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ReturnError(c, "Document not found", err, http.StatusNotFound)
+		return doc, false
+	}
+	if err != nil {
+		helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+		return doc, false
+	}
+
+	return doc, true
 }
@@ -0,0 +1,734 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bjorndonald/test-maker-service/internal/chunker"
+	"github.com/bjorndonald/test-maker-service/internal/dedup"
+	"github.com/bjorndonald/test-maker-service/internal/helpers"
+	"github.com/bjorndonald/test-maker-service/internal/llm"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/repository"
+	"github.com/bjorndonald/test-maker-service/internal/retrieval"
+	"github.com/bjorndonald/test-maker-service/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type stubRepo struct {
+	chunks   []models.Chunk
+	document models.Document
+	missing  bool
+}
+
+func (s *stubRepo) InsertChunks(ctx context.Context, chunks []models.Chunk) error {
+	s.chunks = append(s.chunks, chunks...)
+	return nil
+}
+
+func (s *stubRepo) InsertDocument(ctx context.Context, doc models.Document) (string, error) {
+	return doc.Id.String(), nil
+}
+
+func (s *stubRepo) RetrieveDocument(ctx context.Context, id string) (models.Document, error) {
+	if s.missing {
+		return models.Document{}, sql.ErrNoRows
+	}
+	return s.document, nil
+}
+
+func (s *stubRepo) DeleteDocument(ctx context.Context, id string) error {
+	return nil
+}
+
+func (s *stubRepo) DeletePageChunks(ctx context.Context, document_id string, page int) error {
+	return nil
+}
+
+func (s *stubRepo) VectorSearch(ctx context.Context, document_id string, prompt []float32, limit int) ([]models.Chunk, error) {
+	if len(s.chunks) > limit {
+		return s.chunks[:limit], nil
+	}
+	return s.chunks, nil
+}
+
+func (s *stubRepo) KeywordSearch(ctx context.Context, document_id string, query string, limit int) ([]models.Chunk, error) {
+	return nil, nil
+}
+
+type stubTestRepo struct {
+	mu        sync.Mutex
+	tests     map[string]models.Test
+	questions map[string][]models.Question
+}
+
+func newStubTestRepo() *stubTestRepo {
+	return &stubTestRepo{tests: map[string]models.Test{}, questions: map[string][]models.Question{}}
+}
+
+func (s *stubTestRepo) InsertTest(ctx context.Context, test models.Test) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tests[test.Id.String()] = test
+	return test.Id.String(), nil
+}
+
+func (s *stubTestRepo) RetrieveTest(ctx context.Context, id string) (models.Test, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	test, ok := s.tests[id]
+	if !ok {
+		return test, sql.ErrNoRows
+	}
+	return test, nil
+}
+
+func (s *stubTestRepo) ListTests(ctx context.Context, document_id string) ([]models.Test, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tests := []models.Test{}
+	for _, test := range s.tests {
+		if document_id == "" || test.DocumentId.String() == document_id {
+			tests = append(tests, test)
+		}
+	}
+	return tests, nil
+}
+
+func (s *stubTestRepo) InsertQuestions(ctx context.Context, test_id string, questions []models.Question) ([]models.Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := []models.Question{}
+	for _, q := range questions {
+		q.Id = uuid.New()
+		q.Position = len(s.questions[test_id]) + 1
+		s.questions[test_id] = append(s.questions[test_id], q)
+		saved = append(saved, q)
+	}
+	return saved, nil
+}
+
+func (s *stubTestRepo) RetrieveQuestions(ctx context.Context, test_id string) ([]models.Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	questions := append([]models.Question{}, s.questions[test_id]...)
+	sort.Slice(questions, func(i, j int) bool { return questions[i].Position < questions[j].Position })
+	return questions, nil
+}
+
+func (s *stubTestRepo) RetrieveQuestion(ctx context.Context, test_id string, id string) (models.Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, q := range s.questions[test_id] {
+		if q.Id.String() == id {
+			return q, nil
+		}
+	}
+	return models.Question{}, sql.ErrNoRows
+}
+
+func (s *stubTestRepo) UpdateQuestion(ctx context.Context, test_id string, question models.Question) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, q := range s.questions[test_id] {
+		if q.Id == question.Id {
+			s.questions[test_id][i] = question
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (s *stubTestRepo) DeleteQuestion(ctx context.Context, test_id string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := []models.Question{}
+	for _, q := range s.questions[test_id] {
+		if q.Id.String() != id {
+			q.Position = len(kept) + 1
+			kept = append(kept, q)
+		}
+	}
+	s.questions[test_id] = kept
+	return nil
+}
+
+func (s *stubTestRepo) ReorderQuestions(ctx context.Context, test_id string, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(ids) != len(s.questions[test_id]) {
+		return repository.ErrQuestionSetMismatch
+	}
+	for i, q := range s.questions[test_id] {
+		position := -1
+		for j, id := range ids {
+			if q.Id.String() == id {
+				position = j + 1
+			}
+		}
+		if position < 0 {
+			return repository.ErrQuestionSetMismatch
+		}
+		s.questions[test_id][i].Position = position
+	}
+	return nil
+}
+
+func newTestHandler(fake *llm.Fake) (*Handler, *stubTestRepo) {
+	tests := newStubTestRepo()
+	docs := &stubRepo{}
+	return NewHandler(docs, nil, tests, nil, fake, fake, retrieval.New(docs, fake, chunker.Words{}, retrieval.Options{}), dedup.New(fake, 0), false), tests
+}
+
+func serve(handler gin.HandlerFunc, body interface{}) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	c.Set("validatedRequestBody", body)
+	handler(c)
+	return w
+}
+
+func TestGenerateQuestions(t *testing.T) {
+	fake := llm.NewFake(32, "```json\n{\"questions\":[{\"question\":\"What is 2+2?\",\"answer\":\"4\"}]}\n```")
+	handler, _ := newTestHandler(fake)
+
+	w := serve(handler.GenerateQuestions, QuestionInput{
+		Id:       "0c1b6f7e-2d36-4f0b-9d1a-1f0f9a7f8b11",
+		Num:      1,
+		Subjects: []string{"arithmetic"},
+	})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data models.Test `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data.Questions) != 1 || resp.Data.Questions[0].Answer != "4" {
+		t.Errorf("unexpected questions: %+v", resp.Data.Questions)
+	}
+
+	if n := len(generationRequests(fake)); n != 1 {
+		t.Errorf("expected a single generation request, got %d", n)
+	}
+}
+
+// generationRequests leaves out the requests checking the generated questions.
+func generationRequests(fake *llm.Fake) []llm.ChatRequest {
+	requests := []llm.ChatRequest{}
+	for _, req := range fake.Requests() {
+		if strings.HasSuffix(req.Schema.Name, "_questions") {
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
+
+func TestGenerateQuestionsCitesSources(t *testing.T) {
+	fake := llm.NewFake(32, `{"questions":[{"question":"Which organelle produces energy for the cell?","answer":"The mitochondrion"}]}`)
+	docs := &stubRepo{chunks: []models.Chunk{
+		{Id: uuid.New(), Chunk: "The nucleus stores DNA. It is surrounded by a membrane.", PageFrom: 3, PageTo: 3},
+		{Id: uuid.New(), Chunk: "Cells need energy.\nThe mitochondrion   produces energy for the cell. Ribosomes make proteins.", PageFrom: 7, PageTo: 7},
+		{Id: uuid.New(), Chunk: "The mitochondrion produces energy.", Strategy: "sentence"},
+	}}
+	handler := NewHandler(docs, nil, newStubTestRepo(), nil, fake, fake, retrieval.New(docs, fake, chunker.Words{}, retrieval.Options{}), dedup.New(fake, 0), false)
+
+	w := serve(handler.GenerateQuestions, QuestionInput{
+		Id:       "0c1b6f7e-2d36-4f0b-9d1a-1f0f9a7f8b11",
+		Num:      1,
+		Subjects: []string{"cells"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data models.Test `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := resp.Data.Questions[0].Sources
+	want := models.Source{Page: 7, Excerpt: "The mitochondrion produces energy for the cell."}
+	if len(sources) == 0 || sources[0] != want {
+		t.Fatalf("expected the first source to be %+v, got %+v", want, sources)
+	}
+	for _, source := range sources {
+		if source.Page == 0 {
+			t.Errorf("cited a chunk without a page: %+v", source)
+		}
+	}
+}
+
+func TestGenerateMultipleChoiceDropsInvalidItems(t *testing.T) {
+	fake := llm.NewFake(32, `{"questions":[
+		{"question":"Capital of France?","options":["Paris","Lyon","Nice","Lille"],"correct_index":0},
+		{"question":"Capital of Spain?","options":["Madrid","madrid.","Seville"],"correct_index":0}
+	]}`)
+	handler, _ := newTestHandler(fake)
+
+	w := serve(handler.GenerateQuestions, QuestionInput{
+		Id:       "0c1b6f7e-2d36-4f0b-9d1a-1f0f9a7f8b11",
+		Num:      2,
+		Type:     models.MultipleChoice,
+		Subjects: []string{"geography"},
+	})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data models.Test `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data.Questions) != 1 {
+		t.Fatalf("expected the duplicated distractor to be dropped, got %+v", resp.Data.Questions)
+	}
+	if resp.Data.Questions[0].Type != models.MultipleChoice || resp.Data.Questions[0].Answer != "Paris" {
+		t.Errorf("unexpected question: %+v", resp.Data.Questions[0])
+	}
+}
+
+func TestGenerateQuestionMix(t *testing.T) {
+	fake := llm.NewFake(32)
+	fake.Respond = func(req llm.ChatRequest) (string, error) {
+		prompt := req.Messages[len(req.Messages)-1].Content
+		switch {
+		case strings.Contains(prompt, "true or false"):
+			return `{"questions":[{"question":"Water boils at 100C at sea level.","is_true":true},{"question":"Ice is denser than water.","is_true":false}]}`, nil
+		case strings.Contains(prompt, "ordering"):
+			return `{"questions":[{"question":"Order the states by temperature.","sequence":["solid","liquid","gas"]}]}`, nil
+		}
+		return `{"questions":[]}`, nil
+	}
+	handler, _ := newTestHandler(fake)
+
+	w := serve(handler.GenerateQuestions, QuestionInput{
+		Id:       "0c1b6f7e-2d36-4f0b-9d1a-1f0f9a7f8b11",
+		Types:    map[models.QuestionType]int{models.TrueFalse: 1, models.Ordering: 1},
+		Subjects: []string{"states of matter"},
+	})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data models.Test `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data.Questions) != 2 {
+		t.Fatalf("expected one question per requested type, got %+v", resp.Data.Questions)
+	}
+	if resp.Data.Questions[0].Type != models.TrueFalse || resp.Data.Questions[0].Answer != "True" {
+		t.Errorf("unexpected true/false question: %+v", resp.Data.Questions[0])
+	}
+	if resp.Data.Questions[1].Type != models.Ordering || resp.Data.Questions[1].Answer != "solid > liquid > gas" {
+		t.Errorf("unexpected ordering question: %+v", resp.Data.Questions[1])
+	}
+}
+
+func TestGenerateQuestionsFromBlueprint(t *testing.T) {
+	fake := llm.NewFake(32)
+	fake.Respond = func(req llm.ChatRequest) (string, error) {
+		prompt := req.Messages[len(req.Messages)-1].Content
+		switch {
+		case req.Schema.Name == "classifications" && strings.Contains(prompt, "organelle"):
+			return `{"classifications":[
+				{"index":0,"difficulty":"easy","cognitive_level":"remember"},
+				{"index":1,"difficulty":"easy","cognitive_level":"understand"}
+			]}`, nil
+		case req.Schema.Name == "classifications":
+			return `{"classifications":[{"index":0,"difficulty":"hard","cognitive_level":"analyze"}]}`, nil
+		case strings.Contains(prompt, "already asked"):
+			return `{"questions":[]}`, nil
+		case strings.Contains(prompt, "chapter 2") && strings.Contains(prompt, "easy") && strings.Contains(prompt, "remember"):
+			return `{"questions":[
+				{"question":"Which organelle stores DNA?","options":["Nucleus","Ribosome","Vacuole"],"correct_index":0},
+				{"question":"Which organelle makes proteins?","options":["Nucleus","Ribosome","Vacuole"],"correct_index":1}
+			]}`, nil
+		case strings.Contains(prompt, "chapter 5") && strings.Contains(prompt, "hard") && strings.Contains(prompt, "analyze"):
+			return `{"questions":[{"question":"Why do muscle cells have many mitochondria?","answer":"They need a lot of energy"}]}`, nil
+		}
+		return `{"questions":[]}`, nil
+	}
+	handler, _ := newTestHandler(fake)
+
+	w := serve(handler.GenerateQuestions, QuestionInput{
+		Id: "0c1b6f7e-2d36-4f0b-9d1a-1f0f9a7f8b11",
+		Blueprint: []BlueprintCell{
+			{Subject: "chapter 2", Difficulty: models.Easy, Type: models.MultipleChoice, CognitiveLevel: models.Remember, Count: 2},
+			{Subject: "chapter 5", Difficulty: models.Hard, CognitiveLevel: models.Analyze, Count: 2},
+		},
+	})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data GeneratedTest `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(generationRequests(fake)); n != 3 {
+		t.Errorf("expected a prompt per cell and one for the missing question, got %d", n)
+	}
+	if strings.Join(resp.Data.Subjects, ",") != "chapter 2,chapter 5" {
+		t.Errorf("expected the subjects of the blueprint, got %v", resp.Data.Subjects)
+	}
+
+	questions := resp.Data.Questions
+	if len(questions) != 3 {
+		t.Fatalf("expected 3 questions, got %+v", questions)
+	}
+	if questions[0].Difficulty != models.Easy || questions[0].CognitiveLevel != models.Remember || questions[0].Type != models.MultipleChoice {
+		t.Errorf("unexpected first question: %+v", questions[0])
+	}
+	if questions[1].CognitiveLevel != models.Understand {
+		t.Errorf("expected the classifier to correct the level of the second question, got %+v", questions[1])
+	}
+	if questions[2].Difficulty != models.Hard || questions[2].CognitiveLevel != models.Analyze || questions[2].Type != models.ShortAnswer {
+		t.Errorf("unexpected last question: %+v", questions[2])
+	}
+
+	want := []CoverageCell{
+		{Subject: "chapter 2", Difficulty: models.Easy, Type: models.MultipleChoice, CognitiveLevel: models.Remember, Requested: 2, Produced: 1},
+		{Subject: "chapter 5", Difficulty: models.Hard, Type: models.ShortAnswer, CognitiveLevel: models.Analyze, Requested: 2, Produced: 1},
+	}
+	if len(resp.Data.Coverage) != len(want) {
+		t.Fatalf("expected coverage %+v, got %+v", want, resp.Data.Coverage)
+	}
+	for i := range want {
+		if resp.Data.Coverage[i] != want[i] {
+			t.Errorf("expected coverage %+v, got %+v", want[i], resp.Data.Coverage[i])
+		}
+	}
+
+	levels := resp.Data.CognitiveLevels
+	if len(levels) != 3 || levels[models.Remember] != 1 || levels[models.Understand] != 1 || levels[models.Analyze] != 1 {
+		t.Errorf("unexpected Bloom's mix: %v", levels)
+	}
+}
+
+func TestGenerateQuestionsTargetsLevel(t *testing.T) {
+	fake := llm.NewFake(32)
+	fake.Respond = func(req llm.ChatRequest) (string, error) {
+		if req.Schema.Name == "classifications" {
+			return `{"classifications":[{"index":0,"difficulty":"medium","cognitive_level":"apply"}]}`, nil
+		}
+		return `{"questions":[{"question":"A car covers 120 km in 2 hours. What is its average speed?","answer":"60 km/h"}]}`, nil
+	}
+	handler, _ := newTestHandler(fake)
+
+	w := serve(handler.GenerateQuestions, QuestionInput{
+		Id:             "0c1b6f7e-2d36-4f0b-9d1a-1f0f9a7f8b11",
+		Num:            1,
+		Subjects:       []string{"speed"},
+		Difficulty:     models.Medium,
+		CognitiveLevel: models.Apply,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	requests := generationRequests(fake)
+	if len(requests) != 1 {
+		t.Fatalf("expected a single generation request, got %d", len(requests))
+	}
+	system := requests[0].Messages[0].Content
+	if !strings.Contains(system, helpers.MEDIUM_INSTRUCTIONS) || !strings.Contains(system, helpers.APPLY_INSTRUCTIONS) {
+		t.Errorf("expected the prompt to target the level, got %s", system)
+	}
+	if strings.Contains(system, helpers.REMEMBER_INSTRUCTIONS) {
+		t.Errorf("expected only the instructions of the level asked for")
+	}
+
+	var resp struct {
+		Data GeneratedTest `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	q := resp.Data.Questions[0]
+	if q.Difficulty != models.Medium || q.CognitiveLevel != models.Apply {
+		t.Errorf("expected the question to be tagged, got %+v", q)
+	}
+	if resp.Data.CognitiveLevels[models.Apply] != 1 {
+		t.Errorf("unexpected Bloom's mix: %v", resp.Data.CognitiveLevels)
+	}
+}
+
+func TestGenerateQuestionsReplacesDuplicates(t *testing.T) {
+	fake := llm.NewFake(64)
+	fake.Respond = func(req llm.ChatRequest) (string, error) {
+		prompt := req.Messages[len(req.Messages)-1].Content
+		switch {
+		case req.Schema.Name == "classifications":
+			return `{"classifications":[]}`, nil
+		case strings.Contains(prompt, "already asked"):
+			return `{"questions":[{"question":"What is 3 times 3?","answer":"9"}]}`, nil
+		}
+		return `{"questions":[{"question":"What is 2+2?","answer":"4"},{"question":"What is 10 minus 7?","answer":"3"}]}`, nil
+	}
+	handler, _ := newTestHandler(fake)
+	input := QuestionInput{
+		Id:       "0c1b6f7e-2d36-4f0b-9d1a-1f0f9a7f8b11",
+		Num:      1,
+		Subjects: []string{"arithmetic"},
+	}
+
+	if w := serve(handler.GenerateQuestions, input); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	input.Num = 2
+	w := serve(handler.GenerateQuestions, input)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data GeneratedTest `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	questions := resp.Data.Questions
+	if len(questions) != 2 || questions[0].Answer != "3" || questions[1].Answer != "9" {
+		t.Fatalf("expected the saved question to be replaced, got %+v", questions)
+	}
+
+	requests := generationRequests(fake)
+	last := requests[len(requests)-1].Messages[1].Content
+	if !strings.Contains(last, "Please generate a list of 1 ") || !strings.Contains(last, "- What is 2+2?") {
+		t.Errorf("expected a single replacement to be asked for, got %s", last)
+	}
+}
+
+func TestGenerateQuestionsChecksAnswers(t *testing.T) {
+	respond := func(req llm.ChatRequest) (string, error) {
+		prompt := req.Messages[len(req.Messages)-1].Content
+		switch {
+		case req.Schema.Name == "classifications":
+			return `{"classifications":[]}`, nil
+		case req.Schema.Name == "verdicts" && strings.Contains(prompt, "Mars"):
+			return `{"verdicts":[{"index":0,"grounding":"supported"}]}`, nil
+		case req.Schema.Name == "verdicts":
+			return `{"verdicts":[
+				{"index":0,"grounding":"supported"},
+				{"index":1,"grounding":"unsupported","reason":"the context does not mention the Moon"},
+				{"index":2,"grounding":"partially_supported"}
+			]}`, nil
+		case strings.Contains(prompt, "already asked"):
+			return `{"questions":[{"question":"Which planet is known as the red planet?","answer":"Mars"}]}`, nil
+		}
+		return `{"questions":[
+			{"question":"Which planet is closest to the Sun?","answer":"Mercury"},
+			{"question":"How far away is the Moon?","answer":"384,400 km"},
+			{"question":"Why is Venus so hot?","answer":"Its thick atmosphere traps heat"}
+		]}`, nil
+	}
+	input := QuestionInput{
+		Id:       "0c1b6f7e-2d36-4f0b-9d1a-1f0f9a7f8b11",
+		Num:      3,
+		Subjects: []string{"planets"},
+	}
+
+	generate := func(flag bool) []models.Question {
+		t.Helper()
+		fake := llm.NewFake(64)
+		fake.Respond = respond
+		docs := &stubRepo{}
+		handler := NewHandler(docs, nil, newStubTestRepo(), nil, fake, fake, retrieval.New(docs, fake, chunker.Words{}, retrieval.Options{}), dedup.New(fake, 0), flag)
+
+		w := serve(handler.GenerateQuestions, input)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Data GeneratedTest `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Data.Questions
+	}
+
+	questions := generate(false)
+	want := []struct {
+		answer    string
+		grounding models.Grounding
+	}{
+		{"Mercury", models.Supported},
+		{"Its thick atmosphere traps heat", models.PartiallySupported},
+		{"Mars", models.Supported},
+	}
+	if len(questions) != len(want) {
+		t.Fatalf("expected the unsupported answer to be replaced, got %+v", questions)
+	}
+	for i, w := range want {
+		if questions[i].Answer != w.answer || questions[i].Grounding != w.grounding {
+			t.Errorf("question %d: expected %q %s, got %q %s", i, w.answer, w.grounding, questions[i].Answer, questions[i].Grounding)
+		}
+	}
+
+	questions = generate(true)
+	if len(questions) != 3 || questions[1].Grounding != models.Unsupported {
+		t.Errorf("expected the unsupported answer to be flagged, got %+v", questions)
+	}
+}
+
+func TestGenerateQuestionsFromRetrievedContext(t *testing.T) {
+	fake := llm.NewFake(64)
+	fake.Respond = func(req llm.ChatRequest) (string, error) {
+		if strings.HasSuffix(req.Schema.Name, "_questions") {
+			return `{"questions":[{"question":"What do mitochondria produce?","answer":"Energy","citations":[1]}]}`, nil
+		}
+		return `{}`, nil
+	}
+
+	texts := []string{
+		"The French Revolution began in 1789. It ended the monarchy.",
+		"Ribosomes build proteins. Mitochondria produce energy for the cell through respiration.",
+		"Ribosomes read messenger RNA.",
+	}
+	pages := []int{40, 4, 5}
+	embeddings, _ := fake.Embed(context.Background(), texts)
+	documentId := uuid.MustParse("0c1b6f7e-2d36-4f0b-9d1a-1f0f9a7f8b11")
+	docs, _ := repository.NewMemoryRepo(repository.InnerProduct, "")
+	docs.InsertDocument(context.Background(), models.Document{Id: documentId})
+	for i, text := range texts {
+		docs.InsertChunks(context.Background(), []models.Chunk{{
+			Id: uuid.New(), DocumentId: documentId, Chunk: text, ChunkEmbedding: embeddings[i], PageFrom: pages[i], PageTo: pages[i], Heading: "Cells",
+		}})
+	}
+	retriever := retrieval.New(docs, fake, chunker.Words{}, retrieval.Options{PerSubject: 1})
+	handler := NewHandler(docs, nil, newStubTestRepo(), nil, fake, fake, retriever, dedup.New(fake, 0), false)
+
+	w := serve(handler.GenerateQuestions, QuestionInput{
+		Id:       documentId.String(),
+		Num:      1,
+		Subjects: []string{"mitochondria energy"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	system := generationRequests(fake)[0].Messages[0].Content
+	if !strings.Contains(system, "<context>\n[1] (page 4, Cells)\nRibosomes build proteins. Mitochondria produce energy") {
+		t.Errorf("expected the retrieved chunk to be numbered in the context, got %s", system)
+	}
+	if strings.Contains(system, "French Revolution") || strings.Contains(system, "messenger RNA") {
+		t.Errorf("expected only the relevant chunk in the context, got %s", system)
+	}
+
+	var resp struct {
+		Data GeneratedTest `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	want := models.Source{Page: 4, Excerpt: "Mitochondria produce energy for the cell through respiration."}
+	if sources := resp.Data.Questions[0].Sources; len(sources) != 1 || sources[0] != want {
+		t.Errorf("expected the cited chunk as the source, got %+v", sources)
+	}
+}
+
+func TestGenerateQuestionsReportsUnparseableOutput(t *testing.T) {
+	fake := llm.NewFake(32, "I'm sorry, I can't help with that.")
+	handler, _ := newTestHandler(fake)
+
+	w := serve(handler.GenerateQuestions, QuestionInput{
+		Id:       "0c1b6f7e-2d36-4f0b-9d1a-1f0f9a7f8b11",
+		Num:      1,
+		Subjects: []string{"arithmetic"},
+	})
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(fake.Requests()) != llm.DefaultAttempts {
+		t.Errorf("expected %d attempts, got %d", llm.DefaultAttempts, len(fake.Requests()))
+	}
+}
+
+func TestEmbedMissingDocument(t *testing.T) {
+	fake := llm.NewFake(32)
+	docs, _ := repository.NewMemoryRepo(repository.Cosine, "")
+	handler := NewHandler(docs, docs, docs, nil, fake, fake, retrieval.New(docs, fake, chunker.Words{}, retrieval.Options{}), dedup.New(fake, 0), false)
+
+	w := serve(handler.EmbedPages, PagesInput{
+		Id:         uuid.NewString(),
+		Selections: []Selection{{From: 1, To: 1}},
+	})
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestGenerateQuestionsForMissingDocument(t *testing.T) {
+	fake := llm.NewFake(32, "{\"questions\":[{\"question\":\"What is 2+2?\",\"answer\":\"4\"}]}")
+	handler, tests := newTestHandler(fake)
+	handler.docuRepo.(*stubRepo).missing = true
+
+	w := serve(handler.GenerateQuestions, QuestionInput{
+		Id:       "0c1b6f7e-2d36-4f0b-9d1a-1f0f9a7f8b11",
+		Num:      1,
+		Subjects: []string{"arithmetic"},
+	})
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(fake.Requests()) != 0 {
+		t.Errorf("expected no model calls before the document is found, got %d", len(fake.Requests()))
+	}
+	if len(tests.tests) != 0 {
+		t.Errorf("expected no test to be saved, got %d", len(tests.tests))
+	}
+}
+
+func TestQuestionInputBounds(t *testing.T) {
+	id := "0c1b6f7e-2d36-4f0b-9d1a-1f0f9a7f8b11"
+	cells := make([]BlueprintCell, 21)
+	for i := range cells {
+		cells[i] = BlueprintCell{Subject: "cells", Count: 1}
+	}
+
+	for name, input := range map[string]QuestionInput{
+		"num":       {Id: id, Num: 51, Subjects: []string{"cells"}},
+		"types":     {Id: id, Types: map[models.QuestionType]int{models.Matching: 51}, Subjects: []string{"cells"}},
+		"blueprint": {Id: id, Blueprint: cells},
+	} {
+		if err := validator.Validate(input); err == nil {
+			t.Errorf("expected too large a %s to be rejected", name)
+		}
+	}
+
+	if err := validator.Validate(QuestionInput{Id: id, Num: 50, Blueprint: cells[:20]}); err != nil {
+		t.Errorf("expected the largest request to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bjorndonald/test-maker-service/internal/helpers"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Get job status
+//
+// @Summary Get job status
+// @Description Report the progress, error and result of an analyze or embed job
+// @Tags Jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} JobResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /jobs/{id} [get]
+func (a *Handler) GetJob(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		helpers.ReturnError(c, "Error parsing job ID", err, http.StatusBadRequest)
+		return
+	}
+
+	job, err := a.jobRepo.RetrieveJob(c, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ReturnError(c, "Job not found", err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+		return
+	}
+
+	status := JobStatus{
+		Id:         job.Id.String(),
+		Kind:       job.Kind,
+		Status:     job.Status,
+		PagesDone:  job.PagesDone,
+		PagesTotal: job.PagesTotal,
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt,
+		UpdatedAt:  job.UpdatedAt,
+	}
+	if len(job.Result) > 0 {
+		status.Result = json.RawMessage(job.Result)
+	}
+
+	helpers.ReturnJSON(c, "Job retrieved succesfully", status, http.StatusOK)
+}
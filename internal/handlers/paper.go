@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/bjorndonald/test-maker-service/internal/helpers"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/paper"
+	"github.com/gin-gonic/gin"
+)
+
+type PaperInput struct {
+	FontSize int    `form:"font_size" validate:"omitempty,min=8,max=16"`
+	Columns  int    `form:"columns" validate:"omitempty,oneof=1 2"`
+	Logo     string `form:"logo"`
+}
+
+type UploadedLogo struct {
+	Logo string `json:"logo"`
+}
+
+type LogoResponse struct {
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Data    UploadedLogo `json:"data"`
+}
+
+// Upload logo
+//
+// @Summary Upload logo
+// @Description Upload a school logo to print on test papers
+// @Tags Tests
+// @Accept multipart/form-data
+// @Produce json
+// @Param logo formData file true "PNG or JPEG image"
+// @Success 201 {object} LogoResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /logos [post]
+func (a *Handler) UploadLogo(c *gin.Context) {
+	logo, exists := c.Get("logo")
+	if !exists {
+		helpers.ReturnError(c, "Logo is required", errors.New("logo is required"), http.StatusBadRequest)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Logo uploaded succesfully", UploadedLogo{Logo: logo.(string)}, http.StatusCreated)
+}
+
+// Test paper
+//
+// @Summary Test paper
+// @Description Download a saved test as a printable PDF exam paper
+// @Tags Tests
+// @Produce application/pdf
+// @Param id path string true "Test ID"
+// @Param font_size query int false "Font size of the questions, 8 to 16"
+// @Param columns query int false "1 or 2 columns"
+// @Param logo query string false "Uploaded logo"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tests/{id}/pdf [get]
+func (a *Handler) TestPaper(c *gin.Context) {
+	a.renderPaper(c, paper.Exam, "")
+}
+
+// Answer key
+//
+// @Summary Answer key
+// @Description Download the answer key of a saved test as a PDF
+// @Tags Tests
+// @Produce application/pdf
+// @Param id path string true "Test ID"
+// @Param font_size query int false "Font size of the answers, 8 to 16"
+// @Param columns query int false "1 or 2 columns"
+// @Param logo query string false "Uploaded logo"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tests/{id}/pdf/answer-key [get]
+func (a *Handler) AnswerKeyPaper(c *gin.Context) {
+	a.renderPaper(c, paper.AnswerKey, "-answer-key")
+}
+
+func (a *Handler) renderPaper(c *gin.Context, render func(io.Writer, models.Test, paper.Options) error, suffix string) {
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+	if !exists {
+		helpers.ReturnError(c, "Something went wrong", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(PaperInput)
+	if !ok {
+		helpers.ReturnError(c, "Something went wrong", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	options := paper.Options{FontSize: input.FontSize, Columns: input.Columns}
+	if input.Logo != "" {
+		options.Logo = filepath.Join(helpers.LOGO_DIRECTORY, filepath.Base(input.Logo))
+		if _, err := os.Stat(options.Logo); err != nil {
+			helpers.ReturnError(c, "Logo not found", err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	test, ok := a.loadTest(c)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := render(&buf, test, options); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s%s.pdf"`, test.Id, suffix))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bjorndonald/test-maker-service/internal/helpers"
+	"github.com/bjorndonald/test-maker-service/internal/highlight"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Sources paper
+//
+// @Summary Sources paper
+// @Description Download the document of a saved test with the source of each question highlighted and a note giving the question number
+// @Tags Tests
+// @Produce application/pdf
+// @Param id path string true "Test ID"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tests/{id}/sources.pdf [get]
+func (a *Handler) SourcesPaper(c *gin.Context) {
+	test, ok := a.loadTest(c)
+	if !ok {
+		return
+	}
+
+	doc, err := a.docuRepo.RetrieveDocument(c, test.DocumentId.String())
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ReturnError(c, "Document not found", err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+		return
+	}
+	if _, err := os.Stat(doc.Url); err != nil {
+		helpers.ReturnError(c, "Document not found", err, http.StatusNotFound)
+		return
+	}
+
+	var buf bytes.Buffer
+	if _, err := highlight.Write(&buf, doc.Url, sourceMarks(test.Questions)); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-sources.pdf"`, test.Id))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// sourceMarks turns the sources of the questions into highlights, noting on
+// each passage the numbers of every question that came from it.
+func sourceMarks(questions []models.Question) []highlight.Mark {
+	marks := []highlight.Mark{}
+	numbers := map[models.Source][]string{}
+	for _, q := range questions {
+		for _, source := range q.Sources {
+			if _, ok := numbers[source]; !ok {
+				marks = append(marks, highlight.Mark{Page: source.Page, Text: source.Excerpt})
+			}
+			numbers[source] = append(numbers[source], strconv.Itoa(q.Position))
+		}
+	}
+
+	for i, mark := range marks {
+		positions := numbers[models.Source{Page: mark.Page, Excerpt: mark.Text}]
+		marks[i].Note = "Question " + positions[0]
+		if len(positions) > 1 {
+			marks[i].Note = "Questions " + strings.Join(positions, ", ")
+		}
+	}
+	return marks
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bjorndonald/test-maker-service/internal/helpers"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ReorderInput struct {
+	Ids []string `json:"ids" validate:"required,min=1,dive,uuid"`
+}
+
+// List tests
+//
+// @Summary List tests
+// @Description List saved tests, optionally only those of one document
+// @Tags Tests
+// @Produce json
+// @Param document query string false "Document ID"
+// @Success 200 {object} TestsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tests [get]
+func (a *Handler) ListTests(c *gin.Context) {
+	document := c.Query("document")
+	if document != "" {
+		if _, err := uuid.Parse(document); err != nil {
+			helpers.ReturnError(c, "Error parsing document ID", err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	tests, err := a.testRepo.ListTests(c, document)
+	if err != nil {
+		helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Tests retrieved succesfully", tests, http.StatusOK)
+}
+
+// Get test
+//
+// @Summary Get test
+// @Description Fetch a saved test with its questions in order
+// @Tags Tests
+// @Produce json
+// @Param id path string true "Test ID"
+// @Success 200 {object} TestResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tests/{id} [get]
+func (a *Handler) GetTest(c *gin.Context) {
+	test, ok := a.loadTest(c)
+	if !ok {
+		return
+	}
+
+	helpers.ReturnJSON(c, "Test retrieved succesfully", test, http.StatusOK)
+}
+
+// Edit question
+//
+// @Summary Edit question
+// @Description Replace a question of a saved test, keeping its position
+// @Tags Tests
+// @Accept json
+// @Produce json
+// @Param id path string true "Test ID"
+// @Param questionId path string true "Question ID"
+// @Param question body models.Question true "Question"
+// @Success 200 {object} QuestionResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tests/{id}/questions/{questionId} [put]
+func (a *Handler) UpdateQuestion(c *gin.Context) {
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+	if !exists {
+		helpers.ReturnError(c, "Something went wrong", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	question, ok := validatedReqBody.(models.Question)
+	if !ok {
+		helpers.ReturnError(c, "Something went wrong", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	existing, ok := a.loadQuestion(c)
+	if !ok {
+		return
+	}
+
+	question.Id = existing.Id
+	question.Position = existing.Position
+	question.Normalize()
+	if err := question.Validate(); err != nil {
+		helpers.ReturnError(c, "Error validating input", err, http.StatusBadRequest)
+		return
+	}
+
+	if err := a.testRepo.UpdateQuestion(c, c.Param("id"), question); err != nil {
+		helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Question updated succesfully", question, http.StatusOK)
+}
+
+// Delete question
+//
+// @Summary Delete question
+// @Description Remove a question from a saved test
+// @Tags Tests
+// @Produce json
+// @Param id path string true "Test ID"
+// @Param questionId path string true "Question ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tests/{id}/questions/{questionId} [delete]
+func (a *Handler) DeleteQuestion(c *gin.Context) {
+	if _, ok := a.loadQuestion(c); !ok {
+		return
+	}
+
+	if err := a.testRepo.DeleteQuestion(c, c.Param("id"), c.Param("questionId")); err != nil {
+		helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Question deleted succesfully", nil, http.StatusOK)
+}
+
+// Reorder questions
+//
+// @Summary Reorder questions
+// @Description Set the order of the questions of a saved test. Every question must be listed once.
+// @Tags Tests
+// @Accept json
+// @Produce json
+// @Param id path string true "Test ID"
+// @Param order body ReorderInput true "Question IDs in their new order"
+// @Success 200 {object} TestResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tests/{id}/order [put]
+func (a *Handler) ReorderQuestions(c *gin.Context) {
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+	if !exists {
+		helpers.ReturnError(c, "Something went wrong", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(ReorderInput)
+	if !ok {
+		helpers.ReturnError(c, "Something went wrong", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := a.loadTest(c); !ok {
+		return
+	}
+
+	ids := make([]string, 0, len(input.Ids))
+	for _, id := range input.Ids {
+		ids = append(ids, uuid.MustParse(id).String())
+	}
+
+	err := a.testRepo.ReorderQuestions(c, c.Param("id"), ids)
+	if errors.Is(err, repository.ErrQuestionSetMismatch) {
+		helpers.ReturnError(c, "Error validating input", err, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+		return
+	}
+
+	test, ok := a.loadTest(c)
+	if !ok {
+		return
+	}
+
+	helpers.ReturnJSON(c, "Questions reordered succesfully", test, http.StatusOK)
+}
+
+// Regenerate question
+//
+// @Summary Regenerate question
+// @Description Replace a question with a newly generated one of the same type
+// @Tags Tests
+// @Produce json
+// @Param id path string true "Test ID"
+// @Param questionId path string true "Question ID"
+// @Success 200 {object} QuestionResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tests/{id}/questions/{questionId}/regenerate [post]
+func (a *Handler) RegenerateQuestion(c *gin.Context) {
+	test, ok := a.loadTest(c)
+	if !ok {
+		return
+	}
+
+	existing, ok := a.loadQuestion(c)
+	if !ok {
+		return
+	}
+
+	chunks, err := a.retrieveContext(c, test.DocumentId.String(), test.Subjects)
+	if err != nil {
+		helpers.ReturnError(c, "Search error", err, http.StatusInternalServerError)
+		return
+	}
+
+	seen, err := a.documentQuestions(c, test.DocumentId.String())
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	instructions := "Do not repeat these questions:"
+	for _, q := range test.Questions {
+		instructions += "\n- " + q.Question
+	}
+
+	generated, err := a.generateType(c, seen, existing.Type, 1, test.Subjects, chunks, existing.Difficulty, existing.CognitiveLevel, instructions)
+	if err != nil {
+		returnGenerationError(c, err)
+		return
+	}
+	if len(generated) == 0 {
+		helpers.ReturnError(c, "The model did not return valid questions", errors.New("no replacement question was generated"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	question := generated[0]
+	question.Id = existing.Id
+	question.Position = existing.Position
+	if err := a.testRepo.UpdateQuestion(c, test.Id.String(), question); err != nil {
+		helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Question regenerated succesfully", question, http.StatusOK)
+}
+
+// loadTest fetches the test named by the id path parameter with its
+// questions, writing the error response itself when it can't.
+func (a *Handler) loadTest(c *gin.Context) (models.Test, bool) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		helpers.ReturnError(c, "Error parsing test ID", err, http.StatusBadRequest)
+		return models.Test{}, false
+	}
+
+	test, err := a.testRepo.RetrieveTest(c, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ReturnError(c, "Test not found", err, http.StatusNotFound)
+		return test, false
+	}
+	if err != nil {
+		helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+		return test, false
+	}
+
+	test.Questions, err = a.testRepo.RetrieveQuestions(c, id)
+	if err != nil {
+		helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+		return test, false
+	}
+
+	return test, true
+}
+
+// loadQuestion fetches the question named by the id and questionId path
+// parameters, writing the error response itself when it can't.
+func (a *Handler) loadQuestion(c *gin.Context) (models.Question, bool) {
+	for _, param := range []string{"id", "questionId"} {
+		if _, err := uuid.Parse(c.Param(param)); err != nil {
+			helpers.ReturnError(c, "Error parsing "+param, err, http.StatusBadRequest)
+			return models.Question{}, false
+		}
+	}
+
+	question, err := a.testRepo.RetrieveQuestion(c, c.Param("id"), c.Param("questionId"))
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ReturnError(c, "Question not found", err, http.StatusNotFound)
+		return question, false
+	}
+	if err != nil {
+		helpers.ReturnError(c, "Issue assessing database", err, http.StatusInternalServerError)
+		return question, false
+	}
+
+	return question, true
+}
@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bjorndonald/test-maker-service/internal/llm"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/paper"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func request(method string, route string, path string, handler gin.HandlerFunc, body interface{}) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, route, func(c *gin.Context) {
+		if body != nil {
+			c.Set("validatedRequestBody", body)
+		}
+		handler(c)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func savedTest(t *testing.T, tests *stubTestRepo, questions ...string) models.Test {
+	t.Helper()
+	test := models.Test{Id: uuid.New(), DocumentId: uuid.New(), Title: "Biology", Subjects: []string{"cells"}}
+	tests.InsertTest(context.Background(), test)
+
+	items := []models.Question{}
+	for _, q := range questions {
+		items = append(items, models.Question{Type: models.ShortAnswer, Question: q, Answer: "answer"})
+	}
+	saved, _ := tests.InsertQuestions(context.Background(), test.Id.String(), items)
+	test.Questions = saved
+	return test
+}
+
+func TestReorderQuestions(t *testing.T) {
+	handler, tests := newTestHandler(llm.NewFake(8))
+	test := savedTest(t, tests, "first", "second", "third")
+	ids := []string{test.Questions[2].Id.String(), test.Questions[0].Id.String(), test.Questions[1].Id.String()}
+
+	w := request(http.MethodPut, "/tests/:id/order", "/tests/"+test.Id.String()+"/order", handler.ReorderQuestions, ReorderInput{Ids: ids})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data models.Test `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	got := []string{}
+	for _, q := range resp.Data.Questions {
+		got = append(got, q.Question)
+	}
+	if len(got) != 3 || got[0] != "third" || got[1] != "first" || got[2] != "second" {
+		t.Errorf("unexpected order %v", got)
+	}
+
+	w = request(http.MethodPut, "/tests/:id/order", "/tests/"+test.Id.String()+"/order", handler.ReorderQuestions, ReorderInput{Ids: ids[:2]})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected a partial order to be rejected, got %d", w.Code)
+	}
+}
+
+func TestUpdateQuestionValidatesContent(t *testing.T) {
+	handler, tests := newTestHandler(llm.NewFake(8))
+	test := savedTest(t, tests, "first")
+	path := "/tests/" + test.Id.String() + "/questions/" + test.Questions[0].Id.String()
+
+	invalid := models.Question{Type: models.MultipleChoice, Question: "2+2?", Options: []string{"4", "4", "5"}, CorrectIndex: new(int)}
+	w := request(http.MethodPut, "/tests/:id/questions/:questionId", path, handler.UpdateQuestion, invalid)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	valid := models.Question{Type: models.TrueFalse, Question: "Cells have walls.", IsTrue: new(bool)}
+	w = request(http.MethodPut, "/tests/:id/questions/:questionId", path, handler.UpdateQuestion, valid)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	saved, _ := tests.RetrieveQuestion(context.Background(), test.Id.String(), test.Questions[0].Id.String())
+	if saved.Type != models.TrueFalse || saved.Answer != "False" || saved.Position != 1 {
+		t.Errorf("unexpected saved question %+v", saved)
+	}
+}
+
+func TestDeleteQuestion(t *testing.T) {
+	handler, tests := newTestHandler(llm.NewFake(8))
+	test := savedTest(t, tests, "first", "second")
+	path := "/tests/" + test.Id.String() + "/questions/" + test.Questions[0].Id.String()
+
+	w := request(http.MethodDelete, "/tests/:id/questions/:questionId", path, handler.DeleteQuestion, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = request(http.MethodDelete, "/tests/:id/questions/:questionId", path, handler.DeleteQuestion, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected deleting twice to 404, got %d", w.Code)
+	}
+
+	remaining, _ := tests.RetrieveQuestions(context.Background(), test.Id.String())
+	if len(remaining) != 1 || remaining[0].Question != "second" || remaining[0].Position != 1 {
+		t.Errorf("unexpected remaining questions %+v", remaining)
+	}
+}
+
+func TestRegenerateQuestion(t *testing.T) {
+	fake := llm.NewFake(8, `{"questions":[{"question":"What do mitochondria make?","answer":"ATP"}]}`)
+	handler, tests := newTestHandler(fake)
+	test := savedTest(t, tests, "first", "second")
+	path := "/tests/" + test.Id.String() + "/questions/" + test.Questions[1].Id.String() + "/regenerate"
+
+	w := request(http.MethodPost, "/tests/:id/questions/:questionId/regenerate", path, handler.RegenerateQuestion, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	saved, _ := tests.RetrieveQuestion(context.Background(), test.Id.String(), test.Questions[1].Id.String())
+	if saved.Question != "What do mitochondria make?" || saved.Position != 2 {
+		t.Errorf("unexpected regenerated question %+v", saved)
+	}
+}
+
+func TestGetMissingTest(t *testing.T) {
+	handler, _ := newTestHandler(llm.NewFake(8))
+
+	w := request(http.MethodGet, "/tests/:id", "/tests/"+uuid.NewString(), handler.GetTest, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestExportTest(t *testing.T) {
+	handler, tests := newTestHandler(llm.NewFake(8))
+	test := savedTest(t, tests, "What is a cell?")
+
+	w := request(http.MethodGet, "/tests/:id/export", "/tests/"+test.Id.String()+"/export?format=gift", handler.ExportTest, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "::Q1:: What is a cell? {=answer}") {
+		t.Errorf("unexpected export:\n%s", w.Body.String())
+	}
+
+	w = request(http.MethodGet, "/tests/:id/export", "/tests/"+test.Id.String()+"/export?format=pdf", handler.ExportTest, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown format to be rejected, got %d", w.Code)
+	}
+}
+
+func TestImportTest(t *testing.T) {
+	handler, tests := newTestHandler(llm.NewFake(8))
+	test := savedTest(t, tests, "What is a cell?", "What is an organ?")
+
+	w := request(http.MethodGet, "/tests/:id/export", "/tests/"+test.Id.String()+"/export?format=qti21", handler.ExportTest, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("document_id", test.DocumentId.String())
+	file, _ := form.CreateFormFile("file", "biology.zip")
+	file.Write(w.Body.Bytes())
+	form.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/tests/import", handler.ImportTest)
+	req := httptest.NewRequest(http.MethodPost, "/tests/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data ImportedTest `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	imported := resp.Data.Test
+	if imported.Id == test.Id || imported.Title != "biology" || len(imported.Questions) != 2 {
+		t.Fatalf("unexpected imported test: %+v", imported)
+	}
+	if imported.Questions[1].Question != "What is an organ?" || imported.Questions[1].Answer != "answer" {
+		t.Errorf("unexpected imported question: %+v", imported.Questions[1])
+	}
+}
+
+// postImport uploads a QTI package to ImportTest with the given form fields.
+func postImport(handler *Handler, pkg []byte, fields map[string]string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	file, _ := form.CreateFormFile("file", "biology.zip")
+	file.Write(pkg)
+	form.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/tests/import", handler.ImportTest)
+	req := httptest.NewRequest(http.MethodPost, "/tests/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestImportTestForMissingDocument(t *testing.T) {
+	handler, tests := newTestHandler(llm.NewFake(8))
+	test := savedTest(t, tests, "What is a cell?")
+	export := request(http.MethodGet, "/tests/:id/export", "/tests/"+test.Id.String()+"/export?format=qti21", handler.ExportTest, nil)
+	handler.docuRepo.(*stubRepo).missing = true
+
+	w := postImport(handler, export.Body.Bytes(), map[string]string{"document_id": uuid.NewString()})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(tests.tests) != 1 {
+		t.Errorf("expected no test to be saved, got %d", len(tests.tests))
+	}
+}
+
+func TestImportTestRejectsInvalidTestId(t *testing.T) {
+	handler, tests := newTestHandler(llm.NewFake(8))
+	test := savedTest(t, tests, "What is a cell?")
+	export := request(http.MethodGet, "/tests/:id/export", "/tests/"+test.Id.String()+"/export?format=qti21", handler.ExportTest, nil)
+
+	w := postImport(handler, export.Body.Bytes(), map[string]string{"test_id": "nope"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestListTests(t *testing.T) {
+	handler, tests := newTestHandler(llm.NewFake(8))
+	test := savedTest(t, tests, "What is a cell?")
+	savedTest(t, tests, "What is a tissue?")
+
+	w := request(http.MethodGet, "/tests", "/tests?document="+test.DocumentId.String(), handler.ListTests, nil)
+	var resp struct {
+		Data []models.Test `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || len(resp.Data) != 1 || resp.Data[0].Id != test.Id {
+		t.Errorf("expected only the test of the document (%d): %s", w.Code, w.Body.String())
+	}
+
+	w = request(http.MethodGet, "/tests", "/tests", handler.ListTests, nil)
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || len(resp.Data) != 2 {
+		t.Errorf("expected every test without a filter (%d): %s", w.Code, w.Body.String())
+	}
+
+	w = request(http.MethodGet, "/tests", "/tests?document=nope", handler.ListTests, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid document ID to be rejected, got %d", w.Code)
+	}
+}
+
+func TestTestPaper(t *testing.T) {
+	handler, tests := newTestHandler(llm.NewFake(8))
+	test := savedTest(t, tests, "What is a cell?")
+
+	w := request(http.MethodGet, "/tests/:id/pdf", "/tests/"+test.Id.String()+"/pdf", handler.TestPaper, PaperInput{Columns: 2})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/pdf" || !bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF")) {
+		t.Errorf("expected a PDF, got %q", w.Header().Get("Content-Type"))
+	}
+
+	w = request(http.MethodGet, "/tests/:id/pdf", "/tests/"+test.Id.String()+"/pdf", handler.AnswerKeyPaper, PaperInput{Logo: "../missing.png"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown logo to be rejected, got %d", w.Code)
+	}
+}
+
+func TestSourcesPaper(t *testing.T) {
+	handler, tests := newTestHandler(llm.NewFake(8))
+	test := savedTest(t, tests, "What is a cell?", "What is a tissue?")
+
+	// The printed test stands in for the document the questions came from
+	var book bytes.Buffer
+	if err := paper.Exam(&book, test, paper.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "book.pdf")
+	if err := os.WriteFile(path, book.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	handler.docuRepo = &stubRepo{document: models.Document{Id: test.DocumentId, Url: path}}
+
+	for i := range test.Questions {
+		test.Questions[i].Sources = []models.Source{{Page: 1, Excerpt: test.Questions[i].Question}}
+		tests.UpdateQuestion(context.Background(), test.Id.String(), test.Questions[i])
+	}
+
+	w := request(http.MethodGet, "/tests/:id/sources.pdf", "/tests/"+test.Id.String()+"/sources.pdf", handler.SourcesPaper, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	annotations, err := api.Annotations(bytes.NewReader(w.Body.Bytes()), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	highlights := annotations[1][model.AnnHighLight].Map
+	if len(highlights) != 2 {
+		t.Fatalf("expected a highlight per question, got %v", annotations[1])
+	}
+	notes := []string{}
+	for _, highlight := range highlights {
+		notes = append(notes, highlight.ContentString())
+	}
+	sort.Strings(notes)
+	if !strings.Contains(notes[0], "Question 1") || !strings.Contains(notes[1], "Question 2") {
+		t.Errorf("expected each highlight to give its question number, got %q", notes)
+	}
+
+	handler.docuRepo = &stubRepo{document: models.Document{Url: filepath.Join(t.TempDir(), "missing.pdf")}}
+	w = request(http.MethodGet, "/tests/:id/sources.pdf", "/tests/"+test.Id.String()+"/sources.pdf", handler.SourcesPaper, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected a missing document to give 404, got %d", w.Code)
+	}
+}
+
+func TestVariants(t *testing.T) {
+	handler, tests := newTestHandler(llm.NewFake(8))
+	test := savedTest(t, tests, "first", "second", "third", "fourth", "fifth")
+	seed := int64(1234)
+
+	w := request(http.MethodPost, "/tests/:id/variants", "/tests/"+test.Id.String()+"/variants", handler.CreateVariants, VariantsInput{Count: 3, Seed: &seed})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data []GeneratedVariant `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Data) != 3 {
+		t.Fatalf("expected 3 variants, got %d", len(resp.Data))
+	}
+	created := resp.Data[1]
+	if created.Variant.Code != created.AnswerKey.Code || len(created.AnswerKey.Answers) != 5 {
+		t.Fatalf("unexpected variant: %+v", created)
+	}
+
+	w = request(http.MethodGet, "/tests/:id/variants/:code", "/tests/"+test.Id.String()+"/variants/"+created.Variant.Code, handler.DownloadVariant, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), `"answer"`) {
+		t.Errorf("expected the variant to leave out the answers, got %s", w.Body.String())
+	}
+	var downloaded models.Variant
+	json.Unmarshal(w.Body.Bytes(), &downloaded)
+	for i, q := range downloaded.Questions {
+		if q.Question != created.Variant.Questions[i].Question {
+			t.Fatalf("downloaded variant differs from the created one at question %d", i)
+		}
+	}
+
+	w = request(http.MethodGet, "/tests/:id/variants/:code/key", "/tests/"+test.Id.String()+"/variants/"+created.Variant.Code+"/key", handler.DownloadVariantKey, nil)
+	var key models.AnswerKey
+	json.Unmarshal(w.Body.Bytes(), &key)
+	if w.Code != http.StatusOK || len(key.Answers) != 5 || key.Answers[0].QuestionId != created.AnswerKey.Answers[0].QuestionId {
+		t.Errorf("unexpected answer key (%d): %s", w.Code, w.Body.String())
+	}
+
+	w = request(http.MethodGet, "/tests/:id/variants/:code", "/tests/"+test.Id.String()+"/variants/nope", handler.DownloadVariant, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid code to be rejected, got %d", w.Code)
+	}
+
+	edited := tests.questions[test.Id.String()][0]
+	edited.Question = "Reworded"
+	tests.UpdateQuestion(context.Background(), test.Id.String(), edited)
+	w = request(http.MethodGet, "/tests/:id/variants/:code", "/tests/"+test.Id.String()+"/variants/"+created.Variant.Code, handler.DownloadVariant, nil)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected a code made before an edit to be refused, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+
+	"github.com/bjorndonald/test-maker-service/internal/helpers"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/variants"
+	"github.com/gin-gonic/gin"
+)
+
+type VariantsInput struct {
+	Count int    `json:"count" validate:"required,min=1,max=26"`
+	Seed  *int64 `json:"seed" validate:"omitempty,min=0"`
+}
+
+type GeneratedVariant struct {
+	Variant   models.Variant   `json:"variant"`
+	AnswerKey models.AnswerKey `json:"answer_key"`
+}
+
+type VariantsResponse struct {
+	Success bool               `json:"success"`
+	Message string             `json:"message"`
+	Data    []GeneratedVariant `json:"data"`
+}
+
+// Create variants
+//
+// @Summary Create variants
+// @Description Shuffle a saved test into variants, each with its own answer key. The same seed always gives the same variants
+// @Tags Tests
+// @Accept json
+// @Produce json
+// @Param id path string true "Test ID"
+// @Param variants body VariantsInput true "Number of variants and optional seed"
+// @Success 200 {object} VariantsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tests/{id}/variants [post]
+func (a *Handler) CreateVariants(c *gin.Context) {
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+	if !exists {
+		helpers.ReturnError(c, "Something went wrong", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(VariantsInput)
+	if !ok {
+		helpers.ReturnError(c, "Something went wrong", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	test, ok := a.loadTest(c)
+	if !ok {
+		return
+	}
+
+	seed := rand.Int63n(variants.MaxSeed)
+	if input.Seed != nil {
+		seed = *input.Seed
+	}
+
+	generated := []GeneratedVariant{}
+	for i := 0; i < input.Count; i++ {
+		variant, key := variants.Build(test, i, seed)
+		generated = append(generated, GeneratedVariant{Variant: variant, AnswerKey: key})
+	}
+
+	helpers.ReturnJSON(c, "Variants created succesfully", generated, http.StatusOK)
+}
+
+// Download variant
+//
+// @Summary Download variant
+// @Description Download a variant of a saved test as JSON
+// @Tags Tests
+// @Produce json
+// @Param id path string true "Test ID"
+// @Param code path string true "Variant code"
+// @Success 200 {object} models.Variant
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tests/{id}/variants/{code} [get]
+func (a *Handler) DownloadVariant(c *gin.Context) {
+	variant, _, ok := a.loadVariant(c)
+	if !ok {
+		return
+	}
+
+	downloadJSON(c, variant.Code, variant)
+}
+
+// Download variant answer key
+//
+// @Summary Download variant answer key
+// @Description Download the answer key of a variant of a saved test as JSON
+// @Tags Tests
+// @Produce json
+// @Param id path string true "Test ID"
+// @Param code path string true "Variant code"
+// @Success 200 {object} models.AnswerKey
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tests/{id}/variants/{code}/key [get]
+func (a *Handler) DownloadVariantKey(c *gin.Context) {
+	_, key, ok := a.loadVariant(c)
+	if !ok {
+		return
+	}
+
+	downloadJSON(c, key.Code+"-key", key)
+}
+
+// loadVariant rebuilds the variant named by the code path parameter and its
+// answer key. Codes made before the test was edited are refused, since the
+// rebuilt variant would no longer match the printed paper.
+func (a *Handler) loadVariant(c *gin.Context) (models.Variant, models.AnswerKey, bool) {
+	if _, _, _, err := variants.ParseCode(c.Param("code")); err != nil {
+		helpers.ReturnError(c, "Error validating input", err, http.StatusBadRequest)
+		return models.Variant{}, models.AnswerKey{}, false
+	}
+
+	test, ok := a.loadTest(c)
+	if !ok {
+		return models.Variant{}, models.AnswerKey{}, false
+	}
+
+	variant, key, err := variants.Rebuild(test, c.Param("code"))
+	if err != nil {
+		helpers.ReturnError(c, "Variant is out of date", err, http.StatusConflict)
+		return models.Variant{}, models.AnswerKey{}, false
+	}
+	return variant, key, true
+}
+
+func downloadJSON(c *gin.Context, name string, data interface{}) {
+	body, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, name))
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
@@ -3,22 +3,97 @@ package helpers
 const (
 	INVALID_REQUEST_BODY     = "invalid request body"
 	REQUEST_BODY_PARSE_ERROR = "request body parse error"
+	LOGO_DIRECTORY           = "assets/logos"
+	MAX_SOURCES              = 2
+	MAX_EXCERPT_LENGTH       = 300
+	MAX_GENERATION_ROUNDS    = 3
 	RESPONSE_SYSTEM_TEMPLATE = `You are an experienced teacher, expert at creating exam questions based on a particular curriculum.
 Generate a list of concise question which will adequately test a student based solely on the provided search results. You must only use information from the provided search results. It can be a question about anything in the context. Use an unbiased and academic tone. Combine search results together into a coherent list of questions for someone to answer.
 
-If there is nothing in the context that can be made into a test question, just return an empty array. Don't try to make up a question. Make sure the response is an array of objects.
-Anything between the following \"context\" html blocks is retrieved from a knowledge bank, not part of the conversation with the user.
+If there is nothing in the context that can be made into a test question, just return an empty questions array. Don't try to make up a question.
+Anything between the following \"context\" html blocks is retrieved from a knowledge bank, not part of the conversation with the user. Each passage of the context starts with its number in square brackets and the pages it comes from.
 <context>
 %s
 <context/>
 
 Format result instructions:
-\n%s\n
+Respond with a JSON object matching this JSON schema:
+%s
+
+%s
 
-Also generate a correct answer to the question. Do not repeat text. Please make sure the response is in the format of an array of objects with a question property and answer property. Please only return the formatted response nothing else.
+Give each question a one sentence feedback explaining why the answer is correct, and list in citations the numbers of the passages the question and its answer come from. Do not repeat text. Please only return the formatted response nothing else.
 `
 
-	FORMAT_INSTRUCTIONS = `"""json
-{'questions':{'type':'array','items':{'type':'object','properties':{'question':{'type':'string'},'answer':{'type':'string'}}
-"""`
+	FORMAT_INSTRUCTIONS = `Also generate a correct answer to the question. Please make sure each question has a question property and answer property.`
+
+	MULTIPLE_CHOICE_FORMAT_INSTRUCTIONS = `Each question is multiple choice with between %d and %d options. Exactly one option is correct and correct_index is its zero-based position in options. The other options are plausible distractors: each must be wrong, and no two options may say the same thing or restate the correct answer.`
+
+	TRUE_FALSE_FORMAT_INSTRUCTIONS = `Each question is a single statement that is either true or false according to the context. Set is_true to whether the statement is true. Mix true and false statements, and do not make a false statement by simply adding "not".`
+
+	FILL_IN_THE_BLANK_FORMAT_INSTRUCTIONS = `Each question is a sentence from the context with one or more key terms replaced by ___ (three underscores). blanks lists the missing terms in the order the gaps appear, with exactly one entry per ___ in the question.`
+
+	MATCHING_FORMAT_INSTRUCTIONS = `Each question is an instruction such as "Match each term to its definition" followed by between %d and %d pairs. Every left value and every right value must be unique so there is only one correct way to match them.`
+
+	ORDERING_FORMAT_INSTRUCTIONS = `Each question asks the student to put between %d and %d steps, events or stages in order. sequence lists them in the correct order, and every item must be unique.`
+
+	EASY_INSTRUCTIONS = `Make the questions easy: a student who has read the context once should be able to answer them, and each answer should be stated plainly in the context.`
+
+	MEDIUM_INSTRUCTIONS = `Make the questions of medium difficulty: answering them should take a good understanding of the context, such as connecting two facts or explaining an idea in the student's own words.`
+
+	HARD_INSTRUCTIONS = `Make the questions hard: answering them should take a thorough understanding of the context, such as combining several ideas, working through several steps or telling apart closely related concepts.`
+
+	REMEMBER_INSTRUCTIONS = `Every question should test the remember level of Bloom's taxonomy: ask the student to recall or recognise facts, terms and definitions as the context gives them.`
+
+	UNDERSTAND_INSTRUCTIONS = `Every question should test the understand level of Bloom's taxonomy: ask the student to explain, summarise, classify or compare ideas from the context rather than repeat them.`
+
+	APPLY_INSTRUCTIONS = `Every question should test the apply level of Bloom's taxonomy: ask the student to use a rule, method or concept from the context in a new situation, such as a worked problem or a scenario.`
+
+	ANALYZE_INSTRUCTIONS = `Every question should test the analyze level of Bloom's taxonomy: ask the student to break information from the context into parts, find causes, relationships or evidence, or tell what is relevant from what is not.`
+
+	EVALUATE_INSTRUCTIONS = `Every question should test the evaluate level of Bloom's taxonomy: ask the student to judge, justify or critique a claim, method or decision using criteria from the context.`
+
+	CREATE_INSTRUCTIONS = `Every question should test the create level of Bloom's taxonomy: ask the student to combine ideas from the context into something new, such as a plan, a hypothesis or a design.`
+
+	VERIFY_SYSTEM_TEMPLATE = `You are an experienced teacher checking the answer key of an exam against the material it was written from. For every question you are given, decide whether the material supports its answer.
+
+- supported: the material states or directly implies the answer, and the answer is correct.
+- partially_supported: the material supports only part of the answer, or the answer needs knowledge the material does not give.
+- unsupported: the material does not support the answer, or contradicts it.
+
+Judge only by the material between the following "context" html blocks, not by what you know yourself.
+<context>
+%s
+<context/>
+
+Format result instructions:
+Respond with a JSON object matching this JSON schema:
+%s
+
+Give exactly one verdict for every question, using the index it is listed with, and a short reason for any answer that is not supported. Please only return the formatted response nothing else.
+`
+
+	CLASSIFY_SYSTEM_TEMPLATE = `You are an experienced teacher reviewing exam questions. For every question you are given, judge how difficult it is and which level of Bloom's revised taxonomy it tests.
+
+Difficulty:
+- easy: the answer is stated plainly in the material and only needs to be recalled.
+- medium: the answer needs a good understanding, such as connecting two facts.
+- hard: the answer needs several ideas combined, several steps worked through or close concepts told apart.
+
+Bloom's levels:
+- remember: recall or recognise facts, terms and definitions.
+- understand: explain, summarise, classify or compare ideas.
+- apply: use a rule, method or concept in a new situation.
+- analyze: break information into parts and find causes, relationships or evidence.
+- evaluate: judge, justify or critique a claim, method or decision against criteria.
+- create: combine ideas into something new, such as a plan, a hypothesis or a design.
+
+Judge each question by what it asks of the student, not by how it is labelled.
+
+Format result instructions:
+Respond with a JSON object matching this JSON schema:
+%s
+
+Give exactly one classification for every question, using the index it is listed with. Please only return the formatted response nothing else.
+`
 )
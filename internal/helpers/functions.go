@@ -7,15 +7,16 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
 	"strings"
 
-	"github.com/bjorndonald/test-maker-service/constants"
+	"github.com/bjorndonald/test-maker-service/internal/llm"
+	"github.com/bjorndonald/test-maker-service/internal/models"
 	"github.com/dslipak/pdf"
 	"github.com/gin-gonic/gin"
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
-	"github.com/sashabaranov/go-openai"
 )
 
 func ReturnJSON(c *gin.Context, message string, data interface{}, statusCode int) {
@@ -45,11 +46,13 @@ func ExtractPageAsBase64(inputPDF string, pageNum int) (string, error) {
 	}
 	defer file.Close()
 
-	// Create a temporary output directory for the extracted page
-	outputDir := "./assets/documents"
-	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+	// Create a temporary output directory for the extracted page, so that
+	// pages of different documents extracted at the same time don't collide
+	outputDir, err := os.MkdirTemp("", "pages")
+	if err != nil {
 		return "", fmt.Errorf("could not create output directory: %w", err)
 	}
+	defer os.RemoveAll(outputDir)
 
 	err = api.ExtractPages(file, outputDir, "", []string{fmt.Sprintf("%d", pageNum)}, model.NewDefaultConfiguration())
 	if err != nil {
@@ -71,17 +74,15 @@ func ExtractPageAsBase64(inputPDF string, pageNum int) (string, error) {
 		return "", fmt.Errorf("could not read extracted page content: %w", err)
 	}
 
-	err = os.Remove(extractedPagePath)
-	if err != nil {
-		return "", fmt.Errorf("could not read extracted page content: %w", err)
-	}
-
 	// Encode the buffer content to Base64
 	base64String := base64.StdEncoding.EncodeToString(buffer.Bytes())
 
 	return base64String, nil
 }
 
+// ExtractPDFText returns the text of the selected pages. Lines are separated
+// by a newline and paragraphs, told apart by a larger vertical gap, by a blank
+// line.
 func ExtractPDFText(pdfPath string, selectedPages []int) (string, error) {
 	reader, err := pdf.Open(pdfPath)
 	if err != nil {
@@ -95,7 +96,10 @@ func ExtractPDFText(pdfPath string, selectedPages []int) (string, error) {
 		// Extract text from the page
 		content := page.Content()
 
-		for _, text := range content.Text {
+		for i, text := range content.Text {
+			if i > 0 {
+				fullText.WriteString(textSeparator(content.Text[i-1], text))
+			}
 			fullText.WriteString(text.S)
 		}
 
@@ -105,90 +109,110 @@ func ExtractPDFText(pdfPath string, selectedPages []int) (string, error) {
 	return fullText.String(), nil
 }
 
-// tokenizeSentences splits text into sentences using a simple approach
-func TokenizeSentences(text string) []string {
-	// Replace common abbreviations to prevent incorrect sentence splitting
-	text = strings.ReplaceAll(text, "Mr.", "Mr")
-	text = strings.ReplaceAll(text, "Mrs.", "Mrs")
-	text = strings.ReplaceAll(text, "Dr.", "Dr")
-
-	// Split text into sentences
-	sentences := strings.FieldsFunc(text, func(r rune) bool {
-		return r == '.' || r == '!' || r == '?'
-	})
-
-	// Clean and trim sentences
-	var cleanSentences []string
-	for _, sentence := range sentences {
-		trimmed := strings.TrimSpace(sentence)
-		if trimmed != "" {
-			// Restore punctuation
-			if strings.Contains(text, trimmed+".") {
-				trimmed += "."
-			} else if strings.Contains(text, trimmed+"!") {
-				trimmed += "!"
-			} else if strings.Contains(text, trimmed+"?") {
-				trimmed += "?"
-			}
-			cleanSentences = append(cleanSentences, trimmed)
+// textSeparator guesses the whitespace between two consecutive runs of text
+// from their position, as PDFs place lines rather than spell out line breaks.
+func textSeparator(prev, next pdf.Text) string {
+	size := math.Max(prev.FontSize, next.FontSize)
+	gap := math.Abs(prev.Y - next.Y)
+	if gap > size/2 {
+		if gap > 1.8*size {
+			return "\n\n"
 		}
+		return "\n"
 	}
 
-	return cleanSentences
+	if prev.W > 0 && next.X-(prev.X+prev.W) > size*0.15 && !strings.HasSuffix(prev.S, " ") && !strings.HasPrefix(next.S, " ") {
+		return " "
+	}
+	return ""
 }
 
-func GetOpenAIEmbeddings(texts []string) ([][]float32, error) {
-
-	constant := constants.New()
-
-	// Prepare request payload
-	client := openai.NewClient(constant.OpenAIKey)
-
-	resp, err := client.CreateEmbeddings(context.Background(), openai.EmbeddingRequest{
-		Model: openai.AdaEmbeddingV2,
-		Input: texts,
-	})
-
-	if err != nil {
-		return [][]float32{}, err
+// FormatInstructions returns the output format the model is asked to follow
+// for the given question type. Sizes come from the same bounds as the schema
+// and validation, so the model is never asked for questions that are dropped.
+func FormatInstructions(questionType models.QuestionType) string {
+	switch questionType {
+	case models.MultipleChoice:
+		return fmt.Sprintf(MULTIPLE_CHOICE_FORMAT_INSTRUCTIONS, models.MinOptions, models.MaxOptions)
+	case models.TrueFalse:
+		return TRUE_FALSE_FORMAT_INSTRUCTIONS
+	case models.FillInTheBlank:
+		return FILL_IN_THE_BLANK_FORMAT_INSTRUCTIONS
+	case models.Matching:
+		return fmt.Sprintf(MATCHING_FORMAT_INSTRUCTIONS, models.MinPairs, models.MaxPairs)
+	case models.Ordering:
+		return fmt.Sprintf(ORDERING_FORMAT_INSTRUCTIONS, models.MinSequenceSize, models.MaxSequenceSize)
 	}
+	return FORMAT_INSTRUCTIONS
+}
 
-	embeddings := [][]float32{}
-
-	for _, d := range resp.Data {
-		embeddings = append(embeddings, d.Embedding)
+// GenerateQuestions asks the model for questions of one type from the chunks,
+// written for the difficulty and cognitive level when they are set, and
+// returns the ones that pass validation, with Type and Answer filled in. Each
+// question's sources are the chunks it cites, or the ones sharing the most
+// words with it when it cites none.
+func GenerateQuestions(ctx context.Context, completer llm.ChatCompleter, questionType models.QuestionType, difficulty models.Difficulty, level models.CognitiveLevel, prompt string, chunks []models.Chunk) ([]models.Question, error) {
+	schema := models.QuestionSchema(questionType)
+	instructions := FormatInstructions(questionType)
+	if levelInstructions := LevelInstructions(difficulty, level); levelInstructions != "" {
+		instructions += "\n\n" + levelInstructions
 	}
+	systemPrompt := fmt.Sprintf(RESPONSE_SYSTEM_TEMPLATE, FormatContext(chunks), schema, instructions)
 
-	return embeddings, nil
-}
+	var result struct {
+		Questions []struct {
+			models.Question
+			Citations []int `json:"citations"`
+		} `json:"questions"`
+	}
 
-func GenerateQuestions(ctx context.Context, prompt string, context string) ([]openai.ChatCompletionChoice, error) {
-	constant := constants.New()
-
-	client := openai.NewClient(constant.OpenAIKey)
-
-	systemPrompt := fmt.Sprintf(RESPONSE_SYSTEM_TEMPLATE, "", FORMAT_INSTRUCTIONS)
-
-	resp, err := client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4oMini,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: systemPrompt,
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
+	err := llm.CompleteJSON(ctx, completer, llm.ChatRequest{
+		Messages: []llm.Message{
+			{
+				Role:    llm.RoleSystem,
+				Content: systemPrompt,
+			},
+			{
+				Role:    llm.RoleUser,
+				Content: prompt,
 			},
 		},
-	)
-
+		Schema: &llm.Schema{
+			Name:       string(questionType) + "_questions",
+			Definition: schema,
+		},
+	}, llm.DefaultAttempts, &result, func() []string {
+		var problems []string
+		for i := range result.Questions {
+			q := result.Questions[i]
+			q.Type = questionType
+			q.Normalize()
+			if err := q.Validate(); err != nil {
+				problems = append(problems, fmt.Sprintf("/questions/%d: %s", i, err))
+			}
+		}
+		return problems
+	})
 	if err != nil {
-		return []openai.ChatCompletionChoice{}, err
+		return nil, err
+	}
+
+	questions := []models.Question{}
+	for _, generated := range result.Questions {
+		q := generated.Question
+		q.Type = questionType
+		q.Normalize()
+		if err := q.Validate(); err != nil {
+			log.Printf("dropping invalid question %q: %s", q.Question, err)
+			continue
+		}
+
+		questions = append(questions, q)
+		n := len(questions) - 1
+		if !CiteSources(&questions[n], generated.Citations, chunks) {
+			AttachSources(questions[n:], chunks)
+		}
 	}
 
-	return resp.Choices, nil
+	return questions, nil
 }
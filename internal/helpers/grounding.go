@@ -0,0 +1,75 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/bjorndonald/test-maker-service/internal/llm"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+)
+
+// VerifyAnswers asks the model whether the context supports the answer of each
+// question and records its verdict in Grounding. Questions the model leaves
+// out keep the verdict they had.
+func VerifyAnswers(ctx context.Context, completer llm.ChatCompleter, questions []models.Question, context string) error {
+	if len(questions) == 0 {
+		return nil
+	}
+
+	var result struct {
+		Verdicts []struct {
+			Index     int              `json:"index"`
+			Grounding models.Grounding `json:"grounding"`
+			Reason    string           `json:"reason"`
+		} `json:"verdicts"`
+	}
+
+	schema := models.GroundingSchema()
+	err := llm.CompleteJSON(ctx, completer, llm.ChatRequest{
+		Messages: []llm.Message{
+			{
+				Role:    llm.RoleSystem,
+				Content: fmt.Sprintf(VERIFY_SYSTEM_TEMPLATE, context, schema),
+			},
+			{
+				Role:    llm.RoleUser,
+				Content: listQuestions(questions),
+			},
+		},
+		Schema: &llm.Schema{
+			Name:       "verdicts",
+			Definition: schema,
+		},
+	}, llm.DefaultAttempts, &result, func() []string {
+		var problems []string
+		seen := map[int]bool{}
+		for i, v := range result.Verdicts {
+			if v.Index >= len(questions) {
+				problems = append(problems, fmt.Sprintf("/verdicts/%d: there is no question %d", i, v.Index))
+			}
+			seen[v.Index] = true
+		}
+		for i := range questions {
+			if !seen[i] {
+				problems = append(problems, fmt.Sprintf("question %d has no verdict", i))
+			}
+		}
+		return problems
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, v := range result.Verdicts {
+		if v.Index < 0 || v.Index >= len(questions) {
+			continue
+		}
+		q := &questions[v.Index]
+		q.Grounding = v.Grounding
+		if v.Grounding != models.Supported {
+			log.Printf("answer to %q is %s: %s", q.Question, v.Grounding, v.Reason)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bjorndonald/test-maker-service/internal/llm"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+)
+
+// LevelInstructions tells the model the difficulty and level of Bloom's
+// taxonomy to write questions for. Either may be empty to leave it open.
+func LevelInstructions(difficulty models.Difficulty, level models.CognitiveLevel) string {
+	instructions := []string{}
+	switch difficulty {
+	case models.Easy:
+		instructions = append(instructions, EASY_INSTRUCTIONS)
+	case models.Medium:
+		instructions = append(instructions, MEDIUM_INSTRUCTIONS)
+	case models.Hard:
+		instructions = append(instructions, HARD_INSTRUCTIONS)
+	}
+
+	switch level {
+	case models.Remember:
+		instructions = append(instructions, REMEMBER_INSTRUCTIONS)
+	case models.Understand:
+		instructions = append(instructions, UNDERSTAND_INSTRUCTIONS)
+	case models.Apply:
+		instructions = append(instructions, APPLY_INSTRUCTIONS)
+	case models.Analyze:
+		instructions = append(instructions, ANALYZE_INSTRUCTIONS)
+	case models.Evaluate:
+		instructions = append(instructions, EVALUATE_INSTRUCTIONS)
+	case models.Create:
+		instructions = append(instructions, CREATE_INSTRUCTIONS)
+	}
+
+	return strings.Join(instructions, "\n")
+}
+
+// ClassifyQuestions asks the model to judge the difficulty and cognitive level
+// of each question and corrects their tags to match. Questions the model
+// leaves out keep the tags they had.
+func ClassifyQuestions(ctx context.Context, completer llm.ChatCompleter, questions []models.Question) error {
+	if len(questions) == 0 {
+		return nil
+	}
+
+	var result struct {
+		Classifications []struct {
+			Index          int                   `json:"index"`
+			Difficulty     models.Difficulty     `json:"difficulty"`
+			CognitiveLevel models.CognitiveLevel `json:"cognitive_level"`
+		} `json:"classifications"`
+	}
+
+	schema := models.ClassificationSchema()
+	err := llm.CompleteJSON(ctx, completer, llm.ChatRequest{
+		Messages: []llm.Message{
+			{
+				Role:    llm.RoleSystem,
+				Content: fmt.Sprintf(CLASSIFY_SYSTEM_TEMPLATE, schema),
+			},
+			{
+				Role:    llm.RoleUser,
+				Content: listQuestions(questions),
+			},
+		},
+		Schema: &llm.Schema{
+			Name:       "classifications",
+			Definition: schema,
+		},
+	}, llm.DefaultAttempts, &result, func() []string {
+		var problems []string
+		seen := map[int]bool{}
+		for i, c := range result.Classifications {
+			if c.Index >= len(questions) {
+				problems = append(problems, fmt.Sprintf("/classifications/%d: there is no question %d", i, c.Index))
+			}
+			seen[c.Index] = true
+		}
+		for i := range questions {
+			if !seen[i] {
+				problems = append(problems, fmt.Sprintf("question %d is not classified", i))
+			}
+		}
+		return problems
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range result.Classifications {
+		if c.Index < 0 || c.Index >= len(questions) {
+			continue
+		}
+		q := &questions[c.Index]
+		if q.Difficulty != c.Difficulty || q.CognitiveLevel != c.CognitiveLevel {
+			if q.Difficulty != "" || q.CognitiveLevel != "" {
+				log.Printf("retagging question %q from %s/%s to %s/%s", q.Question, q.Difficulty, q.CognitiveLevel, c.Difficulty, c.CognitiveLevel)
+			}
+			q.Difficulty, q.CognitiveLevel = c.Difficulty, c.CognitiveLevel
+		}
+	}
+	return nil
+}
+
+// listQuestions writes out the questions with their answers, each numbered by
+// its index, for the model to review.
+func listQuestions(questions []models.Question) string {
+	var listing strings.Builder
+	for i, q := range questions {
+		fmt.Fprintf(&listing, "%d. (%s) %s\nAnswer: %s\n\n", i, q.Type.Label(), q.Question, q.Answer)
+	}
+	return listing.String()
+}
@@ -0,0 +1,161 @@
+package helpers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+)
+
+var sentencePattern = regexp.MustCompile(`[^.!?]+[.!?]*`)
+
+// FormatContext lays out the chunks for the prompt, numbered from 1 with the
+// pages and heading they come from, so the model can cite them by number.
+func FormatContext(chunks []models.Chunk) string {
+	var context strings.Builder
+	for i, chunk := range chunks {
+		fmt.Fprintf(&context, "[%d]", i+1)
+
+		labels := []string{}
+		switch {
+		case chunk.PageFrom > 0 && chunk.PageTo > chunk.PageFrom:
+			labels = append(labels, fmt.Sprintf("pages %d-%d", chunk.PageFrom, chunk.PageTo))
+		case chunk.PageFrom > 0:
+			labels = append(labels, fmt.Sprintf("page %d", chunk.PageFrom))
+		}
+		if chunk.Heading != "" {
+			labels = append(labels, chunk.Heading)
+		}
+		if len(labels) > 0 {
+			fmt.Fprintf(&context, " (%s)", strings.Join(labels, ", "))
+		}
+
+		context.WriteString("\n" + strings.TrimSpace(chunk.Chunk) + "\n\n")
+	}
+	return strings.TrimSuffix(context.String(), "\n")
+}
+
+// CiteSources sets the sources of a question to the chunks it cites, numbered
+// from 1 as in FormatContext, quoting the sentence of each that matches the
+// question best. It reports false, leaving the question alone, when none of
+// the citations name a chunk with a page.
+func CiteSources(q *models.Question, citations []int, chunks []models.Chunk) bool {
+	questionWords := keywords(strings.Join([]string{q.Question, q.Answer, q.Feedback}, " "))
+
+	sources := []models.Source{}
+	for _, n := range citations {
+		if n < 1 || n > len(chunks) || chunks[n-1].PageFrom == 0 {
+			continue
+		}
+		source, _ := bestSentence(chunks[n-1], questionWords)
+		if source.Excerpt != "" && len(sources) < MAX_SOURCES && !containsSource(sources, source) {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		return false
+	}
+
+	q.Sources = sources
+	return true
+}
+
+// AttachSources sets the sources of each question to the chunks sharing the
+// most words with it, quoting the sentence of each chunk that matches best.
+// Chunks without a page are never cited.
+func AttachSources(questions []models.Question, chunks []models.Chunk) {
+	for i := range questions {
+		q := &questions[i]
+		questionWords := keywords(strings.Join([]string{q.Question, q.Answer, q.Feedback}, " "))
+
+		type candidate struct {
+			source models.Source
+			score  int
+		}
+		candidates := []candidate{}
+		for _, chunk := range chunks {
+			if chunk.PageFrom == 0 {
+				continue
+			}
+
+			source, score := bestSentence(chunk, questionWords)
+			if score > 0 {
+				candidates = append(candidates, candidate{source: source, score: score})
+			}
+		}
+
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return candidates[a].score > candidates[b].score
+		})
+
+		q.Sources = nil
+		for _, c := range candidates {
+			if len(q.Sources) == MAX_SOURCES {
+				break
+			}
+			if !containsSource(q.Sources, c.source) {
+				q.Sources = append(q.Sources, c.source)
+			}
+		}
+	}
+}
+
+// bestSentence quotes the sentence of the chunk sharing the most of the words,
+// or its first sentence when none share any, and returns how many it shares.
+func bestSentence(chunk models.Chunk, words map[string]bool) (models.Source, int) {
+	best, bestScore := "", -1
+	for _, sentence := range sentencePattern.FindAllString(chunk.Chunk, -1) {
+		score := 0
+		for word := range keywords(sentence) {
+			if words[word] {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = sentence, score
+		}
+	}
+	return models.Source{Page: chunk.PageFrom, Excerpt: excerpt(best)}, bestScore
+}
+
+// keywords returns the lower cased words of text, leaving out the short ones
+// which are mostly stop words.
+func keywords(text string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len([]rune(word)) > 3 {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// excerpt collapses the whitespace of a sentence and shortens it to
+// MAX_EXCERPT_LENGTH characters at a word boundary.
+func excerpt(sentence string) string {
+	text := strings.Join(strings.Fields(sentence), " ")
+	runes := []rune(text)
+	if len(runes) <= MAX_EXCERPT_LENGTH {
+		return text
+	}
+
+	cut := string(runes[:MAX_EXCERPT_LENGTH])
+	if space := strings.LastIndex(cut, " "); space > 0 {
+		cut = cut[:space]
+	}
+	return cut + "…"
+}
+
+func containsSource(sources []models.Source, source models.Source) bool {
+	for _, s := range sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+// Package highlight marks passages of a PDF with highlight annotations, each
+// with a popup note, so a reader can find where something came from.
+package highlight
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/dslipak/pdf"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	pdffont "github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/color"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+const (
+	// ascent and descent extend a line of text from its baseline, as a share
+	// of the font size.
+	ascent  = 0.9
+	descent = 0.25
+
+	popupWidth  = 180
+	popupHeight = 60
+)
+
+var highlightColor = color.SimpleColor{R: 1, G: 0.9, B: 0.2}
+
+// Mark is a passage of a page to highlight with a note.
+type Mark struct {
+	Page int
+	Text string
+	Note string
+}
+
+// Write copies the PDF at path to w with the text of every mark highlighted.
+// Passages are matched ignoring case, whitespace and punctuation, so text
+// extracted from the PDF finds its way back. Marks that can't be found are
+// left out and Write returns how many were placed.
+func Write(w io.Writer, path string, marks []Mark) (int, error) {
+	reader, err := pdf.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open PDF: %w", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("could not open file: %w", err)
+	}
+	defer file.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.ADDANNOTATIONS
+	ctx, err := api.ReadValidateAndOptimize(file, conf)
+	if err != nil {
+		return 0, err
+	}
+
+	placed := 0
+	for i, mark := range marks {
+		if mark.Page < 1 || mark.Page > reader.NumPage() {
+			continue
+		}
+
+		quads := locate(reader.Page(mark.Page).Content().Text, mark.Text)
+		if len(quads) == 0 {
+			continue
+		}
+
+		if err := annotate(ctx, mark, i, quads); err != nil {
+			return placed, err
+		}
+		placed++
+	}
+
+	if err := api.Write(ctx, w, conf); err != nil {
+		return placed, err
+	}
+	return placed, nil
+}
+
+// annotate adds the highlight of a mark and the popup holding its note.
+func annotate(ctx *model.Context, mark Mark, id int, quads types.QuadPoints) error {
+	rect := quads[0].EnclosingRectangle(0)
+	for _, quad := range quads[1:] {
+		r := quad.EnclosingRectangle(0)
+		rect = types.NewRectangle(
+			math.Min(rect.LL.X, r.LL.X), math.Min(rect.LL.Y, r.LL.Y),
+			math.Max(rect.UR.X, r.UR.X), math.Max(rect.UR.Y, r.UR.Y),
+		)
+	}
+
+	highlight := model.NewHighlightAnnotation(
+		*rect, mark.Note, fmt.Sprintf("highlight-%d", id), "", model.AnnPrint, &highlightColor,
+		0, 0, 0, mark.Note, nil, nil, "", "Source", quads,
+	)
+	highlightRef, highlightDict, err := pdfcpu.AddAnnotationToPage(ctx, mark.Page, highlight, false)
+	if err != nil {
+		return err
+	}
+
+	popupRect := types.NewRectangle(rect.UR.X, rect.UR.Y-popupHeight, rect.UR.X+popupWidth, rect.UR.Y)
+	popup := model.NewPopupAnnotation(
+		*popupRect, mark.Note, fmt.Sprintf("popup-%d", id), "", 0, nil,
+		0, 0, 0, highlightRef, false,
+	)
+	popupRef, _, err := pdfcpu.AddAnnotationToPage(ctx, mark.Page, popup, false)
+	if err != nil {
+		return err
+	}
+
+	highlightDict.Insert("Popup", *popupRef)
+	return nil
+}
+
+// place fills in the position of glyphs that a PDF leaves to its fonts. Core
+// fonts usually come without widths, so each glyph of a run is reported at
+// the start of the run with no width.
+func place(glyphs []pdf.Text) []pdf.Text {
+	placed := make([]pdf.Text, len(glyphs))
+	for i, glyph := range glyphs {
+		if glyph.W <= 0 {
+			if pdffont.IsCoreFont(glyph.Font) {
+				glyph.W = pdffont.TextWidth(glyph.S, glyph.Font, int(math.Round(glyph.FontSize)))
+			} else {
+				glyph.W = 0.5 * glyph.FontSize * float64(len([]rune(glyph.S)))
+			}
+		}
+		if i > 0 {
+			prev := placed[i-1]
+			if math.Abs(glyph.Y-prev.Y) < glyph.FontSize/2 && glyph.X <= prev.X {
+				glyph.X = prev.X + prev.W
+			}
+		}
+		placed[i] = glyph
+	}
+	return placed
+}
+
+// locate finds text among the glyphs of a page and returns a quadrilateral
+// around each line it covers.
+func locate(glyphs []pdf.Text, text string) types.QuadPoints {
+	glyphs = place(glyphs)
+
+	// Index the page by the letters and digits of each glyph
+	var page []rune
+	var owner []int
+	for i, glyph := range glyphs {
+		for _, r := range normalize(glyph.S) {
+			page = append(page, r)
+			owner = append(owner, i)
+		}
+	}
+
+	needle := normalize(strings.TrimSuffix(text, "…"))
+	start := index(page, needle)
+	if len(needle) == 0 || start < 0 {
+		return nil
+	}
+	first, last := owner[start], owner[start+len(needle)-1]
+
+	var quads types.QuadPoints
+	var line *types.Rectangle
+	var baseline float64
+	for _, glyph := range glyphs[first : last+1] {
+		if strings.TrimSpace(glyph.S) == "" {
+			continue
+		}
+
+		top, bottom := glyph.Y+ascent*glyph.FontSize, glyph.Y-descent*glyph.FontSize
+		if line != nil && math.Abs(glyph.Y-baseline) < glyph.FontSize/2 {
+			line.LL.X = math.Min(line.LL.X, glyph.X)
+			line.UR.X = math.Max(line.UR.X, glyph.X+glyph.W)
+			line.LL.Y = math.Min(line.LL.Y, bottom)
+			line.UR.Y = math.Max(line.UR.Y, top)
+			continue
+		}
+
+		if line != nil {
+			quads.AddQuadLiteral(*types.NewQuadLiteralForRect(line))
+		}
+		line = types.NewRectangle(glyph.X, bottom, glyph.X+glyph.W, top)
+		baseline = glyph.Y
+	}
+	if line != nil {
+		quads.AddQuadLiteral(*types.NewQuadLiteralForRect(line))
+	}
+
+	return quads
+}
+
+func normalize(text string) []rune {
+	var runes []rune
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
+
+func index(haystack, needle []rune) int {
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
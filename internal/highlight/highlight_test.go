@@ -0,0 +1,132 @@
+package highlight
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dslipak/pdf"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	pdffont "github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func book(t *testing.T) string {
+	t.Helper()
+	description := `{"paper": "A4P", "origin": "LowerLeft", "pages": {
+		"1": {"content": {"text": [
+			{"value": "The nucleus stores DNA.", "pos": [50, 780], "font": {"name": "Helvetica", "size": 12}}
+		]}},
+		"2": {"content": {"text": [
+			{"value": "Cells need energy. The mitochondrion", "pos": [50, 780], "font": {"name": "Helvetica", "size": 12}},
+			{"value": "produces energy for the cell.", "pos": [50, 766], "font": {"name": "Helvetica", "size": 12}}
+		]}}
+	}}`
+
+	var buf bytes.Buffer
+	if err := api.Create(nil, strings.NewReader(description), &buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "book.pdf")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	placed, err := Write(&buf, book(t), []Mark{
+		{Page: 2, Text: "The mitochondrion produces energy for the cell.", Note: "Question 3"},
+		{Page: 1, Text: "Ribosomes make proteins.", Note: "Question 4"},
+		{Page: 9, Text: "The nucleus stores DNA.", Note: "Question 5"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if placed != 1 {
+		t.Fatalf("expected 1 mark to be placed, got %d", placed)
+	}
+
+	annotations, err := api.Annotations(bytes.NewReader(buf.Bytes()), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(annotations[1]) != 0 {
+		t.Errorf("expected no annotations on page 1, got %v", annotations[1])
+	}
+
+	highlights := annotations[2][model.AnnHighLight].Map
+	popups := annotations[2][model.AnnPopup].Map
+	if len(highlights) != 1 || len(popups) != 1 {
+		t.Fatalf("expected a highlight and a popup on page 2, got %v", annotations[2])
+	}
+
+	for _, highlight := range highlights {
+		if !strings.Contains(highlight.ContentString(), "Question 3") {
+			t.Errorf("expected the highlight to carry the question number, got %s", highlight.ContentString())
+		}
+	}
+	for _, popup := range popups {
+		if !strings.HasPrefix(popup.ContentString(), "->") {
+			t.Errorf("expected the popup to belong to the highlight, got %s", popup.ContentString())
+		}
+	}
+
+	ctx, err := api.ReadContext(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linked := 0
+	for nr := range ctx.Table {
+		d, err := ctx.DereferenceDict(*types.NewIndirectRef(nr, 0))
+		if err == nil && d != nil && d.Subtype() != nil && *d.Subtype() == "Highlight" {
+			if _, found := d.Find("Popup"); found {
+				linked++
+			}
+		}
+	}
+	if linked != 1 {
+		t.Errorf("expected the highlight to point at its popup")
+	}
+}
+
+func TestLocateCoversEachLine(t *testing.T) {
+	glyphs := func(text string, y float64) []pdf.Text {
+		var run []pdf.Text
+		for _, r := range text {
+			run = append(run, pdf.Text{Font: "Helvetica", FontSize: 12, X: 50, Y: y, S: string(r)})
+		}
+		return run
+	}
+	page := append(glyphs("Cells need energy. The mitochondrion", 780), glyphs("produces energy for the cell.", 766)...)
+
+	quads := locate(page, "The mitochondrion produces energy")
+	if len(quads) != 2 {
+		t.Fatalf("expected a quadrilateral per line, got %d", len(quads))
+	}
+
+	first, second := quads[0].EnclosingRectangle(0), quads[1].EnclosingRectangle(0)
+	if first.LL.X <= 50 || first.LL.Y >= 780 || first.UR.Y <= 780 {
+		t.Errorf("expected the first line to start after the first sentence and cover its baseline, got %v", first)
+	}
+	if second.LL.X != 50 || second.UR.X >= 50+pdffont.TextWidth("produces energy for the cell.", "Helvetica", 12) {
+		t.Errorf("expected the second line to stop before the end of the line, got %v", second)
+	}
+}
+
+func TestLocateIgnoresSpacingAndCase(t *testing.T) {
+	path := book(t)
+	var buf bytes.Buffer
+	placed, err := Write(&buf, path, []Mark{{Page: 1, Text: "the NUCLEUS  stores…", Note: "Question 1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if placed != 1 {
+		t.Errorf("expected the passage to be found, got %d marks placed", placed)
+	}
+}
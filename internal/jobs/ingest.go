@@ -0,0 +1,255 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/bjorndonald/test-maker-service/internal/chunker"
+	"github.com/bjorndonald/test-maker-service/internal/helpers"
+	"github.com/bjorndonald/test-maker-service/internal/llm"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/repository"
+	"github.com/google/uuid"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+const pageWorkers = 5
+
+type AnalyzePayload struct {
+	DocumentId string `json:"document_id"`
+	Link       string `json:"link,omitempty"`
+}
+
+type EmbedPayload struct {
+	DocumentId string          `json:"document_id"`
+	Pages      []int           `json:"pages"`
+	Chunking   chunker.Options `json:"chunking"`
+}
+
+// Ingestion holds the processors that turn uploaded PDFs into pages and
+// embedded chunks.
+type Ingestion struct {
+	docuRepo  repository.DocumentInterface
+	embedder  llm.Embedder
+	tokenizer chunker.Tokenizer
+}
+
+func NewIngestion(docuRepo repository.DocumentInterface, embedder llm.Embedder, tokenizer chunker.Tokenizer) *Ingestion {
+	return &Ingestion{
+		docuRepo:  docuRepo,
+		embedder:  embedder,
+		tokenizer: tokenizer,
+	}
+}
+
+// Register adds the ingestion processors to the runner.
+func (i *Ingestion) Register(r *Runner) {
+	r.Register(models.AnalyzeJob, i.Analyze)
+	r.Register(models.EmbedJob, i.Embed)
+}
+
+// Analyze downloads the document if it came from a link and extracts every
+// page as a base64 encoded PDF. It starts over when resumed.
+func (i *Ingestion) Analyze(ctx context.Context, job models.Job, progress Progress) (interface{}, error) {
+	var payload AnalyzePayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, err
+	}
+
+	doc, err := i.docuRepo.RetrieveDocument(ctx, payload.DocumentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload.Link != "" {
+		if _, err := os.Stat(doc.Url); err != nil {
+			if err := download(ctx, payload.Link, doc.Url); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	file, err := os.Open(doc.Url)
+	if err != nil {
+		return nil, fmt.Errorf("issue reading file: %w", err)
+	}
+	numPages, err := api.PageCount(file, model.NewDefaultConfiguration())
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("issue getting page number: %w", err)
+	}
+
+	if err := progress(0, numPages); err != nil {
+		return nil, err
+	}
+
+	pages := make(chan int, numPages)
+	for pageNum := 1; pageNum <= numPages; pageNum++ {
+		pages <- pageNum
+	}
+	close(pages)
+
+	pdfpages := make([]string, numPages)
+	var mu sync.Mutex
+	var firstErr error
+	done := 0
+
+	var wg sync.WaitGroup
+	for w := 0; w < pageWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pageNum := range pages {
+				if ctx.Err() != nil {
+					return
+				}
+				encoded, err := helpers.ExtractPageAsBase64(doc.Url, pageNum)
+
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				pdfpages[pageNum-1] = encoded
+				done++
+				if err := progress(done, numPages); err != nil && firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return models.AnalyzedPDF{
+		Id:            doc.Id.String(),
+		NumberOfPages: numPages,
+		Pdfs:          pdfpages,
+	}, nil
+}
+
+// Embed extracts, chunks and embeds the selected pages one at a time, storing
+// the chunks of each page before moving on. When resumed it continues after
+// the last page it finished, replacing any chunks already saved for the page
+// it was working on.
+func (i *Ingestion) Embed(ctx context.Context, job models.Job, progress Progress) (interface{}, error) {
+	var payload EmbedPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, err
+	}
+
+	documentId, err := uuid.Parse(payload.DocumentId)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing document ID: %w", err)
+	}
+
+	doc, err := i.docuRepo.RetrieveDocument(ctx, payload.DocumentId)
+	if err != nil {
+		return nil, err
+	}
+
+	var outline []chunker.Heading
+	if payload.Chunking.Strategy == chunker.StrategyHeading {
+		if outline, err = chunker.Outline(doc.Url); err != nil {
+			return nil, err
+		}
+	}
+
+	splitter, err := chunker.New(i.tokenizer, payload.Chunking, outline)
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(payload.Pages)
+	for done := job.PagesDone; done < total; done++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		text, err := helpers.ExtractPDFText(doc.Url, []int{payload.Pages[done]})
+		if err != nil {
+			return nil, fmt.Errorf("text extraction error: %w", err)
+		}
+
+		// A crash after the chunks of a page were saved but before its
+		// progress was recorded would otherwise save them twice on resume.
+		if err := i.docuRepo.DeletePageChunks(ctx, payload.DocumentId, payload.Pages[done]); err != nil {
+			return nil, err
+		}
+
+		chunks := splitter.Split(payload.Pages[done], text)
+		if len(chunks) > 0 {
+			texts := make([]string, 0, len(chunks))
+			for _, chunk := range chunks {
+				texts = append(texts, chunk.Text)
+			}
+
+			embeddings, err := i.embedder.Embed(ctx, texts)
+			if err != nil {
+				return nil, fmt.Errorf("embedding error: %w", err)
+			}
+
+			rows := []models.Chunk{}
+			for j, embedding := range embeddings {
+				rows = append(rows, models.Chunk{
+					Id:             uuid.New(),
+					DocumentId:     documentId,
+					Chunk:          chunks[j].Text,
+					ChunkEmbedding: embedding,
+					PageFrom:       chunks[j].Page,
+					PageTo:         chunks[j].Page,
+					Start:          chunks[j].Start,
+					End:            chunks[j].End,
+					Strategy:       string(chunks[j].Strategy),
+					Heading:        chunks[j].Heading,
+				})
+			}
+
+			if err := i.docuRepo.InsertChunks(ctx, rows); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := progress(done+1, total); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func download(ctx context.Context, link string, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading %s: %s", link, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bjorndonald/test-maker-service/internal/chunker"
+	"github.com/bjorndonald/test-maker-service/internal/llm"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/repository"
+	"github.com/google/uuid"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+func book(t *testing.T) string {
+	t.Helper()
+	description := `{"paper": "A4P", "origin": "LowerLeft", "pages": {
+		"1": {"content": {"text": [
+			{"value": "The nucleus stores DNA.", "pos": [50, 780], "font": {"name": "Helvetica", "size": 12}}
+		]}},
+		"2": {"content": {"text": [
+			{"value": "The mitochondrion produces energy.", "pos": [50, 780], "font": {"name": "Helvetica", "size": 12}}
+		]}}
+	}}`
+
+	var buf bytes.Buffer
+	if err := api.Create(nil, strings.NewReader(description), &buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "book.pdf")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEmbedResumeDoesNotDuplicateChunks(t *testing.T) {
+	ctx := context.Background()
+	repo, err := repository.NewMemoryRepo(repository.Cosine, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := models.Document{Id: uuid.New(), Url: book(t)}
+	if _, err := repo.InsertDocument(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	payload, _ := json.Marshal(EmbedPayload{DocumentId: doc.Id.String(), Pages: []int{1, 2}})
+	job := models.Job{Id: uuid.New(), Kind: models.EmbedJob, Payload: payload, PagesTotal: 2}
+	ingestion := NewIngestion(repo, llm.NewFake(8), chunker.Words{})
+
+	// The first run stops after the chunks of page 1 were saved but before
+	// its progress was recorded, as a crash would.
+	crash := errors.New("crash")
+	_, err = ingestion.Embed(ctx, job, func(done, total int) error { return crash })
+	if !errors.Is(err, crash) {
+		t.Fatalf("expected the first run to stop, got %v", err)
+	}
+
+	_, err = ingestion.Embed(ctx, job, func(done, total int) error {
+		job.PagesDone = done
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, err := repo.VectorSearch(ctx, doc.Id.String(), make([]float32, 8), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := map[int]int{}
+	for _, chunk := range chunks {
+		pages[chunk.PageFrom]++
+	}
+	if job.PagesDone != 2 || pages[1] != 1 || pages[2] != 1 {
+		t.Errorf("expected one chunk for each page after resuming, got %v", pages)
+	}
+}
@@ -0,0 +1,198 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Progress records how many pages of a job are done. Processors call it as
+// they go so that status polling, and resuming after a restart, see it.
+type Progress func(done int, total int) error
+
+// Processor does the work of one kind of job and returns its result, which is
+// stored as JSON on the job.
+type Processor func(ctx context.Context, job models.Job, progress Progress) (interface{}, error)
+
+const defaultPollInterval = 5 * time.Second
+
+// Runner is a pool of workers processing jobs stored through a JobInterface.
+// Since the queue lives in the database, jobs survive a restart.
+type Runner struct {
+	repo         repository.JobInterface
+	processors   map[models.JobKind]Processor
+	workers      int
+	pollInterval time.Duration
+	wake         chan struct{}
+	wg           sync.WaitGroup
+}
+
+func NewRunner(repo repository.JobInterface, workers int) *Runner {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Runner{
+		repo:         repo,
+		processors:   map[models.JobKind]Processor{},
+		workers:      workers,
+		pollInterval: defaultPollInterval,
+		wake:         make(chan struct{}, workers),
+	}
+}
+
+func (r *Runner) Register(kind models.JobKind, processor Processor) {
+	r.processors[kind] = processor
+}
+
+// Enqueue stores a pending job and wakes a worker to pick it up.
+func (r *Runner) Enqueue(ctx context.Context, kind models.JobKind, payload interface{}, pagesTotal int) (models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	now := time.Now()
+	job := models.Job{
+		Id:         uuid.New(),
+		Kind:       kind,
+		Status:     models.JobPending,
+		Payload:    data,
+		PagesTotal: pagesTotal,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := r.repo.InsertJob(ctx, job); err != nil {
+		return models.Job{}, err
+	}
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+
+	return job, nil
+}
+
+// Start requeues jobs interrupted by a previous shutdown and starts the
+// workers. They stop when ctx is cancelled; Wait blocks until they have.
+func (r *Runner) Start(ctx context.Context) error {
+	requeued, err := r.repo.RequeueRunningJobs(ctx)
+	if err != nil {
+		return err
+	}
+	if requeued > 0 {
+		log.Printf("resuming %d interrupted jobs", requeued)
+	}
+
+	for i := 0; i < r.workers; i++ {
+		r.wg.Add(1)
+		go r.work(ctx)
+	}
+
+	return nil
+}
+
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+func (r *Runner) work(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for r.runNext(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNext claims and runs one job. It reports whether a job was found.
+func (r *Runner) runNext(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	job, ok, err := r.repo.ClaimJob(ctx)
+	if err != nil {
+		log.Println("error claiming job: ", err.Error())
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	r.run(ctx, job)
+	return true
+}
+
+func (r *Runner) run(ctx context.Context, job models.Job) {
+	processor, ok := r.processors[job.Kind]
+	if !ok {
+		r.finish(ctx, job, nil, fmt.Errorf("no processor for job kind %q", job.Kind))
+		return
+	}
+
+	progress := func(done int, total int) error {
+		job.PagesDone = done
+		job.PagesTotal = total
+		job.UpdatedAt = time.Now()
+		return r.repo.UpdateJob(ctx, job)
+	}
+
+	result, err := process(ctx, processor, job, progress)
+	if ctx.Err() != nil {
+		// Shutting down: leave the job running so it is requeued on start.
+		return
+	}
+	r.finish(ctx, job, result, err)
+}
+
+// process runs a processor, turning a panic into an error so that one bad
+// document fails its job instead of taking the service down with it.
+func process(ctx context.Context, processor Processor, job models.Job, progress Progress) (result interface{}, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("job %s panicked: %v\n%s", job.Id, recovered, debug.Stack())
+			result, err = nil, fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+
+	return processor(ctx, job, progress)
+}
+
+func (r *Runner) finish(ctx context.Context, job models.Job, result interface{}, err error) {
+	job.Status = models.JobCompleted
+	job.UpdatedAt = time.Now()
+	if err != nil {
+		job.Status = models.JobFailed
+		job.Error = err.Error()
+	} else if result != nil {
+		job.Result, err = json.Marshal(result)
+		if err != nil {
+			job.Status = models.JobFailed
+			job.Error = err.Error()
+		}
+	}
+
+	if err := r.repo.UpdateJob(ctx, job); err != nil {
+		log.Println("error saving job: ", err.Error())
+	}
+}
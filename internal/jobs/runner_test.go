@@ -0,0 +1,205 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+)
+
+type memoryJobRepo struct {
+	mu   sync.Mutex
+	jobs map[string]models.Job
+}
+
+func newMemoryJobRepo() *memoryJobRepo {
+	return &memoryJobRepo{jobs: map[string]models.Job{}}
+}
+
+func (m *memoryJobRepo) InsertJob(ctx context.Context, job models.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job.Id.String()] = job
+	return nil
+}
+
+func (m *memoryJobRepo) RetrieveJob(ctx context.Context, id string) (models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return job, errors.New("not found")
+	}
+	return job, nil
+}
+
+func (m *memoryJobRepo) UpdateJob(ctx context.Context, job models.Job) error {
+	return m.InsertJob(ctx, job)
+}
+
+func (m *memoryJobRepo) ClaimJob(ctx context.Context) (models.Job, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pending := []models.Job{}
+	for _, job := range m.jobs {
+		if job.Status == models.JobPending {
+			pending = append(pending, job)
+		}
+	}
+	if len(pending) == 0 {
+		return models.Job{}, false, nil
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+
+	job := pending[0]
+	job.Status = models.JobRunning
+	m.jobs[job.Id.String()] = job
+	return job, true, nil
+}
+
+func (m *memoryJobRepo) RequeueRunningJobs(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for id, job := range m.jobs {
+		if job.Status == models.JobRunning {
+			job.Status = models.JobPending
+			m.jobs[id] = job
+			n++
+		}
+	}
+	return n, nil
+}
+
+func waitFor(t *testing.T, repo *memoryJobRepo, id string, status models.JobStatus) models.Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, _ := repo.RetrieveJob(context.Background(), id)
+		if job.Status == status {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s never reached %s", id, status)
+	return models.Job{}
+}
+
+func TestRunnerProcessesJobsAndRecordsProgress(t *testing.T) {
+	repo := newMemoryJobRepo()
+	runner := NewRunner(repo, 2)
+	runner.Register(models.EmbedJob, func(ctx context.Context, job models.Job, progress Progress) (interface{}, error) {
+		for done := job.PagesDone; done < job.PagesTotal; done++ {
+			if err := progress(done+1, job.PagesTotal); err != nil {
+				return nil, err
+			}
+		}
+		return map[string]int{"pages": job.PagesTotal}, nil
+	})
+	runner.Register(models.AnalyzeJob, func(ctx context.Context, job models.Job, progress Progress) (interface{}, error) {
+		return nil, errors.New("corrupt pdf")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		runner.Wait()
+	}()
+	if err := runner.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	embed, err := runner.Enqueue(ctx, models.EmbedJob, EmbedPayload{DocumentId: "doc", Pages: []int{1, 2, 3}}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analyze, err := runner.Enqueue(ctx, models.AnalyzeJob, AnalyzePayload{DocumentId: "doc"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := waitFor(t, repo, embed.Id.String(), models.JobCompleted)
+	if done.PagesDone != 3 || string(done.Result) != `{"pages":3}` {
+		t.Errorf("unexpected completed job: %+v", done)
+	}
+
+	failed := waitFor(t, repo, analyze.Id.String(), models.JobFailed)
+	if failed.Error != "corrupt pdf" {
+		t.Errorf("expected the processor error to be recorded, got %q", failed.Error)
+	}
+}
+
+func TestRunnerResumesInterruptedJobs(t *testing.T) {
+	repo := newMemoryJobRepo()
+	interrupted := models.Job{
+		Id:         [16]byte{1},
+		Kind:       models.EmbedJob,
+		Status:     models.JobRunning,
+		Payload:    []byte(`{}`),
+		PagesDone:  2,
+		PagesTotal: 5,
+		CreatedAt:  time.Now(),
+	}
+	repo.InsertJob(context.Background(), interrupted)
+
+	resumedFrom := make(chan int, 1)
+	runner := NewRunner(repo, 1)
+	runner.Register(models.EmbedJob, func(ctx context.Context, job models.Job, progress Progress) (interface{}, error) {
+		resumedFrom <- job.PagesDone
+		return nil, progress(job.PagesTotal, job.PagesTotal)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		runner.Wait()
+	}()
+	if err := runner.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case from := <-resumedFrom:
+		if from != 2 {
+			t.Errorf("expected the job to resume after page 2, got %d", from)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("interrupted job was not resumed")
+	}
+
+	waitFor(t, repo, interrupted.Id.String(), models.JobCompleted)
+}
+
+func TestRunnerFailsPanickingJobs(t *testing.T) {
+	repo := newMemoryJobRepo()
+	runner := NewRunner(repo, 1)
+	runner.Register(models.AnalyzeJob, func(ctx context.Context, job models.Job, progress Progress) (interface{}, error) {
+		var pages []int
+		return pages[3], nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		runner.Wait()
+	}()
+	if err := runner.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := runner.Enqueue(ctx, models.AnalyzeJob, AnalyzePayload{DocumentId: "doc"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := waitFor(t, repo, job.Id.String(), models.JobFailed)
+	if !strings.Contains(failed.Error, "index out of range") {
+		t.Errorf("expected the panic to be recorded as the job error, got %q", failed.Error)
+	}
+}
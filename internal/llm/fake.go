@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// DefaultDimensions matches the width of the chunks.chunk_embedding column.
+const DefaultDimensions = 1536
+
+// Fake is a deterministic, offline Embedder and ChatCompleter for tests and
+// local development.
+//
+// Embeddings are hashed bags of words, so texts sharing words end up close to
+// each other. Completions are served from Responses in order, the last one
+// repeating once the list is exhausted, unless Respond is set.
+type Fake struct {
+	Dimensions int
+	Responses  []string
+	Respond    func(req ChatRequest) (string, error)
+
+	mu       sync.Mutex
+	requests []ChatRequest
+}
+
+func NewFake(dimensions int, responses ...string) *Fake {
+	return &Fake{
+		Dimensions: dimensions,
+		Responses:  responses,
+	}
+}
+
+func (f *Fake) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		embeddings = append(embeddings, f.embed(text))
+	}
+	return embeddings, nil
+}
+
+func (f *Fake) embed(text string) []float32 {
+	vector := make([]float32, f.Dimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		h := fnv.New32a()
+		h.Write([]byte(word))
+		vector[h.Sum32()%uint32(f.Dimensions)] += 1
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v * v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector
+}
+
+func (f *Fake) Complete(ctx context.Context, req ChatRequest) (string, error) {
+	f.mu.Lock()
+	n := len(f.requests)
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+
+	if f.Respond != nil {
+		return f.Respond(req)
+	}
+	if len(f.Responses) == 0 {
+		return "", nil
+	}
+	if n >= len(f.Responses) {
+		n = len(f.Responses) - 1
+	}
+	return f.Responses[n], nil
+}
+
+// Requests returns every chat request the fake has received so far.
+func (f *Fake) Requests() []ChatRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]ChatRequest(nil), f.requests...)
+}
@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"context"
+	"testing"
+)
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func TestFakeEmbedIsDeterministic(t *testing.T) {
+	fake := NewFake(64)
+
+	first, err := fake.Embed(context.Background(), []string{"The mitochondria is the powerhouse of the cell"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := fake.Embed(context.Background(), []string{"The mitochondria is the powerhouse of the cell"})
+
+	for i := range first[0] {
+		if first[0][i] != second[0][i] {
+			t.Fatalf("embedding differs at %d: %v != %v", i, first[0][i], second[0][i])
+		}
+	}
+}
+
+func TestFakeEmbedRanksSimilarTextsCloser(t *testing.T) {
+	fake := NewFake(256)
+
+	embeds, _ := fake.Embed(context.Background(), []string{
+		"photosynthesis in green plants",
+		"green plants use photosynthesis",
+		"the french revolution began in 1789",
+	})
+
+	if dot(embeds[0], embeds[1]) <= dot(embeds[0], embeds[2]) {
+		t.Errorf("expected related texts to score higher than unrelated ones")
+	}
+}
+
+func TestFakeCompleteServesResponsesInOrder(t *testing.T) {
+	fake := NewFake(8, "first", "second")
+	req := ChatRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}}
+
+	for _, want := range []string{"first", "second", "second"} {
+		got, err := fake.Complete(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+
+	if len(fake.Requests()) != 3 {
+		t.Errorf("expected 3 recorded requests, got %d", len(fake.Requests()))
+	}
+}
@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bjorndonald/test-maker-service/constants"
+)
+
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+type Message struct {
+	Role    string
+	Content string
+}
+
+// Schema asks the provider to constrain its output to a JSON schema.
+type Schema struct {
+	Name       string
+	Definition json.RawMessage
+}
+
+type ChatRequest struct {
+	Messages []Message
+	Schema   *Schema
+}
+
+// Embedder turns a batch of texts into embedding vectors, one per input and in
+// the same order.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// ChatCompleter runs a chat completion and returns the content of the first
+// choice.
+type ChatCompleter interface {
+	Complete(ctx context.Context, req ChatRequest) (string, error)
+}
+
+// New builds the embedder and chat completer selected by LLM_PROVIDER.
+func New(config *constants.Config) (Embedder, ChatCompleter, error) {
+	switch config.LLMProvider {
+	case "", "openai":
+		client := NewOpenAI(config.OpenAIKey, config.ChatModel, config.EmbeddingModel)
+		return client, client, nil
+	case "compatible":
+		if config.LLMBaseURL == "" {
+			return nil, nil, fmt.Errorf("LLM_BASE_URL is required for the compatible provider")
+		}
+		client := NewOpenAICompatible(config.LLMBaseURL, config.OpenAIKey, config.ChatModel, config.EmbeddingModel)
+		return client, client, nil
+	case "fake":
+		fake := NewFake(DefaultDimensions)
+		return fake, fake, nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown llm provider %q", config.LLMProvider)
+}
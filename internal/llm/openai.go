@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// OpenAI talks to the OpenAI API, or to any server exposing the same API such
+// as Ollama or vLLM.
+type OpenAI struct {
+	client         *openai.Client
+	chatModel      string
+	embeddingModel string
+}
+
+func NewOpenAI(apiKey, chatModel, embeddingModel string) *OpenAI {
+	return newOpenAI(openai.DefaultConfig(apiKey), chatModel, embeddingModel)
+}
+
+// NewOpenAICompatible points the client at a self-hosted OpenAI-compatible
+// server, e.g. http://localhost:11434/v1 for Ollama.
+func NewOpenAICompatible(baseURL, apiKey, chatModel, embeddingModel string) *OpenAI {
+	config := openai.DefaultConfig(apiKey)
+	config.BaseURL = baseURL
+	return newOpenAI(config, chatModel, embeddingModel)
+}
+
+func newOpenAI(config openai.ClientConfig, chatModel, embeddingModel string) *OpenAI {
+	if chatModel == "" {
+		chatModel = openai.GPT4oMini
+	}
+	if embeddingModel == "" {
+		embeddingModel = string(openai.AdaEmbeddingV2)
+	}
+
+	return &OpenAI{
+		client:         openai.NewClientWithConfig(config),
+		chatModel:      chatModel,
+		embeddingModel: embeddingModel,
+	}
+}
+
+func (o *OpenAI) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	resp, err := o.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Model: openai.EmbeddingModel(o.embeddingModel),
+		Input: texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(embeddings) {
+			return nil, errors.New("embedding index out of range")
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+
+	return embeddings, nil
+}
+
+func (o *OpenAI) Complete(ctx context.Context, req ChatRequest) (string, error) {
+	messages := make([]openai.ChatCompletionMessage, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    m.Role,
+			Content: m.Content,
+		})
+	}
+
+	request := openai.ChatCompletionRequest{
+		Model:    o.chatModel,
+		Messages: messages,
+	}
+	if req.Schema != nil {
+		request.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   req.Schema.Name,
+				Schema: req.Schema.Definition,
+			},
+		}
+	}
+
+	resp, err := o.client.CreateChatCompletion(ctx, request)
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", errors.New("chat completion returned no choices")
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
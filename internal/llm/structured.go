@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// DefaultAttempts is how many times CompleteJSON asks the model before giving
+// up on a response that does not match the schema.
+const DefaultAttempts = 3
+
+// StructuredOutputError is returned by CompleteJSON when no attempt produced
+// output matching the requested schema.
+type StructuredOutputError struct {
+	Attempts int
+	Problems []string
+}
+
+func (e *StructuredOutputError) Error() string {
+	return fmt.Sprintf("model output did not match the schema after %d attempts: %s", e.Attempts, strings.Join(e.Problems, "; "))
+}
+
+// CompleteJSON runs req with its Schema attached, validates the response
+// against that schema and decodes it into out. When the response is invalid
+// the problems are sent back to the model and it is asked to correct them, up
+// to attempts times in total.
+//
+// check may report problems the schema cannot express, such as duplicate
+// options. They are fed back to the model like schema violations but do not
+// fail the final attempt, so callers should still filter what remains
+// invalid.
+func CompleteJSON(ctx context.Context, completer ChatCompleter, req ChatRequest, attempts int, out interface{}, check func() []string) error {
+	if req.Schema == nil {
+		return errors.New("structured completion requires a schema")
+	}
+	if attempts < 1 {
+		attempts = DefaultAttempts
+	}
+
+	schema, err := jsonschema.CompileString(req.Schema.Name+".json", string(req.Schema.Definition))
+	if err != nil {
+		return fmt.Errorf("invalid schema %s: %w", req.Schema.Name, err)
+	}
+
+	messages := append([]Message(nil), req.Messages...)
+	var problems []string
+	for attempt := 1; attempt <= attempts; attempt++ {
+		content, err := completer.Complete(ctx, ChatRequest{Messages: messages, Schema: req.Schema})
+		if err != nil {
+			return err
+		}
+
+		problems = decode(schema, content, out)
+		if len(problems) == 0 && check != nil {
+			if semantic := check(); len(semantic) > 0 && attempt < attempts {
+				problems = semantic
+			}
+		}
+		if len(problems) == 0 {
+			return nil
+		}
+
+		messages = append(messages,
+			Message{Role: RoleAssistant, Content: content},
+			Message{Role: RoleUser, Content: repairPrompt(problems)},
+		)
+	}
+
+	return &StructuredOutputError{Attempts: attempts, Problems: problems}
+}
+
+func decode(schema *jsonschema.Schema, content string, out interface{}) []string {
+	raw := []byte(stripCodeFence(content))
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return []string{fmt.Sprintf("response is not valid JSON: %s", err)}
+	}
+
+	if err := schema.Validate(doc); err != nil {
+		var validationErr *jsonschema.ValidationError
+		if !errors.As(err, &validationErr) {
+			return []string{err.Error()}
+		}
+		return schemaProblems(validationErr)
+	}
+
+	// Start from the zero value, as decoding into what an earlier attempt
+	// left behind would keep the fields this one leaves out.
+	if value := reflect.ValueOf(out); value.Kind() == reflect.Pointer && !value.IsNil() {
+		value.Elem().SetZero()
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return []string{err.Error()}
+	}
+
+	return nil
+}
+
+// schemaProblems flattens a validation error into its leaf causes, which are
+// the ones that point at something the model can fix.
+func schemaProblems(err *jsonschema.ValidationError) []string {
+	if len(err.Causes) == 0 {
+		location := err.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		return []string{fmt.Sprintf("%s: %s", location, err.Message)}
+	}
+
+	var problems []string
+	for _, cause := range err.Causes {
+		problems = append(problems, schemaProblems(cause)...)
+	}
+	return problems
+}
+
+// stripCodeFence removes a surrounding markdown code fence, which some models
+// add even when asked for bare JSON.
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+
+	if i := strings.Index(content, "\n"); i >= 0 {
+		content = content[i+1:]
+	} else {
+		content = strings.TrimPrefix(content, "```")
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(content), "```"))
+}
+
+func repairPrompt(problems []string) string {
+	var prompt strings.Builder
+	prompt.WriteString("Your previous response did not match the required JSON schema:\n")
+	for _, problem := range problems {
+		prompt.WriteString("- ")
+		prompt.WriteString(problem)
+		prompt.WriteString("\n")
+	}
+	prompt.WriteString("Return the corrected JSON only.")
+	return prompt.String()
+}
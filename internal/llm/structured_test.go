@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var answerSchema = &Schema{
+	Name: "answers",
+	Definition: json.RawMessage(`{
+		"type": "object",
+		"properties": {"answers": {"type": "array", "items": {"type": "string"}, "minItems": 1}},
+		"required": ["answers"],
+		"additionalProperties": false
+	}`),
+}
+
+type answers struct {
+	Answers []string `json:"answers"`
+}
+
+func TestCompleteJSONKeepsApostrophesAndJSONWord(t *testing.T) {
+	fake := NewFake(8, "```json\n{\"answers\":[\"Newton's first law\",\"a json document\"]}\n```")
+
+	var out answers
+	err := CompleteJSON(context.Background(), fake, ChatRequest{Schema: answerSchema}, 3, &out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Answers[0] != "Newton's first law" || out.Answers[1] != "a json document" {
+		t.Errorf("answers were altered: %q", out.Answers)
+	}
+}
+
+func TestCompleteJSONRepairsInvalidOutput(t *testing.T) {
+	fake := NewFake(8, `{"answers":[]}`, `{"answers":["fixed"]}`)
+
+	var out answers
+	err := CompleteJSON(context.Background(), fake, ChatRequest{
+		Messages: []Message{{Role: RoleUser, Content: "answer"}},
+		Schema:   answerSchema,
+	}, 3, &out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Answers) != 1 || out.Answers[0] != "fixed" {
+		t.Errorf("unexpected answers %q", out.Answers)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(requests))
+	}
+	repair := requests[1].Messages[len(requests[1].Messages)-1]
+	if repair.Role != RoleUser || !strings.Contains(repair.Content, "/answers") {
+		t.Errorf("expected the repair prompt to name the invalid field, got %q", repair.Content)
+	}
+}
+
+func TestCompleteJSONFeedsBackCheckProblems(t *testing.T) {
+	fake := NewFake(8, `{"answers":["same","same"]}`, `{"answers":["one","two"]}`)
+
+	var out answers
+	err := CompleteJSON(context.Background(), fake, ChatRequest{Schema: answerSchema}, 3, &out, func() []string {
+		if out.Answers[0] == out.Answers[1] {
+			return []string{"answers must differ"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Requests()) != 2 || out.Answers[1] != "two" {
+		t.Errorf("expected the check to trigger a second attempt, got %q", out.Answers)
+	}
+}
+
+func TestCompleteJSONDropsFieldsOfRejectedAttempts(t *testing.T) {
+	schema := &Schema{
+		Name: "notes",
+		Definition: json.RawMessage(`{
+			"type": "object",
+			"properties": {"items": {"type": "array", "items": {
+				"type": "object",
+				"properties": {"text": {"type": "string"}, "note": {"type": "string"}, "pages": {"type": "array", "items": {"type": "integer"}}},
+				"required": ["text"]
+			}}},
+			"required": ["items"]
+		}`),
+	}
+	fake := NewFake(8, `{"items":[{"text":"rejected","note":"stale","pages":[3]}]}`, `{"items":[{"text":"accepted"}]}`)
+
+	var out struct {
+		Items []struct {
+			Text  string `json:"text"`
+			Note  string `json:"note"`
+			Pages []int  `json:"pages"`
+		} `json:"items"`
+	}
+	err := CompleteJSON(context.Background(), fake, ChatRequest{Schema: schema}, 3, &out, func() []string {
+		if out.Items[0].Text == "rejected" {
+			return []string{"try again"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Items) != 1 || out.Items[0].Text != "accepted" || out.Items[0].Note != "" || out.Items[0].Pages != nil {
+		t.Errorf("expected only the accepted attempt to be kept, got %+v", out.Items)
+	}
+}
+
+func TestCompleteJSONReturnsTypedErrorWhenAttemptsRunOut(t *testing.T) {
+	fake := NewFake(8, "not json at all")
+
+	var out answers
+	err := CompleteJSON(context.Background(), fake, ChatRequest{Schema: answerSchema}, 2, &out, nil)
+
+	var structuredErr *StructuredOutputError
+	if !errors.As(err, &structuredErr) {
+		t.Fatalf("expected a StructuredOutputError, got %v", err)
+	}
+	if structuredErr.Attempts != 2 || len(fake.Requests()) != 2 {
+		t.Errorf("expected 2 attempts, got %d (%d requests)", structuredErr.Attempts, len(fake.Requests()))
+	}
+}
@@ -1,6 +1,10 @@
 package middleware
 
 import (
+	"errors"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
 	"mime/multipart"
 	"net/http"
 
@@ -39,6 +43,63 @@ func FileUploadMiddleware() gin.HandlerFunc {
 	}
 }
 
+// LogoUploadMiddleware saves an uploaded PNG or JPEG logo with the other
+// assets, so test papers can print it.
+func LogoUploadMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		file, err := c.FormFile("logo")
+		if err != nil {
+			helpers.ReturnError(c, "Logo is required", err, http.StatusBadRequest)
+			c.Abort()
+			return
+		}
+
+		extension, err := logoExtension(file)
+		if err != nil {
+			helpers.ReturnError(c, "Invalid file format", err, http.StatusBadRequest)
+			c.Abort()
+			return
+		}
+
+		logo := uuid.New().String() + extension
+		err = c.SaveUploadedFile(file, helpers.LOGO_DIRECTORY+"/"+logo)
+		if err != nil {
+			helpers.ReturnError(c, "Logo is required", err, http.StatusBadRequest)
+			c.Abort()
+			return
+		}
+
+		c.Set("logo", logo)
+		c.Next()
+	}
+}
+
+var logoTypes = map[string]string{
+	"png":  ".png",
+	"jpeg": ".jpg",
+}
+
+// logoExtension reads the header of an uploaded logo and returns the
+// extension of its format. The type the client claims is ignored, since a file
+// that does not decode would make every paper printing it fail.
+func logoExtension(header *multipart.FileHeader) (string, error) {
+	file, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	_, format, err := image.DecodeConfig(file)
+	if err != nil {
+		return "", errors.New("logo must be a PNG or JPEG image")
+	}
+	extension, ok := logoTypes[format]
+	if !ok {
+		return "", errors.New("logo must be a PNG or JPEG image")
+	}
+	return extension, nil
+}
+
 func isValidImage(header *multipart.FileHeader) bool {
 	validTypes := map[string]bool{
 		"application/pdf": true,
@@ -0,0 +1,14 @@
+package models
+
+// Grounding is how well the material a question was generated from supports
+// its answer.
+type Grounding string
+
+const (
+	Supported          Grounding = "supported"
+	PartiallySupported Grounding = "partially_supported"
+	Unsupported        Grounding = "unsupported"
+)
+
+// Groundings lists every verdict from best to worst.
+var Groundings = []Grounding{Supported, PartiallySupported, Unsupported}
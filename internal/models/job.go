@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type JobKind string
+
+const (
+	AnalyzeJob JobKind = "analyze"
+	EmbedJob   JobKind = "embed"
+)
+
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed"
+)
+
+type Job struct {
+	Id         uuid.UUID
+	Kind       JobKind
+	Status     JobStatus
+	Payload    json.RawMessage
+	Result     json.RawMessage
+	Error      string
+	PagesDone  int
+	PagesTotal int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type AnalyzedPDF struct {
+	Id            string   `json:"id"`
+	NumberOfPages int      `json:"numberOfPages"`
+	Pdfs          []string `json:"pdfs"`
+}
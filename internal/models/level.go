@@ -0,0 +1,28 @@
+package models
+
+type Difficulty string
+
+const (
+	Easy   Difficulty = "easy"
+	Medium Difficulty = "medium"
+	Hard   Difficulty = "hard"
+)
+
+// Difficulties lists every difficulty from easiest to hardest.
+var Difficulties = []Difficulty{Easy, Medium, Hard}
+
+// CognitiveLevel is a level of the revised Bloom's taxonomy, the kind of
+// thinking a question asks for.
+type CognitiveLevel string
+
+const (
+	Remember   CognitiveLevel = "remember"
+	Understand CognitiveLevel = "understand"
+	Apply      CognitiveLevel = "apply"
+	Analyze    CognitiveLevel = "analyze"
+	Evaluate   CognitiveLevel = "evaluate"
+	Create     CognitiveLevel = "create"
+)
+
+// CognitiveLevels lists every level from lowest to highest.
+var CognitiveLevels = []CognitiveLevel{Remember, Understand, Apply, Analyze, Evaluate, Create}
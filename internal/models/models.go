@@ -6,11 +6,22 @@ import (
 	"github.com/google/uuid"
 )
 
+// Chunk is an embedded piece of a document. PageFrom and PageTo are the pages
+// it was taken from, and Start and End its character offsets in the text of
+// PageFrom. Chunks embedded before they were tracked have no pages. Score is
+// how well the chunk matched the search that returned it, higher is better.
 type Chunk struct {
 	Id             uuid.UUID
 	DocumentId     uuid.UUID
 	Chunk          string
 	ChunkEmbedding []float32
+	PageFrom       int
+	PageTo         int
+	Start          int
+	End            int
+	Strategy       string
+	Heading        string
+	Score          float64
 }
 
 type Document struct {
@@ -0,0 +1,46 @@
+// Package modelstest holds the fixtures shared by the tests of the packages
+// that read and write questions.
+package modelstest
+
+import "github.com/bjorndonald/test-maker-service/internal/models"
+
+// Index returns a pointer to i, for Question.CorrectIndex.
+func Index(i int) *int {
+	return &i
+}
+
+// Boolean returns a pointer to b, for Question.IsTrue.
+func Boolean(b bool) *bool {
+	return &b
+}
+
+// Questions returns a fresh, valid and normalized question of every type, in
+// the order of models.QuestionTypes.
+func Questions() []models.Question {
+	questions := []models.Question{
+		{
+			Type:         models.MultipleChoice,
+			Question:     "Which gas do plants absorb?",
+			Options:      []string{"Oxygen", "Carbon dioxide", "Nitrogen"},
+			CorrectIndex: Index(1),
+			Feedback:     "Plants use it for photosynthesis.",
+		},
+		{Type: models.TrueFalse, Question: "The nucleus holds the cell's DNA.", IsTrue: Boolean(true)},
+		{Type: models.FillInTheBlank, Question: "The ___ is the powerhouse of the cell.", Blanks: []string{"mitochondrion"}},
+		{
+			Type:     models.Matching,
+			Question: "Match each organelle to its function.",
+			Pairs: []models.MatchPair{
+				{Left: "Ribosome", Right: "Makes proteins"},
+				{Left: "Lysosome", Right: "Digests waste"},
+				{Left: "Nucleus", Right: "Stores DNA"},
+			},
+		},
+		{Type: models.Ordering, Question: "Order the phases of mitosis.", Sequence: []string{"Prophase", "Metaphase", "Anaphase", "Telophase"}},
+		{Type: models.ShortAnswer, Question: "What does ATP stand for?", Answer: "Adenosine triphosphate"},
+	}
+	for i := range questions {
+		questions[i].Normalize()
+	}
+	return questions
+}
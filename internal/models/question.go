@@ -0,0 +1,281 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type QuestionType string
+
+const (
+	ShortAnswer    QuestionType = "short_answer"
+	MultipleChoice QuestionType = "multiple_choice"
+	TrueFalse      QuestionType = "true_false"
+	FillInTheBlank QuestionType = "fill_in_the_blank"
+	Matching       QuestionType = "matching"
+	Ordering       QuestionType = "ordering"
+)
+
+// QuestionTypes lists every supported type in the order they are generated
+// and presented.
+var QuestionTypes = []QuestionType{
+	MultipleChoice,
+	TrueFalse,
+	FillInTheBlank,
+	Matching,
+	Ordering,
+	ShortAnswer,
+}
+
+// Label is the human readable name of the type, as used in prompts.
+func (t QuestionType) Label() string {
+	switch t {
+	case MultipleChoice:
+		return "multiple choice"
+	case TrueFalse:
+		return "true or false"
+	case FillInTheBlank:
+		return "fill in the blank"
+	case Matching:
+		return "matching"
+	case Ordering:
+		return "ordering"
+	}
+	return "short answer"
+}
+
+const (
+	MinOptions = 3
+	MaxOptions = 5
+
+	MinPairs = 3
+	MaxPairs = 6
+
+	MinSequenceSize = 3
+	MaxSequenceSize = 6
+
+	// BlankMarker is how a fill in the blank question marks each gap.
+	BlankMarker = "___"
+)
+
+// BlankPattern matches the gaps of a fill in the blank question.
+var BlankPattern = regexp.MustCompile(`_{3,}`)
+
+// Source is a passage of the document a question was generated from, so it
+// can be checked against the book.
+type Source struct {
+	Page    int    `json:"page"`
+	Excerpt string `json:"excerpt"`
+}
+
+type MatchPair struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+// Question is a single generated or curated test item. Which of the answer
+// fields are used depends on Type:
+//
+//   - short_answer: Answer
+//   - multiple_choice: Options and CorrectIndex
+//   - true_false: IsTrue
+//   - fill_in_the_blank: Blanks, one per BlankMarker in Question
+//   - matching: Pairs
+//   - ordering: Sequence, in the correct order
+//
+// Answer is always filled in by Normalize with a readable form of the answer,
+// and Feedback optionally explains it. Sources are the passages the question
+// was generated from. Difficulty and CognitiveLevel are set when they were
+// asked for, and Grounding once the answer is checked against the sources.
+// Id and Position are set once the question is saved to a test.
+type Question struct {
+	Id           uuid.UUID    `json:"id"`
+	Position     int          `json:"position"`
+	Type         QuestionType `json:"type"`
+	Question     string       `json:"question"`
+	Answer       string       `json:"answer"`
+	Options      []string     `json:"options,omitempty"`
+	CorrectIndex *int         `json:"correct_index,omitempty"`
+	IsTrue       *bool        `json:"is_true,omitempty"`
+	Blanks       []string     `json:"blanks,omitempty"`
+	Pairs        []MatchPair  `json:"pairs,omitempty"`
+	Sequence     []string     `json:"sequence,omitempty"`
+	Feedback     string       `json:"feedback,omitempty"`
+	Sources      []Source     `json:"sources,omitempty"`
+
+	Difficulty     Difficulty     `json:"difficulty,omitempty"`
+	CognitiveLevel CognitiveLevel `json:"cognitive_level,omitempty"`
+	Grounding      Grounding      `json:"grounding,omitempty"`
+}
+
+// Normalize derives Answer from the type specific fields.
+func (q *Question) Normalize() {
+	if q.Type == "" {
+		q.Type = ShortAnswer
+	}
+
+	switch q.Type {
+	case MultipleChoice:
+		if q.CorrectIndex != nil && *q.CorrectIndex >= 0 && *q.CorrectIndex < len(q.Options) {
+			q.Answer = q.Options[*q.CorrectIndex]
+		}
+	case TrueFalse:
+		if q.IsTrue != nil {
+			q.Answer = "False"
+			if *q.IsTrue {
+				q.Answer = "True"
+			}
+		}
+	case FillInTheBlank:
+		if len(q.Blanks) > 0 {
+			q.Answer = strings.Join(q.Blanks, "; ")
+		}
+	case Matching:
+		if len(q.Pairs) > 0 {
+			pairs := make([]string, 0, len(q.Pairs))
+			for _, p := range q.Pairs {
+				pairs = append(pairs, p.Left+" - "+p.Right)
+			}
+			q.Answer = strings.Join(pairs, "; ")
+		}
+	case Ordering:
+		if len(q.Sequence) > 0 {
+			q.Answer = strings.Join(q.Sequence, " > ")
+		}
+	}
+}
+
+// Validate checks that the question is well formed for its type. For multiple
+// choice questions the distractors must be distinct from the answer and from
+// each other.
+func (q Question) Validate() error {
+	if strings.TrimSpace(q.Question) == "" {
+		return errors.New("question is required")
+	}
+
+	switch q.Type {
+	case ShortAnswer, "":
+		if strings.TrimSpace(q.Answer) == "" {
+			return errors.New("answer is required")
+		}
+	case MultipleChoice:
+		return q.validateMultipleChoice()
+	case TrueFalse:
+		if q.IsTrue == nil {
+			return errors.New("is_true is required")
+		}
+	case FillInTheBlank:
+		return q.validateFillInTheBlank()
+	case Matching:
+		return q.validateMatching()
+	case Ordering:
+		return q.validateOrdering()
+	default:
+		return fmt.Errorf("unknown question type %q", q.Type)
+	}
+
+	return nil
+}
+
+func (q Question) validateMultipleChoice() error {
+	if len(q.Options) < MinOptions || len(q.Options) > MaxOptions {
+		return fmt.Errorf("multiple choice questions need between %d and %d options, got %d", MinOptions, MaxOptions, len(q.Options))
+	}
+	if q.CorrectIndex == nil {
+		return errors.New("correct_index is required")
+	}
+	if *q.CorrectIndex < 0 || *q.CorrectIndex >= len(q.Options) {
+		return fmt.Errorf("correct_index %d is out of range", *q.CorrectIndex)
+	}
+
+	seen := map[string]int{}
+	for i, option := range q.Options {
+		key := normalizeOption(option)
+		if key == "" {
+			return fmt.Errorf("option %d is empty", i)
+		}
+		if j, ok := seen[key]; ok {
+			if j == *q.CorrectIndex || i == *q.CorrectIndex {
+				return fmt.Errorf("distractor %d repeats the correct answer", i)
+			}
+			return fmt.Errorf("options %d and %d are the same", j, i)
+		}
+		seen[key] = i
+	}
+
+	if q.Answer != "" && normalizeOption(q.Answer) != normalizeOption(q.Options[*q.CorrectIndex]) {
+		return errors.New("answer does not match the option at correct_index")
+	}
+
+	return nil
+}
+
+func (q Question) validateFillInTheBlank() error {
+	gaps := len(BlankPattern.FindAllStringIndex(q.Question, -1))
+	if gaps == 0 {
+		return fmt.Errorf("question must mark each blank with %s", BlankMarker)
+	}
+	if gaps != len(q.Blanks) {
+		return fmt.Errorf("question has %d blanks but %d answers", gaps, len(q.Blanks))
+	}
+	for i, blank := range q.Blanks {
+		if strings.TrimSpace(blank) == "" {
+			return fmt.Errorf("blank %d has no answer", i)
+		}
+	}
+	return nil
+}
+
+func (q Question) validateMatching() error {
+	if len(q.Pairs) < MinPairs || len(q.Pairs) > MaxPairs {
+		return fmt.Errorf("matching questions need between %d and %d pairs, got %d", MinPairs, MaxPairs, len(q.Pairs))
+	}
+
+	lefts := map[string]bool{}
+	rights := map[string]bool{}
+	for i, pair := range q.Pairs {
+		left, right := normalizeOption(pair.Left), normalizeOption(pair.Right)
+		if left == "" || right == "" {
+			return fmt.Errorf("pair %d is incomplete", i)
+		}
+		if lefts[left] {
+			return fmt.Errorf("pair %d repeats the prompt %q", i, pair.Left)
+		}
+		if rights[right] {
+			return fmt.Errorf("pair %d repeats the match %q", i, pair.Right)
+		}
+		lefts[left] = true
+		rights[right] = true
+	}
+	return nil
+}
+
+func (q Question) validateOrdering() error {
+	if len(q.Sequence) < MinSequenceSize || len(q.Sequence) > MaxSequenceSize {
+		return fmt.Errorf("ordering questions need between %d and %d items, got %d", MinSequenceSize, MaxSequenceSize, len(q.Sequence))
+	}
+
+	seen := map[string]bool{}
+	for i, item := range q.Sequence {
+		key := normalizeOption(item)
+		if key == "" {
+			return fmt.Errorf("item %d is empty", i)
+		}
+		if seen[key] {
+			return fmt.Errorf("item %d appears more than once", i)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// normalizeOption folds case, surrounding punctuation and whitespace so that
+// "Paris." and " paris" count as the same option.
+func normalizeOption(option string) string {
+	option = strings.ToLower(strings.Join(strings.Fields(option), " "))
+	return strings.Trim(option, ".,;:!?\"'")
+}
@@ -0,0 +1,176 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/models/modelstest"
+)
+
+func TestValidateMultipleChoice(t *testing.T) {
+	tests := []struct {
+		name     string
+		question models.Question
+		valid    bool
+	}{
+		{
+			name: "valid",
+			question: models.Question{
+				Type: models.MultipleChoice, Question: "2+2?",
+				Options: []string{"3", "4", "5"}, CorrectIndex: modelstest.Index(1),
+			},
+			valid: true,
+		},
+		{
+			name: "too few options",
+			question: models.Question{
+				Type: models.MultipleChoice, Question: "2+2?",
+				Options: []string{"3", "4"}, CorrectIndex: modelstest.Index(1),
+			},
+		},
+		{
+			name: "too many options",
+			question: models.Question{
+				Type: models.MultipleChoice, Question: "2+2?",
+				Options: []string{"1", "2", "3", "4", "5", "6"}, CorrectIndex: modelstest.Index(3),
+			},
+		},
+		{
+			name: "missing correct index",
+			question: models.Question{
+				Type: models.MultipleChoice, Question: "2+2?",
+				Options: []string{"3", "4", "5"},
+			},
+		},
+		{
+			name: "correct index out of range",
+			question: models.Question{
+				Type: models.MultipleChoice, Question: "2+2?",
+				Options: []string{"3", "4", "5"}, CorrectIndex: modelstest.Index(3),
+			},
+		},
+		{
+			name: "distractor restates answer",
+			question: models.Question{
+				Type: models.MultipleChoice, Question: "Capital of France?",
+				Options: []string{"Paris", "Lyon", " paris."}, CorrectIndex: modelstest.Index(0),
+			},
+		},
+		{
+			name: "duplicate distractors",
+			question: models.Question{
+				Type: models.MultipleChoice, Question: "Capital of France?",
+				Options: []string{"Paris", "Lyon", "LYON"}, CorrectIndex: modelstest.Index(0),
+			},
+		},
+		{
+			name: "answer disagrees with correct index",
+			question: models.Question{
+				Type: models.MultipleChoice, Question: "Capital of France?", Answer: "Lyon",
+				Options: []string{"Paris", "Lyon", "Nice"}, CorrectIndex: modelstest.Index(0),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.question.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestValidateTypedQuestions(t *testing.T) {
+	tests := []struct {
+		name     string
+		question models.Question
+		valid    bool
+	}{
+		{
+			name:     "true false",
+			question: models.Question{Type: models.TrueFalse, Question: "The sun is a star.", IsTrue: modelstest.Boolean(true)},
+			valid:    true,
+		},
+		{
+			name:     "true false without verdict",
+			question: models.Question{Type: models.TrueFalse, Question: "The sun is a star."},
+		},
+		{
+			name:     "cloze with two blanks",
+			question: models.Question{Type: models.FillInTheBlank, Question: "___ is the capital of ____.", Blanks: []string{"Paris", "France"}},
+			valid:    true,
+		},
+		{
+			name:     "cloze with missing answers",
+			question: models.Question{Type: models.FillInTheBlank, Question: "___ is the capital of ___.", Blanks: []string{"Paris"}},
+		},
+		{
+			name:     "cloze without blanks",
+			question: models.Question{Type: models.FillInTheBlank, Question: "Paris is the capital of France.", Blanks: []string{"Paris"}},
+		},
+		{
+			name: "matching",
+			question: models.Question{Type: models.Matching, Question: "Match the capitals.", Pairs: []models.MatchPair{
+				{Left: "France", Right: "Paris"}, {Left: "Spain", Right: "Madrid"}, {Left: "Italy", Right: "Rome"},
+			}},
+			valid: true,
+		},
+		{
+			name: "matching with too few pairs",
+			question: models.Question{Type: models.Matching, Question: "Match the capitals.", Pairs: []models.MatchPair{
+				{Left: "France", Right: "Paris"}, {Left: "Spain", Right: "Madrid"},
+			}},
+		},
+		{
+			name: "matching with ambiguous right side",
+			question: models.Question{Type: models.Matching, Question: "Match the capitals.", Pairs: []models.MatchPair{
+				{Left: "France", Right: "Paris"}, {Left: "Spain", Right: "Madrid"}, {Left: "Italy", Right: "paris"},
+			}},
+		},
+		{
+			name:     "ordering",
+			question: models.Question{Type: models.Ordering, Question: "Order the planets.", Sequence: []string{"Mercury", "Venus", "Earth"}},
+			valid:    true,
+		},
+		{
+			name:     "ordering with too many items",
+			question: models.Question{Type: models.Ordering, Question: "Order the planets.", Sequence: []string{"Mercury", "Venus", "Earth", "Mars", "Jupiter", "Saturn", "Uranus"}},
+		},
+		{
+			name:     "ordering with repeats",
+			question: models.Question{Type: models.Ordering, Question: "Order the planets.", Sequence: []string{"Mercury", "Venus", "mercury"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.question.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestFixtureQuestionsAreValid(t *testing.T) {
+	questions := modelstest.Questions()
+	if len(questions) != len(models.QuestionTypes) {
+		t.Fatalf("expected a question of each of the %d types, got %d", len(models.QuestionTypes), len(questions))
+	}
+	for i, q := range questions {
+		if q.Type != models.QuestionTypes[i] {
+			t.Errorf("expected question %d to be %s, got %s", i, models.QuestionTypes[i], q.Type)
+		}
+		if err := q.Validate(); err != nil {
+			t.Errorf("expected the %s fixture to be valid, got %v", q.Type, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+func stringSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "string", "minLength": 1}
+}
+
+func arraySchema(items map[string]interface{}, minItems int, maxItems int) map[string]interface{} {
+	schema := map[string]interface{}{"type": "array", "items": items}
+	if minItems > 0 {
+		schema["minItems"] = minItems
+	}
+	if maxItems > 0 {
+		schema["maxItems"] = maxItems
+	}
+	return schema
+}
+
+// objectSchema requires every property except the optional ones.
+func objectSchema(properties map[string]interface{}, optional ...string) map[string]interface{} {
+	required := make([]string, 0, len(properties))
+	for name := range properties {
+		if !contains(optional, name) {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// questionSchema adds the properties shared by every question type.
+// citations are the numbers of the passages of the context a question comes
+// from.
+func questionSchema(properties map[string]interface{}) map[string]interface{} {
+	if _, ok := properties["question"]; !ok {
+		properties["question"] = stringSchema()
+	}
+	properties["feedback"] = map[string]interface{}{"type": "string"}
+	properties["citations"] = arraySchema(map[string]interface{}{"type": "integer", "minimum": 1}, 0, 0)
+	return objectSchema(properties, "feedback", "citations")
+}
+
+// itemSchema describes the JSON shape of a single question of the given type
+// as the model is expected to produce it.
+func itemSchema(questionType QuestionType) map[string]interface{} {
+	switch questionType {
+	case MultipleChoice:
+		return questionSchema(map[string]interface{}{
+			"options":       arraySchema(stringSchema(), MinOptions, MaxOptions),
+			"correct_index": map[string]interface{}{"type": "integer", "minimum": 0, "maximum": MaxOptions - 1},
+		})
+	case TrueFalse:
+		return questionSchema(map[string]interface{}{
+			"is_true": map[string]interface{}{"type": "boolean"},
+		})
+	case FillInTheBlank:
+		return questionSchema(map[string]interface{}{
+			"question": map[string]interface{}{"type": "string", "pattern": "_{3,}"},
+			"blanks":   arraySchema(stringSchema(), 1, 0),
+		})
+	case Matching:
+		pair := objectSchema(map[string]interface{}{
+			"left":  stringSchema(),
+			"right": stringSchema(),
+		})
+		return questionSchema(map[string]interface{}{
+			"pairs": arraySchema(pair, MinPairs, MaxPairs),
+		})
+	case Ordering:
+		return questionSchema(map[string]interface{}{
+			"sequence": arraySchema(stringSchema(), MinSequenceSize, MaxSequenceSize),
+		})
+	}
+	return questionSchema(map[string]interface{}{
+		"answer": stringSchema(),
+	})
+}
+
+// QuestionSchema returns the JSON schema of a generation response holding
+// questions of the given type.
+func QuestionSchema(questionType QuestionType) json.RawMessage {
+	schema, _ := json.Marshal(objectSchema(map[string]interface{}{
+		"questions": arraySchema(itemSchema(questionType), 0, 0),
+	}))
+	return schema
+}
+
+// ClassificationSchema returns the JSON schema of a response judging the
+// difficulty and cognitive level of a list of questions, each referred to by
+// its index.
+func ClassificationSchema() json.RawMessage {
+	item := objectSchema(map[string]interface{}{
+		"index":           map[string]interface{}{"type": "integer", "minimum": 0},
+		"difficulty":      map[string]interface{}{"type": "string", "enum": Difficulties},
+		"cognitive_level": map[string]interface{}{"type": "string", "enum": CognitiveLevels},
+	})
+	schema, _ := json.Marshal(objectSchema(map[string]interface{}{
+		"classifications": arraySchema(item, 0, 0),
+	}))
+	return schema
+}
+
+// GroundingSchema returns the JSON schema of a response judging whether the
+// answers of a list of questions are supported, each referred to by its index.
+func GroundingSchema() json.RawMessage {
+	item := objectSchema(map[string]interface{}{
+		"index":     map[string]interface{}{"type": "integer", "minimum": 0},
+		"grounding": map[string]interface{}{"type": "string", "enum": Groundings},
+		"reason":    map[string]interface{}{"type": "string"},
+	}, "reason")
+	schema, _ := json.Marshal(objectSchema(map[string]interface{}{
+		"verdicts": arraySchema(item, 0, 0),
+	}))
+	return schema
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+func TestQuestionSchemaAcceptsEachType(t *testing.T) {
+	samples := map[QuestionType]string{
+		ShortAnswer:    `{"questions":[{"question":"What is 2+2?","answer":"4"}]}`,
+		MultipleChoice: `{"questions":[{"question":"2+2?","options":["3","4","5"],"correct_index":1}]}`,
+		TrueFalse:      `{"questions":[{"question":"2+2 is 4.","is_true":true}]}`,
+		FillInTheBlank: `{"questions":[{"question":"2+2 is ___.","blanks":["4"]}]}`,
+		Matching:       `{"questions":[{"question":"Match.","pairs":[{"left":"a","right":"1"},{"left":"b","right":"2"},{"left":"c","right":"3"}]}]}`,
+		Ordering:       `{"questions":[{"question":"Order.","sequence":["1","2","3"]}]}`,
+	}
+
+	for _, questionType := range QuestionTypes {
+		t.Run(string(questionType), func(t *testing.T) {
+			schema, err := jsonschema.CompileString("schema.json", string(QuestionSchema(questionType)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var doc interface{}
+			if err := json.Unmarshal([]byte(samples[questionType]), &doc); err != nil {
+				t.Fatal(err)
+			}
+			if err := schema.Validate(doc); err != nil {
+				t.Errorf("expected sample to validate: %v", err)
+			}
+
+			var other interface{}
+			json.Unmarshal([]byte(`{"questions":[{"question":"?"}]}`), &other)
+			if err := schema.Validate(other); err == nil {
+				t.Error("expected an incomplete question to be rejected")
+			}
+		})
+	}
+}
+
+func TestClassificationSchema(t *testing.T) {
+	schema, err := jsonschema.CompileString("schema.json", string(ClassificationSchema()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for sample, valid := range map[string]bool{
+		`{"classifications":[{"index":0,"difficulty":"easy","cognitive_level":"analyze"}]}`:   true,
+		`{"classifications":[{"index":0,"difficulty":"simple","cognitive_level":"analyze"}]}`: false,
+		`{"classifications":[{"index":0,"difficulty":"easy","cognitive_level":"recall"}]}`:    false,
+		`{"classifications":[{"difficulty":"easy","cognitive_level":"analyze"}]}`:             false,
+	} {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(sample), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if err := schema.Validate(doc); (err == nil) != valid {
+			t.Errorf("expected %s to be valid: %v, got %v", sample, valid, err)
+		}
+	}
+}
+
+func TestGroundingSchema(t *testing.T) {
+	schema, err := jsonschema.CompileString("schema.json", string(GroundingSchema()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for sample, valid := range map[string]bool{
+		`{"verdicts":[{"index":0,"grounding":"supported"}]}`:                         true,
+		`{"verdicts":[{"index":1,"grounding":"unsupported","reason":"not stated"}]}`: true,
+		`{"verdicts":[{"index":0,"grounding":"maybe"}]}`:                             false,
+	} {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(sample), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if err := schema.Validate(doc); (err == nil) != valid {
+			t.Errorf("expected %s to be valid: %v, got %v", sample, valid, err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Test is a saved set of questions generated from a document. Questions are
+// kept in Position order, starting at 1.
+type Test struct {
+	Id         uuid.UUID  `json:"id"`
+	DocumentId uuid.UUID  `json:"document_id"`
+	Title      string     `json:"title"`
+	Subjects   []string   `json:"subjects"`
+	Questions  []Question `json:"questions,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// CognitiveLevels counts the questions of the test at each level of Bloom's
+// taxonomy. Questions without a level are left out.
+func (t Test) CognitiveLevels() map[CognitiveLevel]int {
+	levels := map[CognitiveLevel]int{}
+	for _, q := range t.Questions {
+		if q.CognitiveLevel != "" {
+			levels[q.CognitiveLevel]++
+		}
+	}
+	return levels
+}
@@ -0,0 +1,48 @@
+package models
+
+import "github.com/google/uuid"
+
+// Variant is a version of a saved test with its questions, and the options,
+// matches and items within them, shuffled. It holds only what students see,
+// the answers are in its AnswerKey. The same test and seed always produce the
+// same variant.
+type Variant struct {
+	TestId    uuid.UUID         `json:"test_id"`
+	Code      string            `json:"code"`
+	Seed      int64             `json:"seed"`
+	Title     string            `json:"title"`
+	Questions []VariantQuestion `json:"questions"`
+}
+
+// VariantQuestion is a question as printed on a variant. Options are the
+// choices of a multiple choice question, labelled A, B, C and so on. A
+// matching question pairs each of its numbered Prompts with one of its
+// lettered Matches, and an ordering question puts its lettered Items in order.
+type VariantQuestion struct {
+	Number   int          `json:"number"`
+	Type     QuestionType `json:"type"`
+	Question string       `json:"question"`
+	Options  []string     `json:"options,omitempty"`
+	Prompts  []string     `json:"prompts,omitempty"`
+	Matches  []string     `json:"matches,omitempty"`
+	Items    []string     `json:"items,omitempty"`
+}
+
+// AnswerKey lists the answers of a variant in the order its questions are
+// printed.
+type AnswerKey struct {
+	TestId  uuid.UUID   `json:"test_id"`
+	Code    string      `json:"code"`
+	Answers []KeyAnswer `json:"answers"`
+}
+
+// KeyAnswer is the answer to one question of a variant. Choice is the letter
+// of the correct option of multiple choice questions, the prompt numbers with
+// the letters of their matches for matching questions, such as "1-C, 2-A",
+// and the letters of the items in order for ordering questions.
+type KeyAnswer struct {
+	Number     int       `json:"number"`
+	QuestionId uuid.UUID `json:"question_id"`
+	Choice     string    `json:"choice,omitempty"`
+	Answer     string    `json:"answer"`
+}
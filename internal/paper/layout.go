@@ -0,0 +1,224 @@
+package paper
+
+import (
+	"strings"
+
+	pdffont "github.com/pdfcpu/pdfcpu/pkg/font"
+)
+
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 48.0
+	gutter     = 20.0
+	logoHeight = 48.0
+
+	regular = "Helvetica"
+	bold    = "Helvetica-Bold"
+	italic  = "Helvetica-Oblique"
+)
+
+// line is one row of a block: text, or a rule to write an answer on when
+// rule is set.
+type line struct {
+	text   string
+	font   string
+	size   int
+	indent float64
+	rule   bool
+	// gap is extra space left above the line.
+	gap float64
+}
+
+// block is a question or answer, kept in one column when it fits.
+type block []line
+
+// layout flows blocks down the columns of successive pages.
+type layout struct {
+	opts   Options
+	pages  []*content
+	column int
+	top    float64
+	y      float64
+	width  float64
+}
+
+func newLayout(opts Options) *layout {
+	l := &layout{
+		opts:  opts,
+		width: (pageWidth - 2*margin - gutter*float64(opts.Columns-1)) / float64(opts.Columns),
+	}
+	l.newPage()
+	return l
+}
+
+func (l *layout) newPage() {
+	l.pages = append(l.pages, &content{})
+	l.column = 0
+	l.top = pageHeight - margin
+	l.y = l.top
+}
+
+func (l *layout) nextColumn() {
+	l.column++
+	if l.column == l.opts.Columns {
+		l.newPage()
+		return
+	}
+	l.y = l.top
+}
+
+func (l *layout) current() *content {
+	return l.pages[len(l.pages)-1]
+}
+
+func (l *layout) x() float64 {
+	return margin + float64(l.column)*(l.width+gutter)
+}
+
+func (l *layout) bottom() float64 {
+	return margin
+}
+
+func lineHeight(ln line) float64 {
+	height := pdffont.LineHeight(ln.font, ln.size)
+	if ln.rule {
+		height *= 1.8
+	}
+	return height + ln.gap
+}
+
+func (b block) height() float64 {
+	var h float64
+	for _, ln := range b {
+		h += lineHeight(ln)
+	}
+	return h
+}
+
+// header sets the title block across the full width of the first page. The
+// body starts below it.
+func (l *layout) header(title string, subtitle string, fields bool) {
+	page := l.current()
+	size := l.opts.FontSize
+
+	if l.opts.Logo != "" {
+		l.y -= logoHeight
+		page.Image = append(page.Image, imageBox{Src: l.opts.Logo, Pos: [2]float64{margin, l.y}, Height: logoHeight})
+		l.y -= float64(size)
+	}
+
+	full := pageWidth - 2*margin
+	for _, text := range wrap(title, bold, size+6, full) {
+		l.y -= pdffont.LineHeight(bold, size+6)
+		page.Text = append(page.Text, textBox{Value: escape(text), Pos: [2]float64{-1, l.y}, Font: font{Name: bold, Size: size + 6}, Align: "center"})
+	}
+	if subtitle != "" {
+		for _, text := range wrap(subtitle, regular, size, full) {
+			l.y -= pdffont.LineHeight(regular, size)
+			page.Text = append(page.Text, textBox{Value: escape(text), Pos: [2]float64{-1, l.y}, Font: font{Name: regular, Size: size}, Align: "center"})
+		}
+	}
+
+	if fields {
+		l.y -= 2 * pdffont.LineHeight(regular, size)
+		x := margin
+		for _, field := range []struct {
+			label string
+			width float64
+		}{{"Name:", full * 0.6}, {"Date:", full * 0.4}} {
+			label := pdffont.TextWidth(field.label, bold, size) + 6
+			page.Text = append(page.Text, textBox{Value: field.label, Pos: [2]float64{x, l.y}, Font: font{Name: bold, Size: size}})
+			page.Box = append(page.Box, box{Pos: [2]float64{x + label, l.y + 2}, Width: field.width - label - gutter, Height: 0.6, FillCol: "#000000"})
+			x += field.width
+		}
+	}
+
+	l.y -= float64(size)
+	page.Box = append(page.Box, box{Pos: [2]float64{margin, l.y}, Width: full, Height: 1, FillCol: "#000000"})
+	l.y -= float64(size)
+	l.top = l.y
+}
+
+// place sets a block below the previous one. A block that does not fit in
+// what is left of the column starts the next one, unless it would not fit in
+// a whole column either, in which case it is split.
+func (l *layout) place(b block) {
+	if l.y < l.top {
+		spacing := float64(l.opts.FontSize)
+		if l.y-spacing-b.height() < l.bottom() && b.height() <= l.top-l.bottom() {
+			l.nextColumn()
+		} else {
+			l.y -= spacing
+		}
+	}
+
+	for _, ln := range b {
+		if l.y-lineHeight(ln) < l.bottom() {
+			l.nextColumn()
+		}
+		l.y -= lineHeight(ln)
+
+		page := l.current()
+		x := l.x() + ln.indent
+		if ln.rule {
+			page.Box = append(page.Box, box{Pos: [2]float64{x, l.y + 2}, Width: l.width - ln.indent, Height: 0.6, FillCol: "#808080"})
+			continue
+		}
+		page.Text = append(page.Text, textBox{Value: escape(ln.text), Pos: [2]float64{x, l.y}, Font: font{Name: ln.font, Size: ln.size}})
+	}
+}
+
+// wrap breaks text into lines no wider than width. Words longer than a line
+// are broken between characters.
+func wrap(text string, fontName string, size int, width float64) []string {
+	var lines []string
+	current := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+		if pdffont.TextWidth(candidate, fontName, size) <= width {
+			current = candidate
+			continue
+		}
+		if current != "" {
+			lines = append(lines, current)
+		}
+
+		current = ""
+		for _, r := range word {
+			if current != "" && pdffont.TextWidth(current+string(r), fontName, size) > width {
+				lines = append(lines, current)
+				current = ""
+			}
+			current += string(r)
+		}
+	}
+	if current != "" || len(lines) == 0 {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// labelled wraps text into the lines of a block, with label printed before
+// the first line. The other lines hang under the text rather than the label.
+func (l *layout) labelled(label string, text string, fontName string, indent float64) block {
+	var b block
+	size := l.opts.FontSize
+	hang := 0.0
+	if label != "" {
+		label += " "
+		hang = pdffont.TextWidth(label, fontName, size)
+	}
+
+	for i, text := range wrap(text, fontName, size, l.width-indent-hang) {
+		ln := line{text: text, font: fontName, size: size, indent: indent + hang}
+		if i == 0 {
+			ln.text, ln.indent = label+text, indent
+		}
+		b = append(b, ln)
+	}
+	return b
+}
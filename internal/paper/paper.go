@@ -0,0 +1,138 @@
+// Package paper lays out saved tests as printable PDF exam papers and answer
+// keys. Pages are described in pdfcpu's JSON page description and rendered
+// with pdfcpu, so only its core fonts are used.
+package paper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+const (
+	DefaultFontSize = 11
+	MinFontSize     = 8
+	MaxFontSize     = 16
+)
+
+// Options control how a paper is laid out.
+type Options struct {
+	// FontSize of the question text in points. Titles are set larger.
+	FontSize int
+	// Columns the questions are set in, one or two.
+	Columns int
+	// Logo is the path of a PNG or JPEG image printed above the title.
+	Logo string
+}
+
+func (o Options) withDefaults() Options {
+	if o.FontSize == 0 {
+		o.FontSize = DefaultFontSize
+	}
+	if o.FontSize < MinFontSize {
+		o.FontSize = MinFontSize
+	}
+	if o.FontSize > MaxFontSize {
+		o.FontSize = MaxFontSize
+	}
+	if o.Columns != 2 {
+		o.Columns = 1
+	}
+	return o
+}
+
+// Exam writes the test paper handed to students: a header with name and date
+// fields, then the numbered questions with room for the answers.
+func Exam(w io.Writer, test models.Test, opts Options) error {
+	l := newLayout(opts.withDefaults())
+	l.header(test.Title, strings.Join(test.Subjects, ", "), true)
+	for i, q := range test.Questions {
+		l.place(l.question(i+1, q))
+	}
+	return l.render(w)
+}
+
+// AnswerKey writes the answers to the questions of Exam, numbered the same
+// way, with the feedback of each question.
+func AnswerKey(w io.Writer, test models.Test, opts Options) error {
+	l := newLayout(opts.withDefaults())
+	l.header(test.Title+" - Answer key", strings.Join(test.Subjects, ", "), false)
+	for i, q := range test.Questions {
+		l.place(l.answer(i+1, q))
+	}
+	return l.render(w)
+}
+
+// The JSON page description understood by pdfcpu's create command, limited
+// to what papers use.
+type description struct {
+	Paper  string          `json:"paper"`
+	Origin string          `json:"origin"`
+	Pages  map[string]page `json:"pages"`
+}
+
+type page struct {
+	Content content `json:"content"`
+}
+
+type content struct {
+	Text  []textBox  `json:"text,omitempty"`
+	Box   []box      `json:"box,omitempty"`
+	Image []imageBox `json:"image,omitempty"`
+}
+
+type font struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+type textBox struct {
+	Value string     `json:"value"`
+	Pos   [2]float64 `json:"pos"`
+	Font  font       `json:"font"`
+	Align string     `json:"align,omitempty"`
+	Width float64    `json:"width,omitempty"`
+}
+
+type box struct {
+	Pos     [2]float64 `json:"pos"`
+	Width   float64    `json:"width"`
+	Height  float64    `json:"height"`
+	FillCol string     `json:"fillCol"`
+}
+
+type imageBox struct {
+	Src    string     `json:"src"`
+	Pos    [2]float64 `json:"pos"`
+	Height float64    `json:"height"`
+}
+
+func (l *layout) render(w io.Writer) error {
+	desc := description{Paper: "A4P", Origin: "LowerLeft", Pages: map[string]page{}}
+	for i, c := range l.pages {
+		c.Text = append(c.Text, textBox{
+			Value: escape(fmt.Sprintf("Page %d of %d", i+1, len(l.pages))),
+			Pos:   [2]float64{-1, margin / 2},
+			Font:  font{Name: regular, Size: 8},
+			Align: "center",
+		})
+		desc.Pages[fmt.Sprint(i+1)] = page{Content: *c}
+	}
+
+	data, err := json.Marshal(desc)
+	if err != nil {
+		return err
+	}
+	return api.Create(nil, bytes.NewReader(data), w, nil)
+}
+
+// escape keeps pdfcpu from reading a percent sign in text as the start of
+// a page number or timestamp placeholder.
+func escape(text string) string {
+	return strings.ReplaceAll(text, "%", "%%")
+}
@@ -0,0 +1,150 @@
+package paper
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/models/modelstest"
+	"github.com/dslipak/pdf"
+	pdffont "github.com/pdfcpu/pdfcpu/pkg/font"
+)
+
+func fixture(copies int) models.Test {
+	test := models.Test{Title: "Biology", Subjects: []string{"cells", "organelles"}}
+	for i := 0; i < copies; i++ {
+		questions := modelstest.Questions()
+		// Feedback with a percent sign, which has to be drawn verbatim.
+		questions[0].Feedback = "Nearly 100% of it is fixed during photosynthesis."
+		test.Questions = append(test.Questions, questions...)
+	}
+	return test
+}
+
+// text returns the text of each page of a rendered paper.
+func text(t *testing.T, data []byte) []string {
+	t.Helper()
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pages []string
+	for i := 1; i <= reader.NumPage(); i++ {
+		var b strings.Builder
+		for _, glyph := range reader.Page(i).Content().Text {
+			b.WriteString(glyph.S)
+		}
+		pages = append(pages, b.String())
+	}
+	return pages
+}
+
+func TestExam(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Exam(&buf, fixture(1), Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := text(t, buf.Bytes())
+	if len(pages) != 1 {
+		t.Fatalf("expected 1 page, got %d", len(pages))
+	}
+	for _, want := range []string{
+		"Biology", "cells, organelles", "Name:", "Date:",
+		"1. Which gas", "A. Oxygen", "B. Carbon dioxide",
+		"2. The nucleus", "A. True", "B. False",
+		"3. The ______________ is the powerhouse",
+		"____ 1. Ribosome", "A. Digests waste", "B. Makes proteins", "C. Stores DNA",
+		"____ Anaphase", "6. What does ATP stand for?", "Page 1 of 1",
+	} {
+		if !strings.Contains(pages[0], want) {
+			t.Errorf("exam is missing %q:\n%s", want, pages[0])
+		}
+	}
+	if strings.Contains(pages[0], "Adenosine") {
+		t.Error("exam gives away an answer")
+	}
+}
+
+func TestAnswerKey(t *testing.T) {
+	var buf bytes.Buffer
+	if err := AnswerKey(&buf, fixture(1), Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	page := text(t, buf.Bytes())[0]
+	for _, want := range []string{
+		"Biology - Answer key", "1. B. Carbon dioxide", "Nearly 100% of it", "2. A. True",
+		"3. mitochondrion", "4. 1-B, 2-A, 3-C", "5. Prophase > Metaphase > Anaphase > Telophase", "6. Adenosine triphosphate",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("answer key is missing %q:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "Name:") {
+		t.Error("answer key has name and date fields")
+	}
+}
+
+func TestLayoutOptions(t *testing.T) {
+	pageCount := func(opts Options) int {
+		var buf bytes.Buffer
+		if err := Exam(&buf, fixture(6), opts); err != nil {
+			t.Fatal(err)
+		}
+		return len(text(t, buf.Bytes()))
+	}
+
+	one := pageCount(Options{Columns: 1})
+	if two := pageCount(Options{Columns: 2}); two >= one {
+		t.Errorf("expected two columns to need fewer pages than %d, got %d", one, two)
+	}
+	if large := pageCount(Options{FontSize: MaxFontSize}); large <= one {
+		t.Errorf("expected a larger font to need more pages than %d, got %d", one, large)
+	}
+}
+
+func TestLogo(t *testing.T) {
+	logo := filepath.Join(t.TempDir(), "logo.png")
+	file, err := os.Create(logo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, image.NewRGBA(image.Rect(0, 0, 40, 20))); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	var buf bytes.Buffer
+	if err := Exam(&buf, fixture(1), Options{Logo: logo}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("/Image")) {
+		t.Error("expected the logo to be embedded")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	lines := wrap("the quick brown fox jumps over the lazy dog", regular, 10, 60)
+	if len(lines) < 2 {
+		t.Fatalf("expected the text to wrap, got %q", lines)
+	}
+	for _, line := range lines {
+		if width := pdffont.TextWidth(line, regular, 10); width > 60 {
+			t.Errorf("line %q is %.1f wide", line, width)
+		}
+	}
+	if got := strings.Join(lines, " "); got != "the quick brown fox jumps over the lazy dog" {
+		t.Errorf("wrapping changed the text: %q", got)
+	}
+
+	if lines := wrap("Pneumonoultramicroscopicsilicovolcanoconiosis", regular, 10, 40); len(lines) < 2 {
+		t.Errorf("expected a long word to be broken, got %q", lines)
+	}
+}
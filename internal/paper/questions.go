@@ -0,0 +1,128 @@
+package paper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+)
+
+const (
+	optionIndent = 18.0
+	answerLines  = 3
+	blank        = "______________"
+)
+
+func letter(i int) string {
+	return string(rune('A' + i))
+}
+
+// choices are the options printed under a multiple choice or true/false
+// question, and the index of the correct one.
+func choices(q models.Question) ([]string, int) {
+	if q.Type == models.TrueFalse {
+		if q.IsTrue != nil && *q.IsTrue {
+			return []string{"True", "False"}, 0
+		}
+		return []string{"True", "False"}, 1
+	}
+	if q.CorrectIndex == nil {
+		return q.Options, -1
+	}
+	return q.Options, *q.CorrectIndex
+}
+
+// matches are the right hand sides of a matching question in the order they
+// are printed. They are sorted so they no longer line up with their prompts.
+func matches(q models.Question) []string {
+	rights := make([]string, 0, len(q.Pairs))
+	for _, pair := range q.Pairs {
+		rights = append(rights, pair.Right)
+	}
+	sort.Strings(rights)
+	return rights
+}
+
+// steps are the items of an ordering question in the order they are printed.
+func steps(q models.Question) []string {
+	sorted := append([]string(nil), q.Sequence...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func (l *layout) rules(n int) block {
+	var b block
+	for i := 0; i < n; i++ {
+		b = append(b, line{font: regular, size: l.opts.FontSize, rule: true})
+	}
+	return b
+}
+
+// question lays out a question as printed on the exam paper.
+func (l *layout) question(number int, q models.Question) block {
+	label := fmt.Sprintf("%d.", number)
+
+	switch q.Type {
+	case models.MultipleChoice, models.TrueFalse:
+		b := l.labelled(label, q.Question, regular, 0)
+		options, _ := choices(q)
+		for i, option := range options {
+			b = append(b, l.labelled(letter(i)+".", option, regular, optionIndent)...)
+		}
+		return b
+
+	case models.FillInTheBlank:
+		return l.labelled(label, models.BlankPattern.ReplaceAllString(q.Question, blank), regular, 0)
+
+	case models.Matching:
+		b := l.labelled(label, q.Question, regular, 0)
+		for i, pair := range q.Pairs {
+			b = append(b, l.labelled(fmt.Sprintf("____ %d.", i+1), pair.Left, regular, optionIndent)...)
+		}
+		for i, right := range matches(q) {
+			b = append(b, l.labelled(letter(i)+".", right, regular, optionIndent)...)
+		}
+		return b
+
+	case models.Ordering:
+		b := l.labelled(label, q.Question, regular, 0)
+		for _, step := range steps(q) {
+			b = append(b, l.labelled("____", step, regular, optionIndent)...)
+		}
+		return b
+	}
+
+	return append(l.labelled(label, q.Question, regular, 0), l.rules(answerLines)...)
+}
+
+// answer lays out the answer key entry of a question.
+func (l *layout) answer(number int, q models.Question) block {
+	label := fmt.Sprintf("%d.", number)
+	answer := q.Answer
+
+	switch q.Type {
+	case models.MultipleChoice, models.TrueFalse:
+		options, correct := choices(q)
+		if correct >= 0 && correct < len(options) {
+			answer = letter(correct) + ". " + options[correct]
+		}
+
+	case models.Matching:
+		letters := map[string]string{}
+		for i, right := range matches(q) {
+			letters[right] = letter(i)
+		}
+		pairs := make([]string, 0, len(q.Pairs))
+		for i, pair := range q.Pairs {
+			pairs = append(pairs, fmt.Sprintf("%d-%s", i+1, letters[pair.Right]))
+		}
+		answer = strings.Join(pairs, ", ")
+	}
+
+	b := l.labelled(label, answer, bold, 0)
+	if q.Feedback != "" {
+		b = append(b, l.labelled("", q.Feedback, italic, optionIndent)...)
+	}
+	return b
+}
@@ -0,0 +1,207 @@
+package qti
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// node is an XML element, or a run of text when it has no name. Items are
+// built and read as node trees so both versions can share one layout.
+type node struct {
+	name     string
+	attrs    [][2]string
+	children []*node
+	text     string
+}
+
+// el builds an element from alternating attribute names and values.
+func el(name string, attrs ...string) *node {
+	n := &node{name: name}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		n.attrs = append(n.attrs, [2]string{attrs[i], attrs[i+1]})
+	}
+	return n
+}
+
+func text(s string) *node {
+	return &node{text: s}
+}
+
+func (n *node) add(children ...*node) *node {
+	n.children = append(n.children, children...)
+	return n
+}
+
+// textEl builds an element holding only text.
+func textEl(name string, s string, attrs ...string) *node {
+	return el(name, attrs...).add(text(s))
+}
+
+func (n *node) attr(name string) string {
+	for _, a := range n.attrs {
+		if a[0] == name {
+			return a[1]
+		}
+	}
+	return ""
+}
+
+// child returns the first child element called name.
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// all returns the child elements called name.
+func (n *node) all(name string) []*node {
+	var found []*node
+	for _, c := range n.children {
+		if c.name == name {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// find returns the first element below n, depth first, for which match is
+// true.
+func (n *node) find(match func(*node) bool) *node {
+	for _, c := range n.children {
+		if c.name == "" {
+			continue
+		}
+		if match(c) {
+			return c
+		}
+		if found := c.find(match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// content is the text inside n with whitespace collapsed.
+func (n *node) content() string {
+	var b strings.Builder
+	n.collect(&b, func(*node) (string, bool) { return "", false })
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// collect writes the text below n. replace can substitute the text of an
+// element, and reports whether it did.
+func (n *node) collect(b *strings.Builder, replace func(*node) (string, bool)) {
+	for _, c := range n.children {
+		if c.name == "" {
+			b.WriteString(c.text)
+			continue
+		}
+		if s, ok := replace(c); ok {
+			b.WriteString(s)
+			continue
+		}
+		if htmlElements[c.name] {
+			b.WriteByte(' ')
+		}
+		c.collect(b, replace)
+	}
+}
+
+var (
+	escapeText = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	escapeAttr = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "\n", "&#10;", "\t", "&#9;")
+)
+
+// write serializes n in the dialect's naming. Elements holding only other
+// elements are indented; elements with text are written on one line so no
+// whitespace is added to their content.
+func (n *node) write(b *strings.Builder, d dialect, depth int) {
+	if n.name == "" {
+		escapeText.WriteString(b, n.text)
+		return
+	}
+
+	name := d.element(n.name)
+	b.WriteString("<" + name)
+	for _, a := range n.attrs {
+		b.WriteString(" " + d.attr(a[0]) + `="`)
+		escapeAttr.WriteString(b, a[1])
+		b.WriteString(`"`)
+	}
+	if len(n.children) == 0 {
+		b.WriteString("/>")
+		return
+	}
+	b.WriteString(">")
+
+	mixed := false
+	for _, c := range n.children {
+		if c.name == "" {
+			mixed = true
+		}
+	}
+
+	for _, c := range n.children {
+		if !mixed {
+			b.WriteString("\n" + strings.Repeat("  ", depth+1))
+		}
+		c.write(b, d, depth+1)
+	}
+	if !mixed {
+		b.WriteString("\n" + strings.Repeat("  ", depth))
+	}
+	b.WriteString("</" + name + ">")
+}
+
+func document(root *node, d dialect) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	root.write(&b, d, 0)
+	b.WriteString("\n")
+	return b.String()
+}
+
+// parse reads an XML document into a tree with every name in its canonical
+// 2.1 form. Namespaces are dropped.
+func parse(r io.Reader) (*node, error) {
+	decoder := xml.NewDecoder(r)
+	root := &node{}
+	stack := []*node{root}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		parent := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &node{name: canonical(t.Name.Local)}
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+					continue
+				}
+				n.attrs = append(n.attrs, [2]string{canonical(a.Name.Local), a.Value})
+			}
+			parent.add(n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			parent.add(text(string(t)))
+		}
+	}
+
+	if root = root.find(func(*node) bool { return true }); root == nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return root, nil
+}
@@ -0,0 +1,112 @@
+// Package qti reads and writes IMS QTI content packages: a zip file with an
+// imsmanifest.xml and one assessment item file per question. Both QTI 2.1
+// and QTI 3.0 are supported. The two versions share their structure and only
+// differ in naming, so items are built and read as a version neutral tree
+// using the 2.1 names.
+package qti
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// Version is a QTI specification version.
+type Version string
+
+const (
+	V21 Version = "2.1"
+	V30 Version = "3.0"
+)
+
+const manifestName = "imsmanifest.xml"
+
+// ErrNoManifest is returned by Read when the package has no imsmanifest.xml.
+var ErrNoManifest = errors.New("package has no " + manifestName)
+
+// dialect holds what differs between the QTI versions.
+type dialect struct {
+	itemNamespace     string
+	manifestNamespace string
+	resourceType      string
+	schema            string
+	schemaVersion     string
+	template          string
+	prefixed          bool
+}
+
+var dialects = map[Version]dialect{
+	V21: {
+		itemNamespace:     "http://www.imsglobal.org/xsd/imsqti_v2p1",
+		manifestNamespace: "http://www.imsglobal.org/xsd/imscp_v1p1",
+		resourceType:      "imsqti_item_xmlv2p1",
+		schema:            "QTIv2.1 Package",
+		schemaVersion:     "2.1",
+	},
+	V30: {
+		itemNamespace:     "http://www.imsglobal.org/xsd/imsqtiasi_v3p0",
+		manifestNamespace: "http://www.imsglobal.org/xsd/qti/qtiv3p0/imscp_v1p1",
+		resourceType:      "imsqti_item_xmlv3p0",
+		schema:            "QTI Package",
+		schemaVersion:     "3.0.0",
+		prefixed:          true,
+	},
+}
+
+// htmlElements are the XHTML elements used in item bodies. They keep their
+// names in every version.
+var htmlElements = map[string]bool{"p": true, "div": true, "span": true, "br": true}
+
+// element turns a 2.1 element name into the name used by the dialect. QTI 3.0
+// writes choiceInteraction as qti-choice-interaction.
+func (d dialect) element(name string) string {
+	if !d.prefixed || htmlElements[name] {
+		return name
+	}
+	return "qti-" + kebab(name)
+}
+
+// attr turns a 2.1 attribute name into the name used by the dialect. QTI 3.0
+// writes responseIdentifier as response-identifier.
+func (d dialect) attr(name string) string {
+	if !d.prefixed {
+		return name
+	}
+	return kebab(name)
+}
+
+func kebab(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsUpper(r) {
+			b.WriteByte('-')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// canonical turns an element or attribute name of either version back into
+// its 2.1 form.
+func canonical(name string) string {
+	name = strings.TrimPrefix(name, "qti-")
+	if !strings.Contains(name, "-") {
+		return name
+	}
+
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		if r == '-' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
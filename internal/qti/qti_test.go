@@ -0,0 +1,134 @@
+package qti
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/models/modelstest"
+	"github.com/google/uuid"
+)
+
+// questions adds markup that needs escaping, a false statement and a second
+// blank to the shared fixture.
+func questions() []models.Question {
+	extra := []models.Question{
+		{
+			Type:         models.MultipleChoice,
+			Question:     "Which gas makes up most of the air? <pick one>",
+			Options:      []string{"Oxygen", "Nitrogen & argon", "Carbon dioxide"},
+			CorrectIndex: modelstest.Index(1),
+		},
+		{Type: models.TrueFalse, Question: "Ribosomes are membrane bound.", IsTrue: modelstest.Boolean(false)},
+		{
+			Type:     models.FillInTheBlank,
+			Question: "___ converts light into ___ energy.",
+			Blanks:   []string{"Chlorophyll", "chemical"},
+		},
+	}
+	for i := range extra {
+		extra[i].Normalize()
+	}
+	return append(modelstest.Questions(), extra...)
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, version := range []Version{V21, V30} {
+		t.Run(string(version), func(t *testing.T) {
+			test := models.Test{Id: uuid.New(), Title: "Cells", Questions: questions()}
+			for i := range test.Questions {
+				test.Questions[i].Id = uuid.New()
+			}
+
+			var buf bytes.Buffer
+			if err := Write(&buf, test, version); err != nil {
+				t.Fatal(err)
+			}
+
+			pkg, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pkg.Skipped) > 0 {
+				t.Errorf("unexpected skipped items: %v", pkg.Skipped)
+			}
+			if want := questions(); !reflect.DeepEqual(pkg.Questions, want) {
+				t.Errorf("round trip changed the questions:\n got: %+v\nwant: %+v", pkg.Questions, want)
+			}
+		})
+	}
+}
+
+func writeZip(t *testing.T, files map[string]string) *bytes.Reader {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		file, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestReadSkipsUnsupportedItems(t *testing.T) {
+	r := writeZip(t, map[string]string{
+		"imsmanifest.xml": `<manifest xmlns="http://www.imsglobal.org/xsd/qti/qtiv3p0/imscp_v1p1">
+  <resources>
+    <resource identifier="r1" type="imsqti_item_xmlv3p0" href="one.xml"/>
+    <resource identifier="r2" type="imsqti_item_xmlv3p0" href="two.xml"/>
+    <resource identifier="r3" type="imsqti_item_xmlv3p0" href="missing.xml"/>
+    <resource identifier="r4" type="webcontent" href="style.css"/>
+  </resources>
+</manifest>`,
+		"one.xml": `<qti-assessment-item xmlns="http://www.imsglobal.org/xsd/imsqtiasi_v3p0" identifier="one">
+  <qti-response-declaration identifier="RESPONSE" cardinality="single" base-type="identifier">
+    <qti-correct-response><qti-value>b</qti-value></qti-correct-response>
+  </qti-response-declaration>
+  <qti-item-body>
+    <qti-choice-interaction response-identifier="RESPONSE" max-choices="1">
+      <qti-prompt>Which planet is <em>largest</em>?</qti-prompt>
+      <qti-simple-choice identifier="a">Mars</qti-simple-choice>
+      <qti-simple-choice identifier="b">Jupiter</qti-simple-choice>
+      <qti-simple-choice identifier="c">Venus</qti-simple-choice>
+    </qti-choice-interaction>
+  </qti-item-body>
+</qti-assessment-item>`,
+		"two.xml": `<qti-assessment-item xmlns="http://www.imsglobal.org/xsd/imsqtiasi_v3p0" identifier="two">
+  <qti-item-body>
+    <qti-hotspot-interaction response-identifier="RESPONSE"/>
+  </qti-item-body>
+</qti-assessment-item>`,
+	})
+
+	pkg, err := Read(r, r.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pkg.Questions) != 1 {
+		t.Fatalf("expected 1 question, got %d", len(pkg.Questions))
+	}
+	q := pkg.Questions[0]
+	if q.Type != models.MultipleChoice || q.Question != "Which planet is largest?" || q.Answer != "Jupiter" {
+		t.Errorf("unexpected question: %+v", q)
+	}
+
+	if len(pkg.Skipped) != 2 || !strings.HasPrefix(pkg.Skipped[0], "two.xml") || !strings.HasPrefix(pkg.Skipped[1], "missing.xml") {
+		t.Errorf("unexpected skipped items: %v", pkg.Skipped)
+	}
+}
+
+func TestReadWithoutManifest(t *testing.T) {
+	r := writeZip(t, map[string]string{"item.xml": "<assessmentItem/>"})
+	if _, err := Read(r, r.Size()); err != ErrNoManifest {
+		t.Errorf("expected ErrNoManifest, got %v", err)
+	}
+}
@@ -0,0 +1,261 @@
+package qti
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+)
+
+// maxFileSize caps how much of each file in a package is read.
+const maxFileSize = 1 << 20
+
+// Package is what Read found in a QTI content package. Skipped lists the
+// items that could not be turned into questions, with the reason.
+type Package struct {
+	Questions []models.Question
+	Skipped   []string
+}
+
+// Read reads the assessment items of a QTI 2.1 or 3.0 content package in
+// manifest order. Items using interactions this service has no question type
+// for are skipped rather than failing the whole package.
+func Read(r io.ReaderAt, size int64) (Package, error) {
+	var pkg Package
+
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return pkg, err
+	}
+
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		files[path.Clean(file.Name)] = file
+	}
+
+	manifestFile, ok := files[manifestName]
+	if !ok {
+		return pkg, ErrNoManifest
+	}
+	manifest, err := parseFile(manifestFile)
+	if err != nil {
+		return pkg, fmt.Errorf("%s: %w", manifestName, err)
+	}
+
+	resources := manifest.child("resources")
+	if resources == nil {
+		return pkg, nil
+	}
+
+	for _, resource := range resources.all("resource") {
+		if !strings.HasPrefix(resource.attr("type"), "imsqti_item") {
+			continue
+		}
+
+		href := path.Clean(resource.attr("href"))
+		file, ok := files[href]
+		if !ok {
+			pkg.Skipped = append(pkg.Skipped, href+": file is missing from the package")
+			continue
+		}
+
+		root, err := parseFile(file)
+		if err != nil {
+			pkg.Skipped = append(pkg.Skipped, fmt.Sprintf("%s: %s", href, err))
+			continue
+		}
+
+		question, err := readItem(root)
+		if err == nil {
+			question.Normalize()
+			err = question.Validate()
+		}
+		if err != nil {
+			pkg.Skipped = append(pkg.Skipped, fmt.Sprintf("%s: %s", href, err))
+			continue
+		}
+		pkg.Questions = append(pkg.Questions, question)
+	}
+
+	return pkg, nil
+}
+
+func parseFile(file *zip.File) (*node, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return parse(io.LimitReader(reader, maxFileSize))
+}
+
+func isInteraction(n *node) bool {
+	return strings.HasSuffix(n.name, "Interaction")
+}
+
+// readItem maps the interaction of an assessment item onto a question type.
+func readItem(root *node) (models.Question, error) {
+	var q models.Question
+	if root.name != "assessmentItem" {
+		return q, fmt.Errorf("expected an assessment item, found %s", root.name)
+	}
+
+	body := root.child("itemBody")
+	if body == nil {
+		return q, errors.New("item has no body")
+	}
+	interaction := body.find(isInteraction)
+	if interaction == nil {
+		return q, errors.New("item has no interaction")
+	}
+
+	correct := correctValues(root)
+	values := correct[interaction.attr("responseIdentifier")]
+	q.Question = questionText(body)
+	if feedback := root.child("modalFeedback"); feedback != nil {
+		q.Feedback = feedback.content()
+	}
+
+	switch interaction.name {
+	case "choiceInteraction":
+		if len(values) != 1 {
+			return q, errors.New("only single response choice interactions are supported")
+		}
+
+		choices := interaction.all("simpleChoice")
+		for i, choice := range choices {
+			q.Options = append(q.Options, choice.content())
+			if choice.attr("identifier") == values[0] {
+				q.CorrectIndex = &i
+			}
+		}
+		if q.CorrectIndex == nil {
+			return q, errors.New("correct response is not one of the choices")
+		}
+
+		q.Type = models.MultipleChoice
+		if isTrue, ok := trueFalse(q.Options, *q.CorrectIndex); ok {
+			q.Type, q.IsTrue, q.Options, q.CorrectIndex = models.TrueFalse, &isTrue, nil, nil
+		}
+
+	case "textEntryInteraction":
+		q.Type = models.FillInTheBlank
+		var missing error
+		body.find(func(n *node) bool {
+			if n.name == "textEntryInteraction" {
+				answers := correct[n.attr("responseIdentifier")]
+				if len(answers) == 0 {
+					missing = errors.New("blank has no correct response")
+					return true
+				}
+				q.Blanks = append(q.Blanks, answers[0])
+			}
+			return false
+		})
+		if missing != nil {
+			return q, missing
+		}
+
+	case "matchInteraction":
+		sets := interaction.all("simpleMatchSet")
+		if len(sets) != 2 {
+			return q, errors.New("match interaction needs two sets")
+		}
+		left, right := choiceTexts(sets[0]), choiceTexts(sets[1])
+
+		q.Type = models.Matching
+		for _, value := range values {
+			ids := strings.Fields(value)
+			if len(ids) != 2 || left[ids[0]] == "" || right[ids[1]] == "" {
+				return q, fmt.Errorf("unknown pair %q", value)
+			}
+			q.Pairs = append(q.Pairs, models.MatchPair{Left: left[ids[0]], Right: right[ids[1]]})
+		}
+
+	case "orderInteraction":
+		steps := choiceTexts(interaction)
+
+		q.Type = models.Ordering
+		for _, value := range values {
+			if steps[value] == "" {
+				return q, fmt.Errorf("unknown choice %q", value)
+			}
+			q.Sequence = append(q.Sequence, steps[value])
+		}
+
+	case "extendedTextInteraction":
+		q.Type = models.ShortAnswer
+		if len(values) > 0 {
+			q.Answer = values[0]
+		}
+
+	default:
+		return q, fmt.Errorf("%s is not supported", interaction.name)
+	}
+
+	return q, nil
+}
+
+// correctValues maps each response identifier to its correct values.
+func correctValues(root *node) map[string][]string {
+	correct := map[string][]string{}
+	for _, declaration := range root.all("responseDeclaration") {
+		response := declaration.child("correctResponse")
+		if response == nil {
+			continue
+		}
+		for _, value := range response.all("value") {
+			identifier := declaration.attr("identifier")
+			correct[identifier] = append(correct[identifier], value.content())
+		}
+	}
+	return correct
+}
+
+// questionText is the text of the item body. Blanks become the blank marker,
+// and other interactions only contribute their prompt.
+func questionText(body *node) string {
+	var b strings.Builder
+	body.collect(&b, func(n *node) (string, bool) {
+		if n.name == "textEntryInteraction" {
+			return models.BlankMarker, true
+		}
+		if isInteraction(n) {
+			if prompt := n.child("prompt"); prompt != nil {
+				return " " + prompt.content() + " ", true
+			}
+			return "", true
+		}
+		return "", false
+	})
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// choiceTexts maps the identifiers of the choices under n to their text.
+func choiceTexts(n *node) map[string]string {
+	texts := map[string]string{}
+	for _, c := range n.children {
+		if c.name == "simpleChoice" || c.name == "simpleAssociableChoice" {
+			texts[c.attr("identifier")] = c.content()
+		}
+	}
+	return texts
+}
+
+// trueFalse reports whether options are True and False, and if so whether
+// the correct one is True.
+func trueFalse(options []string, correct int) (bool, bool) {
+	if len(options) != 2 {
+		return false, false
+	}
+	first, second := strings.ToLower(options[0]), strings.ToLower(options[1])
+	if !(first == "true" && second == "false") && !(first == "false" && second == "true") {
+		return false, false
+	}
+	return strings.ToLower(options[correct]) == "true", true
+}
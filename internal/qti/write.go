@@ -0,0 +1,215 @@
+package qti
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// Write writes test as a QTI content package of the given version.
+func Write(w io.Writer, test models.Test, version Version) error {
+	d, ok := dialects[version]
+	if !ok {
+		return fmt.Errorf("unsupported QTI version %q", version)
+	}
+
+	archive := zip.NewWriter(w)
+	resources := el("resources")
+
+	for i, q := range test.Questions {
+		identifier := itemIdentifier(q, i)
+		href := "items/" + identifier + ".xml"
+
+		file, err := archive.Create(href)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(file, document(item(q, identifier, d), d)); err != nil {
+			return err
+		}
+
+		resources.add(el("resource", "identifier", "res-"+identifier, "type", d.resourceType, "href", href).
+			add(el("file", "href", href)))
+	}
+
+	manifest := el("manifest", "xmlns", d.manifestNamespace, "identifier", "test-"+test.Id.String()).add(
+		el("metadata").add(
+			textEl("schema", d.schema),
+			textEl("schemaversion", d.schemaVersion),
+		),
+		el("organizations"),
+		resources,
+	)
+
+	// The manifest uses the content packaging names in every version.
+	file, err := archive.Create(manifestName)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(file, document(manifest, dialect{})); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+func itemIdentifier(q models.Question, i int) string {
+	if q.Id == uuid.Nil {
+		return "item-" + strconv.Itoa(i+1)
+	}
+	return "item-" + q.Id.String()
+}
+
+// item builds the assessment item of a question. Every item is scored by
+// matching all of its responses against their correct values, except short
+// answers, which need a person to mark them.
+func item(q models.Question, identifier string, d dialect) *node {
+	root := el("assessmentItem", "xmlns", d.itemNamespace, "identifier", identifier,
+		"title", strings.Join(strings.Fields(q.Question), " "),
+		"adaptive", "false", "timeDependent", "false")
+	body := el("itemBody")
+	var responses []string
+
+	switch q.Type {
+	case models.MultipleChoice, models.TrueFalse:
+		options, correct := q.Options, 0
+		if q.CorrectIndex != nil {
+			correct = *q.CorrectIndex
+		}
+		if q.Type == models.TrueFalse {
+			options, correct = []string{"True", "False"}, 1
+			if q.IsTrue != nil && *q.IsTrue {
+				correct = 0
+			}
+		}
+
+		interaction := el("choiceInteraction", "responseIdentifier", "RESPONSE", "shuffle", "false", "maxChoices", "1")
+		for i, option := range options {
+			interaction.add(textEl("simpleChoice", option, "identifier", choiceIdentifier(q.Type, i)))
+		}
+		root.add(declaration("RESPONSE", "single", "identifier", choiceIdentifier(q.Type, correct)))
+		body.add(textEl("p", q.Question), interaction)
+		responses = append(responses, "RESPONSE")
+
+	case models.FillInTheBlank:
+		paragraph := el("p")
+		for i, part := range models.BlankPattern.Split(q.Question, -1) {
+			if i > 0 && i <= len(q.Blanks) {
+				response := "RESPONSE_" + strconv.Itoa(i)
+				root.add(declaration(response, "single", "string", q.Blanks[i-1]))
+				paragraph.add(el("textEntryInteraction", "responseIdentifier", response,
+					"expectedLength", strconv.Itoa(len(q.Blanks[i-1]))))
+				responses = append(responses, response)
+			}
+			if part != "" {
+				paragraph.add(text(part))
+			}
+		}
+		body.add(paragraph)
+
+	case models.Matching:
+		prompts, matches := el("simpleMatchSet"), el("simpleMatchSet")
+		pairs := make([]string, 0, len(q.Pairs))
+		for i, pair := range q.Pairs {
+			left, right := "L"+strconv.Itoa(i+1), "R"+strconv.Itoa(i+1)
+			prompts.add(textEl("simpleAssociableChoice", pair.Left, "identifier", left, "matchMax", "1"))
+			matches.add(textEl("simpleAssociableChoice", pair.Right, "identifier", right, "matchMax", "1"))
+			pairs = append(pairs, left+" "+right)
+		}
+
+		root.add(declaration("RESPONSE", "multiple", "directedPair", pairs...))
+		body.add(textEl("p", q.Question), el("matchInteraction", "responseIdentifier", "RESPONSE",
+			"shuffle", "true", "maxAssociations", strconv.Itoa(len(q.Pairs))).add(prompts, matches))
+		responses = append(responses, "RESPONSE")
+
+	case models.Ordering:
+		interaction := el("orderInteraction", "responseIdentifier", "RESPONSE", "shuffle", "true")
+		order := make([]string, 0, len(q.Sequence))
+		for i, step := range q.Sequence {
+			id := "S" + strconv.Itoa(i+1)
+			interaction.add(textEl("simpleChoice", step, "identifier", id))
+			order = append(order, id)
+		}
+
+		root.add(declaration("RESPONSE", "ordered", "identifier", order...))
+		body.add(textEl("p", q.Question), interaction)
+		responses = append(responses, "RESPONSE")
+
+	default:
+		root.add(declaration("RESPONSE", "single", "string", q.Answer))
+		body.add(textEl("p", q.Question), el("extendedTextInteraction", "responseIdentifier", "RESPONSE"))
+	}
+
+	root.add(el("outcomeDeclaration", "identifier", "SCORE", "cardinality", "single", "baseType", "float").
+		add(el("defaultValue").add(textEl("value", "0"))))
+	if q.Feedback != "" {
+		root.add(el("outcomeDeclaration", "identifier", "FEEDBACK", "cardinality", "single", "baseType", "identifier"))
+	}
+
+	root.add(body)
+	if processing := responseProcessing(responses, q.Feedback != ""); processing != nil {
+		root.add(processing)
+	}
+	if q.Feedback != "" {
+		root.add(el("modalFeedback", "outcomeIdentifier", "FEEDBACK", "identifier", "GENERAL", "showHide", "show").
+			add(textEl("p", q.Feedback)))
+	}
+
+	return root
+}
+
+func choiceIdentifier(t models.QuestionType, i int) string {
+	if t == models.TrueFalse {
+		return []string{"true", "false"}[i]
+	}
+	return "C" + strconv.Itoa(i+1)
+}
+
+func declaration(identifier string, cardinality string, baseType string, values ...string) *node {
+	correct := el("correctResponse")
+	for _, value := range values {
+		correct.add(textEl("value", value))
+	}
+	return el("responseDeclaration", "identifier", identifier, "cardinality", cardinality, "baseType", baseType).
+		add(correct)
+}
+
+func responseProcessing(responses []string, feedback bool) *node {
+	if len(responses) == 0 && !feedback {
+		return nil
+	}
+
+	processing := el("responseProcessing")
+	if len(responses) > 0 {
+		var condition *node
+		for _, response := range responses {
+			match := el("match").add(el("variable", "identifier", response), el("correct", "identifier", response))
+			switch {
+			case condition == nil:
+				condition = match
+			case condition.name == "and":
+				condition.add(match)
+			default:
+				condition = el("and").add(condition, match)
+			}
+		}
+
+		processing.add(el("responseCondition").add(
+			el("responseIf").add(condition, setOutcome("SCORE", "float", "1")),
+			el("responseElse").add(setOutcome("SCORE", "float", "0")),
+		))
+	}
+	if feedback {
+		processing.add(setOutcome("FEEDBACK", "identifier", "GENERAL"))
+	}
+	return processing
+}
+
+func setOutcome(identifier string, baseType string, value string) *node {
+	return el("setOutcomeValue", "identifier", identifier).add(textEl("baseValue", value, "baseType", baseType))
+}
@@ -0,0 +1,45 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/bjorndonald/test-maker-service/database"
+	"github.com/bjorndonald/test-maker-service/internal/repository"
+	"github.com/bjorndonald/test-maker-service/internal/repository/repotest"
+)
+
+func TestMemoryDocumentInterface(t *testing.T) {
+	for _, metric := range []repository.Metric{repository.Cosine, repository.InnerProduct} {
+		t.Run(string(metric), func(t *testing.T) {
+			repotest.Document(t, 8, func(t *testing.T) repository.DocumentInterface {
+				repo, err := repository.NewMemoryRepo(metric, "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return repo
+			})
+		})
+	}
+}
+
+// TestPostgresDocumentInterface runs against the database at
+// TEST_DATABASE_URL, migrating it first, and is skipped when it is not set.
+func TestPostgresDocumentInterface(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	conn, err := database.Connect(context.Background(), &database.Config{URL: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	repo := repository.NewPostgresRepo(conn)
+	repotest.Document(t, 1536, func(t *testing.T) repository.DocumentInterface {
+		return repo
+	})
+}
@@ -2,26 +2,28 @@ package repository
 
 import (
 	"context"
-	"database/sql"
-	"fmt"
 	"log"
-	"strings"
 
 	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type DocumentInterface interface {
 	InsertChunks(ctx context.Context, chunks []models.Chunk) error
+	DeletePageChunks(ctx context.Context, document_id string, page int) error
 	InsertDocument(ctx context.Context, doc models.Document) (string, error)
 	RetrieveDocument(ctx context.Context, id string) (models.Document, error)
-	VectorSearch(ctx context.Context, document_id string, prompt []float32) ([]models.Chunk, error)
+	VectorSearch(ctx context.Context, document_id string, prompt []float32, limit int) ([]models.Chunk, error)
+	KeywordSearch(ctx context.Context, document_id string, query string, limit int) ([]models.Chunk, error)
+	DeleteDocument(ctx context.Context, id string) error
 }
 
 type documentRepo struct {
-	DB *sql.DB
+	DB *pgxpool.Pool
 }
 
-func NewPostgresRepo(conn *sql.DB) DocumentInterface {
+func NewPostgresRepo(conn *pgxpool.Pool) DocumentInterface {
 	return &documentRepo{
 		DB: conn,
 	}
@@ -34,7 +36,7 @@ func (m *documentRepo) RetrieveDocument(ctx context.Context, id string) (models.
 		select id, url, created_at from documents where id = $1
 	`
 
-	row := m.DB.QueryRowContext(ctx, query, id)
+	row := m.DB.QueryRow(ctx, query, id)
 
 	err := row.Scan(
 		&document.Id,
@@ -48,32 +50,116 @@ func (m *documentRepo) RetrieveDocument(ctx context.Context, id string) (models.
 	return document, nil
 }
 
-func (m *documentRepo) VectorSearch(ctx context.Context, document_id string, prompt []float32) ([]models.Chunk, error) {
-	var chunks []models.Chunk
+func (m *documentRepo) VectorSearch(ctx context.Context, document_id string, prompt []float32, limit int) ([]models.Chunk, error) {
+	chunks := []models.Chunk{}
 
 	query := `
-		SELECT id, chunk, chunk_embedding <#> $1 AS similarity
+		SELECT id, document, chunk, page_from, page_to, char_start, char_end, strategy, heading, chunk_embedding <#> $1 AS similarity
 		FROM chunks WHERE document = $2
 		ORDER BY similarity
-		LIMIT 5;
+		LIMIT $3;
 	`
-	vector := fmt.Sprintf("[%s]", strings.Trim(strings.Replace(fmt.Sprint(prompt), " ", ",", -1), "[]"))
+	rows, err := m.DB.Query(ctx, query, prompt, document_id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var chunk models.Chunk
+		var similarity float64
+		err := rows.Scan(
+			&chunk.Id,
+			&chunk.DocumentId,
+			&chunk.Chunk,
+			&chunk.PageFrom,
+			&chunk.PageTo,
+			&chunk.Start,
+			&chunk.End,
+			&chunk.Strategy,
+			&chunk.Heading,
+			&similarity,
+		)
+		if err != nil {
+			return nil, err
+		}
+		// <#> is the negated inner product
+		chunk.Score = -similarity
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, rows.Err()
+}
+
+// KeywordSearch ranks the chunks of a document sharing a word with the query
+// by BM25, with the same parameters as MemoryRepo. Term frequencies, chunk
+// lengths and document frequencies come from the chunk_search vectors of the
+// document, so unlike MemoryRepo words are stemmed and stop words are left
+// out of both the query and the chunk lengths.
+func (m *documentRepo) KeywordSearch(ctx context.Context, document_id string, query string, limit int) ([]models.Chunk, error) {
+	chunks := []models.Chunk{}
 
-	rows, err := m.DB.Query(query, vector, document_id)
+	stmt := `
+		WITH terms AS (
+			SELECT DISTINCT lexeme FROM unnest(to_tsvector('english', $2))
+		), document_chunks AS (
+			SELECT id, chunk_search FROM chunks WHERE document = $1
+		), lengths AS (
+			SELECT c.id, coalesce(sum(array_length(v.positions, 1)), 0) AS length
+			FROM document_chunks c LEFT JOIN LATERAL unnest(c.chunk_search) v ON true
+			GROUP BY c.id
+		), stats AS (
+			SELECT count(*) AS n, greatest(avg(length), 1) AS average FROM lengths
+		), frequencies AS (
+			SELECT c.id, v.lexeme, array_length(v.positions, 1) AS tf
+			FROM document_chunks c, unnest(c.chunk_search) v
+			WHERE v.lexeme IN (SELECT lexeme FROM terms)
+		), occurrences AS (
+			SELECT lexeme, count(*) AS df FROM frequencies GROUP BY lexeme
+		), scores AS (
+			SELECT f.id, sum(
+				ln(1 + (s.n - o.df + 0.5) / (o.df + 0.5))
+				* f.tf * ($4::float8 + 1) / (f.tf + $4::float8 * (1 - $5::float8 + $5::float8 * l.length / s.average))
+			)::float8 AS score
+			FROM frequencies f
+			JOIN occurrences o USING (lexeme)
+			JOIN lengths l USING (id)
+			CROSS JOIN stats s
+			GROUP BY f.id
+		)
+		SELECT c.id, c.document, c.chunk, c.page_from, c.page_to, c.char_start, c.char_end, c.strategy, c.heading, scores.score
+		FROM scores JOIN chunks c USING (id)
+		ORDER BY scores.score DESC
+		LIMIT $3
+	`
+
+	rows, err := m.DB.Query(ctx, stmt, document_id, query, limit, bm25K1, bm25B)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var chunk models.Chunk
-		err := rows.Scan(&chunk.Id, &chunk.Chunk, &chunk.ChunkEmbedding)
+		err := rows.Scan(
+			&chunk.Id,
+			&chunk.DocumentId,
+			&chunk.Chunk,
+			&chunk.PageFrom,
+			&chunk.PageTo,
+			&chunk.Start,
+			&chunk.End,
+			&chunk.Strategy,
+			&chunk.Heading,
+			&chunk.Score,
+		)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
+		chunks = append(chunks, chunk)
 	}
 
-	return chunks, nil
+	return chunks, rows.Err()
 }
 
 func (m *documentRepo) InsertDocument(ctx context.Context, doc models.Document) (string, error) {
@@ -81,7 +167,7 @@ func (m *documentRepo) InsertDocument(ctx context.Context, doc models.Document)
 	stmt := `
 		insert into documents (id, url, created_at) values ($1, $2, $3) returning id 
 		`
-	err := m.DB.QueryRowContext(ctx, stmt,
+	err := m.DB.QueryRow(ctx, stmt,
 		doc.Id,
 		doc.Url,
 		doc.CreatedAt,
@@ -94,24 +180,62 @@ func (m *documentRepo) InsertDocument(ctx context.Context, doc models.Document)
 	return newID, nil
 }
 
+// InsertChunks copies the chunks into the database in a single transaction,
+// so a failure part way leaves none of them behind.
 func (m *documentRepo) InsertChunks(ctx context.Context, chunks []models.Chunk) error {
-	for _, chunk := range chunks {
-		var newID string
-		stmt := `
-			insert into chunks (id, document, chunk, chunk_embedding) values ($1, $2, $3, $4::vector) returning id 
-		`
-		vector := fmt.Sprintf("[%s]", strings.Trim(strings.Replace(fmt.Sprint(chunk.ChunkEmbedding), " ", ",", -1), "[]"))
-		// log.Printf("Query: vector=%s\n", vector)
-		err := m.DB.QueryRowContext(ctx, stmt,
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	tx, err := m.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows := make([][]any, len(chunks))
+	for i, chunk := range chunks {
+		rows[i] = []any{
 			chunk.Id,
 			chunk.DocumentId,
 			chunk.Chunk,
-			vector,
-		).Scan(&newID)
-		if err != nil {
-			return err
+			chunk.ChunkEmbedding,
+			chunk.PageFrom,
+			chunk.PageTo,
+			chunk.Start,
+			chunk.End,
+			chunk.Strategy,
+			chunk.Heading,
 		}
 	}
 
-	return nil
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"chunks"}, chunkColumns, pgx.CopyFromRows(rows)); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeletePageChunks removes the chunks starting on a page of a document, so the
+// page can be embedded again without leaving duplicates behind.
+func (m *documentRepo) DeletePageChunks(ctx context.Context, document_id string, page int) error {
+	_, err := m.DB.Exec(ctx, `
+		delete from chunks where document = $1 and page_from = $2
+	`, document_id, page)
+	return err
+}
+
+var chunkColumns = []string{"id", "document", "chunk", "chunk_embedding", "page_from", "page_to", "char_start", "char_end", "strategy", "heading"}
+
+// DeleteDocument removes a document. Its chunks and tests go with it through
+// the foreign keys.
+func (m *documentRepo) DeleteDocument(ctx context.Context, id string) error {
+	tag, err := m.DB.Exec(ctx, `
+		delete from documents where id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	return expectRow(tag)
 }
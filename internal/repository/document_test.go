@@ -0,0 +1,87 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bjorndonald/test-maker-service/database"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// benchmarkChunks returns a page worth of chunks of a 300 page book.
+func benchmarkChunks(document uuid.UUID) []models.Chunk {
+	chunks := make([]models.Chunk, 20)
+	for i := range chunks {
+		embedding := make([]float32, 1536)
+		for j := range embedding {
+			embedding[j] = float32((i+j)%97) / 97
+		}
+		chunks[i] = models.Chunk{
+			Id:             uuid.New(),
+			DocumentId:     document,
+			Chunk:          strings.Repeat("Mitochondria produce energy for the cell. ", 20),
+			ChunkEmbedding: embedding,
+			PageFrom:       1,
+			PageTo:         1,
+			Strategy:       "fixed",
+		}
+	}
+	return chunks
+}
+
+// insertRowByRow is how chunks were inserted before they were copied, one
+// statement per chunk with the embedding as a text literal.
+func insertRowByRow(ctx context.Context, conn *pgxpool.Pool, chunks []models.Chunk) error {
+	for _, chunk := range chunks {
+		vector := fmt.Sprintf("[%s]", strings.Trim(strings.Replace(fmt.Sprint(chunk.ChunkEmbedding), " ", ",", -1), "[]"))
+		_, err := conn.Exec(ctx, `
+			insert into chunks (id, document, chunk, chunk_embedding, page_from, page_to, char_start, char_end, strategy, heading)
+			values ($1, $2, $3, $4::vector, $5, $6, $7, $8, $9, $10)
+		`,
+			chunk.Id, chunk.DocumentId, chunk.Chunk, vector, chunk.PageFrom, chunk.PageTo, chunk.Start, chunk.End, chunk.Strategy, chunk.Heading,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BenchmarkInsertChunks measures copying a page of chunks against inserting
+// them one at a time, on the database at TEST_DATABASE_URL.
+func BenchmarkInsertChunks(b *testing.B) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		b.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	conn, err := database.Connect(ctx, &database.Config{URL: dsn})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+
+	repo := repository.NewPostgresRepo(conn)
+
+	b.Run("copy", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := repo.InsertChunks(ctx, benchmarkChunks(uuid.New())); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("row by row", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := insertRowByRow(ctx, conn, benchmarkChunks(uuid.New())); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
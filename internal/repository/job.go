@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type JobInterface interface {
+	InsertJob(ctx context.Context, job models.Job) error
+	RetrieveJob(ctx context.Context, id string) (models.Job, error)
+	UpdateJob(ctx context.Context, job models.Job) error
+	ClaimJob(ctx context.Context) (models.Job, bool, error)
+	RequeueRunningJobs(ctx context.Context) (int64, error)
+}
+
+type jobRepo struct {
+	DB *pgxpool.Pool
+}
+
+func NewPostgresJobRepo(conn *pgxpool.Pool) JobInterface {
+	return &jobRepo{
+		DB: conn,
+	}
+}
+
+const jobColumns = `id, kind, status, payload, result, error, pages_done, pages_total, created_at, updated_at`
+
+func scanJob(row interface{ Scan(...any) error }) (models.Job, error) {
+	var job models.Job
+	var payload, result []byte
+
+	err := row.Scan(
+		&job.Id,
+		&job.Kind,
+		&job.Status,
+		&payload,
+		&result,
+		&job.Error,
+		&job.PagesDone,
+		&job.PagesTotal,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	job.Payload = payload
+	job.Result = result
+
+	return job, err
+}
+
+func (m *jobRepo) InsertJob(ctx context.Context, job models.Job) error {
+	stmt := `
+		insert into jobs (` + jobColumns + `) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := m.DB.Exec(ctx, stmt,
+		job.Id,
+		job.Kind,
+		job.Status,
+		[]byte(job.Payload),
+		nullableJSON(job.Result),
+		job.Error,
+		job.PagesDone,
+		job.PagesTotal,
+		job.CreatedAt,
+		job.UpdatedAt,
+	)
+
+	return err
+}
+
+func (m *jobRepo) RetrieveJob(ctx context.Context, id string) (models.Job, error) {
+	query := `
+		select ` + jobColumns + ` from jobs where id = $1
+	`
+
+	return scanJob(m.DB.QueryRow(ctx, query, id))
+}
+
+func (m *jobRepo) UpdateJob(ctx context.Context, job models.Job) error {
+	stmt := `
+		update jobs set status = $2, payload = $3, result = $4, error = $5, pages_done = $6, pages_total = $7, updated_at = $8
+		where id = $1
+	`
+	_, err := m.DB.Exec(ctx, stmt,
+		job.Id,
+		job.Status,
+		[]byte(job.Payload),
+		nullableJSON(job.Result),
+		job.Error,
+		job.PagesDone,
+		job.PagesTotal,
+		job.UpdatedAt,
+	)
+
+	return err
+}
+
+// ClaimJob marks the oldest pending job as running and returns it. The bool
+// is false when there is nothing to do.
+func (m *jobRepo) ClaimJob(ctx context.Context) (models.Job, bool, error) {
+	query := `
+		update jobs set status = $1, updated_at = now()
+		where id = (
+			select id from jobs where status = $2
+			order by created_at
+			for update skip locked
+			limit 1
+		)
+		returning ` + jobColumns
+
+	job, err := scanJob(m.DB.QueryRow(ctx, query, models.JobRunning, models.JobPending))
+	if errors.Is(err, sql.ErrNoRows) {
+		return job, false, nil
+	}
+	if err != nil {
+		return job, false, err
+	}
+
+	return job, true, nil
+}
+
+// RequeueRunningJobs puts jobs that were running when the service stopped back
+// in the queue so they are resumed.
+func (m *jobRepo) RequeueRunningJobs(ctx context.Context) (int64, error) {
+	stmt := `
+		update jobs set status = $1, updated_at = now() where status = $2
+	`
+	tag, err := m.DB.Exec(ctx, stmt, models.JobPending, models.JobRunning)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func nullableJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,670 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// Metric is how VectorSearch compares embeddings.
+type Metric string
+
+const (
+	Cosine       Metric = "cosine"
+	InnerProduct Metric = "inner_product"
+)
+
+// bm25 parameters, the usual defaults.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// MemoryRepo keeps documents, jobs and tests in memory, implementing
+// DocumentInterface, JobInterface and TestInterface without a database.
+// Vector search compares the query with every chunk of the document. When a
+// snapshot path is set, the data is loaded from it on start and written back
+// every snapshotInterval while it has changed, and on Close. Writing the whole
+// snapshot on every change would make job progress updates rewrite it again
+// and again.
+type MemoryRepo struct {
+	mu       sync.RWMutex
+	metric   Metric
+	snapshot string
+	data     memoryData
+	dirty    bool
+
+	// writing serialises snapshot writes, which happen outside mu.
+	writing sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// snapshotInterval is how often a changed MemoryRepo writes its snapshot.
+const snapshotInterval = time.Second
+
+// memoryData is everything MemoryRepo holds, as written to its snapshot.
+type memoryData struct {
+	Documents map[string]models.Document   `json:"documents"`
+	Chunks    map[string][]models.Chunk    `json:"chunks"`
+	Jobs      map[string]models.Job        `json:"jobs"`
+	Tests     map[string]models.Test       `json:"tests"`
+	Questions map[string][]models.Question `json:"questions"`
+}
+
+// NewMemoryRepo returns an empty MemoryRepo comparing embeddings by metric, or
+// by cosine similarity when it is empty. snapshot, when set, is the file the
+// data is kept in between runs; Close must then be called on shutdown.
+func NewMemoryRepo(metric Metric, snapshot string) (*MemoryRepo, error) {
+	switch metric {
+	case "":
+		metric = Cosine
+	case Cosine, InnerProduct:
+	default:
+		return nil, fmt.Errorf("unknown vector metric %q", metric)
+	}
+
+	m := &MemoryRepo{
+		metric:   metric,
+		snapshot: snapshot,
+		data: memoryData{
+			Documents: map[string]models.Document{},
+			Chunks:    map[string][]models.Chunk{},
+			Jobs:      map[string]models.Job{},
+			Tests:     map[string]models.Test{},
+			Questions: map[string][]models.Question{},
+		},
+	}
+	if snapshot == "" {
+		return m, nil
+	}
+
+	content, err := os.ReadFile(snapshot)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, &m.data); err != nil {
+			return nil, fmt.Errorf("could not read snapshot %s: %w", snapshot, err)
+		}
+	}
+
+	m.start()
+	return m, nil
+}
+
+// start runs the loop writing the snapshot until Close.
+func (m *MemoryRepo) start() {
+	m.stop, m.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(snapshotInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				if err := m.Flush(); err != nil {
+					log.Printf("could not write snapshot %s: %s", m.snapshot, err)
+				}
+			}
+		}
+	}()
+}
+
+// Flush writes the snapshot now if anything changed since the last write.
+func (m *MemoryRepo) Flush() error {
+	if m.snapshot == "" {
+		return nil
+	}
+
+	m.writing.Lock()
+	defer m.writing.Unlock()
+
+	m.mu.Lock()
+	if !m.dirty {
+		m.mu.Unlock()
+		return nil
+	}
+	content, err := json.Marshal(m.data)
+	m.dirty = err != nil
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := m.save(content); err != nil {
+		m.mu.Lock()
+		m.dirty = true
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Close stops the snapshot loop and writes any changes left.
+func (m *MemoryRepo) Close() error {
+	if m.stop != nil {
+		close(m.stop)
+		<-m.done
+		m.stop = nil
+	}
+	return m.Flush()
+}
+
+// save writes content to the snapshot, replacing the file in one step so a
+// crash never leaves it half written.
+func (m *MemoryRepo) save(content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(m.snapshot), filepath.Base(m.snapshot)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.snapshot)
+}
+
+func (m *MemoryRepo) InsertDocument(ctx context.Context, doc models.Document) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := doc.Id.String()
+	if _, ok := m.data.Documents[id]; ok {
+		return "", fmt.Errorf("document %s already exists", id)
+	}
+	m.data.Documents[id] = doc
+
+	m.dirty = true
+	return id, nil
+}
+
+func (m *MemoryRepo) RetrieveDocument(ctx context.Context, id string) (models.Document, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Document{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	doc, ok := m.data.Documents[id]
+	if !ok {
+		return doc, sql.ErrNoRows
+	}
+	return doc, nil
+}
+
+// DeleteDocument removes a document along with its chunks, tests and their
+// questions, as the database's cascading foreign keys would.
+func (m *MemoryRepo) DeleteDocument(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.Documents[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.data.Documents, id)
+	delete(m.data.Chunks, id)
+	for testId, test := range m.data.Tests {
+		if test.DocumentId.String() == id {
+			delete(m.data.Tests, testId)
+			delete(m.data.Questions, testId)
+		}
+	}
+
+	m.dirty = true
+	return nil
+}
+
+// DeletePageChunks removes the chunks starting on a page of a document.
+func (m *MemoryRepo) DeletePageChunks(ctx context.Context, document_id string, page int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := []models.Chunk{}
+	for _, chunk := range m.data.Chunks[document_id] {
+		if chunk.PageFrom != page {
+			kept = append(kept, chunk)
+		}
+	}
+	m.data.Chunks[document_id] = kept
+
+	m.dirty = true
+	return nil
+}
+
+// InsertChunks saves the chunks, or none of them when one belongs to a
+// document that does not exist, as the database's foreign key would.
+func (m *MemoryRepo) InsertChunks(ctx context.Context, chunks []models.Chunk) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, chunk := range chunks {
+		if _, ok := m.data.Documents[chunk.DocumentId.String()]; !ok {
+			return fmt.Errorf("document %s does not exist", chunk.DocumentId)
+		}
+	}
+	for _, chunk := range chunks {
+		if chunk.Id == uuid.Nil {
+			chunk.Id = uuid.New()
+		}
+		chunk.Score = 0
+		document := chunk.DocumentId.String()
+		m.data.Chunks[document] = append(m.data.Chunks[document], chunk)
+	}
+
+	m.dirty = true
+	return nil
+}
+
+// VectorSearch scores every chunk of the document against the prompt.
+func (m *MemoryRepo) VectorSearch(ctx context.Context, document_id string, prompt []float32, limit int) ([]models.Chunk, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	chunks := []models.Chunk{}
+	for _, chunk := range m.data.Chunks[document_id] {
+		if len(chunk.ChunkEmbedding) != len(prompt) {
+			return nil, fmt.Errorf("expected %d dimensions, chunk %s has %d", len(prompt), chunk.Id, len(chunk.ChunkEmbedding))
+		}
+		chunk.Score = m.similarity(prompt, chunk.ChunkEmbedding)
+		chunk.ChunkEmbedding = nil
+		chunks = append(chunks, chunk)
+	}
+
+	return top(chunks, limit), nil
+}
+
+func (m *MemoryRepo) similarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if m.metric == InnerProduct {
+		return dot
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// KeywordSearch ranks the chunks of the document containing any word of the
+// query by BM25.
+func (m *MemoryRepo) KeywordSearch(ctx context.Context, document_id string, query string, limit int) ([]models.Chunk, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	queryTerms := terms(query)
+	documentChunks := m.data.Chunks[document_id]
+	if len(queryTerms) == 0 || len(documentChunks) == 0 {
+		return []models.Chunk{}, nil
+	}
+
+	counts := make([]map[string]int, len(documentChunks))
+	lengths := make([]int, len(documentChunks))
+	frequency := map[string]int{}
+	total := 0
+	for i, chunk := range documentChunks {
+		counts[i] = map[string]int{}
+		words := terms(chunk.Chunk)
+		for _, word := range words {
+			counts[i][word]++
+		}
+		for word := range counts[i] {
+			frequency[word]++
+		}
+		lengths[i] = len(words)
+		total += len(words)
+	}
+	average := float64(total) / float64(len(documentChunks))
+
+	chunks := []models.Chunk{}
+	for i, chunk := range documentChunks {
+		score := 0.0
+		for _, term := range queryTerms {
+			tf := float64(counts[i][term])
+			if tf == 0 {
+				continue
+			}
+			n := float64(frequency[term])
+			idf := math.Log(1 + (float64(len(documentChunks))-n+0.5)/(n+0.5))
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(lengths[i])/average))
+		}
+		if score > 0 {
+			chunk.Score = score
+			chunk.ChunkEmbedding = nil
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	return top(chunks, limit), nil
+}
+
+// terms splits text into lower cased words.
+func terms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func top(chunks []models.Chunk, limit int) []models.Chunk {
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i].Score > chunks[j].Score
+	})
+	if limit >= 0 && len(chunks) > limit {
+		chunks = chunks[:limit]
+	}
+	return chunks
+}
+
+func (m *MemoryRepo) InsertJob(ctx context.Context, job models.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := job.Id.String()
+	if _, ok := m.data.Jobs[id]; ok {
+		return fmt.Errorf("job %s already exists", id)
+	}
+	m.data.Jobs[id] = job
+
+	m.dirty = true
+	return nil
+}
+
+func (m *MemoryRepo) RetrieveJob(ctx context.Context, id string) (models.Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.data.Jobs[id]
+	if !ok {
+		return job, sql.ErrNoRows
+	}
+	return job, nil
+}
+
+func (m *MemoryRepo) UpdateJob(ctx context.Context, job models.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := job.Id.String()
+	existing, ok := m.data.Jobs[id]
+	if !ok {
+		return nil
+	}
+	job.Kind = existing.Kind
+	job.CreatedAt = existing.CreatedAt
+	m.data.Jobs[id] = job
+
+	m.dirty = true
+	return nil
+}
+
+// ClaimJob marks the oldest pending job as running and returns it. The bool
+// is false when there is nothing to do.
+func (m *MemoryRepo) ClaimJob(ctx context.Context) (models.Job, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var oldest *models.Job
+	for _, job := range m.data.Jobs {
+		if job.Status != models.JobPending {
+			continue
+		}
+		if oldest == nil || job.CreatedAt.Before(oldest.CreatedAt) {
+			job := job
+			oldest = &job
+		}
+	}
+	if oldest == nil {
+		return models.Job{}, false, nil
+	}
+
+	oldest.Status = models.JobRunning
+	oldest.UpdatedAt = time.Now()
+	m.data.Jobs[oldest.Id.String()] = *oldest
+
+	m.dirty = true
+	return *oldest, true, nil
+}
+
+// RequeueRunningJobs puts jobs that were running when the service stopped back
+// in the queue so they are resumed.
+func (m *MemoryRepo) RequeueRunningJobs(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var requeued int64
+	for id, job := range m.data.Jobs {
+		if job.Status == models.JobRunning {
+			job.Status = models.JobPending
+			job.UpdatedAt = time.Now()
+			m.data.Jobs[id] = job
+			requeued++
+		}
+	}
+
+	m.dirty = true
+	return requeued, nil
+}
+
+func (m *MemoryRepo) InsertTest(ctx context.Context, test models.Test) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := test.Id.String()
+	if _, ok := m.data.Tests[id]; ok {
+		return "", fmt.Errorf("test %s already exists", id)
+	}
+	if _, ok := m.data.Documents[test.DocumentId.String()]; !ok {
+		return "", fmt.Errorf("document %s does not exist", test.DocumentId)
+	}
+	test.Questions = nil
+	m.data.Tests[id] = test
+
+	m.dirty = true
+	return id, nil
+}
+
+func (m *MemoryRepo) RetrieveTest(ctx context.Context, id string) (models.Test, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	test, ok := m.data.Tests[id]
+	if !ok {
+		return test, sql.ErrNoRows
+	}
+	return test, nil
+}
+
+func (m *MemoryRepo) ListTests(ctx context.Context, document_id string) ([]models.Test, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tests := []models.Test{}
+	for _, test := range m.data.Tests {
+		if document_id == "" || test.DocumentId.String() == document_id {
+			tests = append(tests, test)
+		}
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		return tests[i].CreatedAt.After(tests[j].CreatedAt)
+	})
+
+	return tests, nil
+}
+
+// InsertQuestions appends questions to the end of a test, assigning their ids
+// and positions, and returns them as saved.
+func (m *MemoryRepo) InsertQuestions(ctx context.Context, test_id string, questions []models.Question) ([]models.Question, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.Tests[test_id]; !ok {
+		return nil, fmt.Errorf("test %s does not exist", test_id)
+	}
+
+	saved := make([]models.Question, 0, len(questions))
+	for _, question := range questions {
+		if question.Id == uuid.Nil {
+			question.Id = uuid.New()
+		}
+		question.Position = len(m.data.Questions[test_id]) + 1
+		m.data.Questions[test_id] = append(m.data.Questions[test_id], question)
+		saved = append(saved, question)
+	}
+
+	m.touchTest(test_id)
+	m.dirty = true
+	return saved, nil
+}
+
+func (m *MemoryRepo) RetrieveQuestions(ctx context.Context, test_id string) ([]models.Question, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	questions := append([]models.Question{}, m.data.Questions[test_id]...)
+	sort.Slice(questions, func(i, j int) bool {
+		return questions[i].Position < questions[j].Position
+	})
+
+	return questions, nil
+}
+
+func (m *MemoryRepo) RetrieveQuestion(ctx context.Context, test_id string, id string) (models.Question, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, question := range m.data.Questions[test_id] {
+		if question.Id.String() == id {
+			return question, nil
+		}
+	}
+	return models.Question{}, sql.ErrNoRows
+}
+
+// UpdateQuestion replaces the content of a question, keeping its position.
+func (m *MemoryRepo) UpdateQuestion(ctx context.Context, test_id string, question models.Question) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, existing := range m.data.Questions[test_id] {
+		if existing.Id == question.Id {
+			question.Position = existing.Position
+			m.data.Questions[test_id][i] = question
+			m.dirty = true
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+// DeleteQuestion removes a question and closes the gap it leaves in the
+// positions of the questions after it.
+func (m *MemoryRepo) DeleteQuestion(ctx context.Context, test_id string, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	questions := m.data.Questions[test_id]
+	for i, question := range questions {
+		if question.Id.String() != id {
+			continue
+		}
+
+		kept := append(questions[:i:i], questions[i+1:]...)
+		for j := range kept {
+			if kept[j].Position > question.Position {
+				kept[j].Position--
+			}
+		}
+		m.data.Questions[test_id] = kept
+		m.touchTest(test_id)
+		m.dirty = true
+		return nil
+	}
+	return sql.ErrNoRows
+}
+
+// ReorderQuestions sets the order of every question in a test. ids must list
+// each question of the test exactly once.
+func (m *MemoryRepo) ReorderQuestions(ctx context.Context, test_id string, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	questions := m.data.Questions[test_id]
+	if len(ids) != len(questions) {
+		return ErrQuestionSetMismatch
+	}
+
+	positions := map[string]int{}
+	for i, id := range ids {
+		if _, seen := positions[id]; seen {
+			return ErrQuestionSetMismatch
+		}
+		positions[id] = i + 1
+	}
+	for _, question := range questions {
+		if _, ok := positions[question.Id.String()]; !ok {
+			return ErrQuestionSetMismatch
+		}
+	}
+
+	for i := range questions {
+		questions[i].Position = positions[questions[i].Id.String()]
+	}
+	m.touchTest(test_id)
+	m.dirty = true
+	return nil
+}
+
+// touchTest records that the questions of a test changed. The caller must
+// hold the write lock.
+func (m *MemoryRepo) touchTest(test_id string) {
+	if test, ok := m.data.Tests[test_id]; ok {
+		test.UpdatedAt = time.Now()
+		m.data.Tests[test_id] = test
+	}
+}
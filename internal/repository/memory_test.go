@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/google/uuid"
+)
+
+func chunk(document uuid.UUID, text string, embedding ...float32) models.Chunk {
+	return models.Chunk{Id: uuid.New(), DocumentId: document, Chunk: text, ChunkEmbedding: embedding, PageFrom: 1, PageTo: 1}
+}
+
+// withDocuments returns a repository holding a document for each id.
+func withDocuments(t *testing.T, metric Metric, ids ...uuid.UUID) *MemoryRepo {
+	t.Helper()
+	repo, err := NewMemoryRepo(metric, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if _, err := repo.InsertDocument(context.Background(), models.Document{Id: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func texts(chunks []models.Chunk) []string {
+	texts := []string{}
+	for _, c := range chunks {
+		texts = append(texts, c.Chunk)
+	}
+	return texts
+}
+
+func TestMemoryVectorSearch(t *testing.T) {
+	ctx := context.Background()
+	document, other := uuid.New(), uuid.New()
+	chunks := []models.Chunk{
+		chunk(document, "long", 10, 0),
+		chunk(document, "aligned", 1, 1),
+		chunk(document, "opposite", -1, -1),
+		chunk(other, "other document", 1, 1),
+	}
+
+	cases := []struct {
+		metric Metric
+		want   []string
+	}{
+		{Cosine, []string{"aligned", "long"}},
+		{InnerProduct, []string{"long", "aligned"}},
+	}
+	for _, tc := range cases {
+		repo := withDocuments(t, tc.metric, document, other)
+		repo.InsertChunks(ctx, chunks)
+
+		got, err := repo.VectorSearch(ctx, document.String(), []float32{1, 1}, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(texts(got)) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.metric, tc.want, texts(got))
+		}
+		if got[0].ChunkEmbedding != nil {
+			t.Errorf("%s: expected the embedding to be left out of the results", tc.metric)
+		}
+	}
+
+	repo := withDocuments(t, Cosine, document, other)
+	repo.InsertChunks(ctx, chunks)
+	if _, err := repo.VectorSearch(ctx, document.String(), []float32{1, 1, 1}, 2); err == nil {
+		t.Error("expected an error for a prompt of the wrong size")
+	}
+	if _, err := NewMemoryRepo("euclidean", ""); err == nil {
+		t.Error("expected an error for an unknown metric")
+	}
+}
+
+func TestMemoryKeywordSearch(t *testing.T) {
+	ctx := context.Background()
+	document, other := uuid.New(), uuid.New()
+	repo := withDocuments(t, Cosine, document, other)
+	repo.InsertChunks(ctx, []models.Chunk{
+		chunk(document, "Mitochondria produce energy. Mitochondria have their own DNA."),
+		chunk(document, "The cell uses energy for many things."),
+		chunk(document, "Ribosomes build proteins."),
+		chunk(other, "Mitochondria in another document."),
+	})
+
+	got, err := repo.KeywordSearch(ctx, document.String(), "Mitochondria energy", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Chunk[:12] != "Mitochondria" || got[0].Score <= got[1].Score {
+		t.Errorf("expected the chunks sharing words ranked by BM25, got %v", texts(got))
+	}
+}
+
+func TestMemoryMissingIds(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := NewMemoryRepo(Cosine, "")
+	id := uuid.NewString()
+
+	if _, err := repo.RetrieveDocument(ctx, id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing document, got %v", err)
+	}
+	if _, err := repo.RetrieveJob(ctx, id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing job, got %v", err)
+	}
+	if _, err := repo.RetrieveTest(ctx, id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing test, got %v", err)
+	}
+	if _, err := repo.RetrieveQuestion(ctx, id, id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing question, got %v", err)
+	}
+	if err := repo.DeleteQuestion(ctx, id, id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows deleting a missing question, got %v", err)
+	}
+	if _, _, err := repo.ClaimJob(ctx); err != nil {
+		t.Errorf("expected no error claiming from an empty queue, got %v", err)
+	}
+}
+
+func TestMemoryQuestions(t *testing.T) {
+	ctx := context.Background()
+	test := models.Test{Id: uuid.New(), DocumentId: uuid.New(), Title: "Cells"}
+	repo := withDocuments(t, Cosine, test.DocumentId)
+	repo.InsertTest(ctx, test)
+
+	saved, err := repo.InsertQuestions(ctx, test.Id.String(), []models.Question{
+		{Question: "a"}, {Question: "b"}, {Question: "c"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated, _ := repo.RetrieveTest(ctx, test.Id.String()); !updated.UpdatedAt.After(test.UpdatedAt) {
+		t.Errorf("expected adding questions to update the test, got %v", updated.UpdatedAt)
+	}
+	if saved[2].Position != 3 || saved[2].Id == uuid.Nil {
+		t.Fatalf("expected ids and positions to be assigned, got %+v", saved[2])
+	}
+
+	if err := repo.DeleteQuestion(ctx, test.Id.String(), saved[0].Id.String()); err != nil {
+		t.Fatal(err)
+	}
+	err = repo.ReorderQuestions(ctx, test.Id.String(), []string{saved[2].Id.String(), saved[1].Id.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	questions, _ := repo.RetrieveQuestions(ctx, test.Id.String())
+	if len(questions) != 2 || questions[0].Question != "c" || questions[0].Position != 1 || questions[1].Position != 2 {
+		t.Errorf("expected [c b] at positions 1 and 2, got %+v", questions)
+	}
+
+	err = repo.ReorderQuestions(ctx, test.Id.String(), []string{saved[2].Id.String(), saved[2].Id.String()})
+	if !errors.Is(err, ErrQuestionSetMismatch) {
+		t.Errorf("expected ErrQuestionSetMismatch for a repeated id, got %v", err)
+	}
+}
+
+func TestMemoryClaimJob(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := NewMemoryRepo(Cosine, "")
+	now := time.Now()
+	newer := models.Job{Id: uuid.New(), Status: models.JobPending, CreatedAt: now}
+	older := models.Job{Id: uuid.New(), Status: models.JobPending, CreatedAt: now.Add(-time.Minute)}
+	repo.InsertJob(ctx, newer)
+	repo.InsertJob(ctx, older)
+
+	job, ok, err := repo.ClaimJob(ctx)
+	if err != nil || !ok || job.Id != older.Id || job.Status != models.JobRunning {
+		t.Fatalf("expected the oldest job to be claimed, got %+v %v %v", job, ok, err)
+	}
+
+	requeued, err := repo.RequeueRunningJobs(ctx)
+	if err != nil || requeued != 1 {
+		t.Errorf("expected one job to be requeued, got %d %v", requeued, err)
+	}
+}
+
+func TestMemoryConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	document := uuid.New()
+	repo := withDocuments(t, Cosine, document)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			repo.InsertChunks(ctx, []models.Chunk{chunk(document, fmt.Sprint("chunk ", i), float32(i), 1)})
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.VectorSearch(ctx, document.String(), []float32{1, 1}, 5); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, _ := repo.KeywordSearch(ctx, document.String(), "chunk", 100)
+	if len(got) != 20 {
+		t.Errorf("expected every chunk to be saved, got %d", len(got))
+	}
+}
+
+func TestMemorySnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	document := models.Document{Id: uuid.New(), Url: "https://example.com/cells.pdf"}
+
+	repo, err := NewMemoryRepo(Cosine, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.InsertDocument(ctx, document)
+	repo.InsertChunks(ctx, []models.Chunk{chunk(document.Id, "Mitochondria produce energy.", 1, 0)})
+	test := models.Test{Id: uuid.New(), DocumentId: document.Id}
+	repo.InsertTest(ctx, test)
+	repo.InsertQuestions(ctx, test.Id.String(), []models.Question{{Question: "What produces energy?"}})
+	job := models.Job{Id: uuid.New(), Status: models.JobRunning}
+	repo.InsertJob(ctx, job)
+	for i := 1; i <= 100; i++ {
+		job.PagesDone = i
+		repo.UpdateJob(ctx, job)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := NewMemoryRepo(Cosine, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := restored.RetrieveDocument(ctx, document.Id.String()); err != nil || got.Url != document.Url {
+		t.Errorf("expected the document to be restored, got %+v %v", got, err)
+	}
+	if got, _ := restored.VectorSearch(ctx, document.Id.String(), []float32{1, 0}, 1); len(got) != 1 {
+		t.Errorf("expected the chunk and its embedding to be restored, got %+v", got)
+	}
+	if got, _ := restored.RetrieveQuestions(ctx, test.Id.String()); len(got) != 1 || got[0].Position != 1 {
+		t.Errorf("expected the questions to be restored, got %+v", got)
+	}
+	if got, _ := restored.RetrieveJob(ctx, job.Id.String()); got.PagesDone != 100 {
+		t.Errorf("expected the last job progress to be written on close, got %+v", got)
+	}
+	restored.Close()
+}
+
+func TestMemoryDeleteDocumentCascades(t *testing.T) {
+	ctx := context.Background()
+	test := models.Test{Id: uuid.New(), DocumentId: uuid.New(), Title: "Cells"}
+	repo := withDocuments(t, Cosine, test.DocumentId)
+	repo.InsertTest(ctx, test)
+	repo.InsertQuestions(ctx, test.Id.String(), []models.Question{{Question: "a"}})
+
+	if err := repo.DeleteDocument(ctx, test.DocumentId.String()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.RetrieveTest(ctx, test.Id.String()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the test to be deleted with its document, got %v", err)
+	}
+	if questions, _ := repo.RetrieveQuestions(ctx, test.Id.String()); len(questions) != 0 {
+		t.Errorf("expected the questions to be deleted with their test, got %+v", questions)
+	}
+}
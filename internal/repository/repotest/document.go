@@ -0,0 +1,357 @@
+// Package repotest holds test suites every implementation of the repository
+// interfaces has to pass, so they can be swapped for one another.
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Document runs the DocumentInterface suite against the repository newRepo
+// returns, storing embeddings of dims dimensions. Every test inserts its own
+// documents, so newRepo may return the same repository each time.
+func Document(t *testing.T, dims int, newRepo func(t *testing.T) repository.DocumentInterface) {
+	t.Run("RetrieveDocument", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		doc := newDocument()
+
+		id, err := repo.InsertDocument(ctx, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != doc.Id.String() {
+			t.Errorf("expected the id %s, got %s", doc.Id, id)
+		}
+
+		got, err := repo.RetrieveDocument(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Id != doc.Id || got.Url != doc.Url || !got.CreatedAt.Equal(doc.CreatedAt) {
+			t.Errorf("expected %+v, got %+v", doc, got)
+		}
+	})
+
+	t.Run("RetrieveMissingDocument", func(t *testing.T) {
+		_, err := newRepo(t).RetrieveDocument(context.Background(), uuid.NewString())
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("expected sql.ErrNoRows, got %v", err)
+		}
+	})
+
+	t.Run("VectorSearch", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		doc := insertDocument(t, repo)
+
+		chunks := []models.Chunk{
+			newChunk(doc, "orthogonal", axis(dims, 1)),
+			newChunk(doc, "exact", axis(dims, 0)),
+			newChunk(doc, "close", between(dims, 0, 1, 0.8)),
+			newChunk(doc, "opposite", scale(axis(dims, 0), -1)),
+		}
+		chunks[1].PageFrom, chunks[1].PageTo, chunks[1].Start, chunks[1].End = 3, 4, 10, 250
+		chunks[1].Strategy, chunks[1].Heading = "heading", "Cells"
+		if err := repo.InsertChunks(ctx, chunks); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := repo.VectorSearch(ctx, doc.Id.String(), axis(dims, 0), 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"exact", "close", "orthogonal"}
+		if len(got) != len(want) {
+			t.Fatalf("expected %d chunks, got %d", len(want), len(got))
+		}
+		for i := range want {
+			if got[i].Chunk != want[i] {
+				t.Errorf("expected %q at %d, got %q", want[i], i, got[i].Chunk)
+			}
+			if i > 0 && got[i].Score > got[i-1].Score {
+				t.Errorf("expected scores in descending order, got %v after %v", got[i].Score, got[i-1].Score)
+			}
+		}
+
+		exact, expected := got[0], chunks[1]
+		if exact.Id != expected.Id || exact.DocumentId != expected.DocumentId || exact.PageFrom != 3 || exact.PageTo != 4 ||
+			exact.Start != 10 || exact.End != 250 || exact.Strategy != "heading" || exact.Heading != "Cells" {
+			t.Errorf("expected the chunk to round trip, got %+v", exact)
+		}
+		if exact.Score < 0.99 || exact.Score > 1.01 {
+			t.Errorf("expected an identical unit vector to score 1, got %v", exact.Score)
+		}
+	})
+
+	t.Run("VectorSearchIsolatesDocuments", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		doc, other := insertDocument(t, repo), insertDocument(t, repo)
+
+		err := repo.InsertChunks(ctx, []models.Chunk{
+			newChunk(doc, "mine", axis(dims, 1)),
+			newChunk(other, "theirs", axis(dims, 0)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := repo.VectorSearch(ctx, doc.Id.String(), axis(dims, 0), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].Chunk != "mine" {
+			t.Errorf("expected only the chunk of the document, got %+v", got)
+		}
+
+		got, err = repo.VectorSearch(ctx, uuid.NewString(), axis(dims, 0), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 0 {
+			t.Errorf("expected no chunks for a missing document, got %+v", got)
+		}
+	})
+
+	t.Run("KeywordSearch", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		doc, other := insertDocument(t, repo), insertDocument(t, repo)
+
+		err := repo.InsertChunks(ctx, []models.Chunk{
+			newChunk(doc, "Ribosomes build proteins.", axis(dims, 0)),
+			newChunk(doc, "Mitochondria produce energy. Mitochondria have their own DNA.", axis(dims, 0)),
+			newChunk(doc, "The cell stores energy as fat.", axis(dims, 0)),
+			newChunk(other, "Mitochondria of another document.", axis(dims, 0)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := repo.KeywordSearch(ctx, doc.Id.String(), "mitochondria energy", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got[0].Chunk != "Mitochondria produce energy. Mitochondria have their own DNA." {
+			t.Errorf("expected the chunks sharing words, best first, got %+v", got)
+		}
+
+		got, err = repo.KeywordSearch(ctx, doc.Id.String(), "mitochondria energy", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 {
+			t.Errorf("expected the limit to be respected, got %d chunks", len(got))
+		}
+	})
+
+	t.Run("KeywordSearchRanksByBM25", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		doc := insertDocument(t, repo)
+
+		// Words without stop words, whose stems match across the chunks, score
+		// the same whether or not the backend stems them.
+		err := repo.InsertChunks(ctx, []models.Chunk{
+			newChunk(doc, "cell cell cell cell", axis(dims, 0)),
+			newChunk(doc, "membrane protein", axis(dims, 0)),
+			newChunk(doc, "cell wall", axis(dims, 0)),
+			newChunk(doc, "protein", axis(dims, 0)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := repo.KeywordSearch(ctx, doc.Id.String(), "cell membrane", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 4 chunks of 9 words; cell is in 2 of them and membrane in 1.
+		average := 9.0 / 4
+		want := []struct {
+			chunk string
+			score float64
+		}{
+			{"membrane protein", bm25(1, 2, average, 4, 1)},
+			{"cell cell cell cell", bm25(4, 4, average, 4, 2)},
+			{"cell wall", bm25(1, 2, average, 4, 2)},
+		}
+		if len(got) != len(want) {
+			t.Fatalf("expected %d chunks, got %+v", len(want), got)
+		}
+		for i, w := range want {
+			if got[i].Chunk != w.chunk || math.Abs(got[i].Score-w.score) > 1e-6 {
+				t.Errorf("expected %q with score %f at %d, got %q with %f", w.chunk, w.score, i, got[i].Chunk, got[i].Score)
+			}
+		}
+	})
+
+	t.Run("InsertChunksNeedsDocument", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		doc := insertDocument(t, repo)
+
+		err := repo.InsertChunks(ctx, []models.Chunk{
+			newChunk(doc, "kept", axis(dims, 0)),
+			newChunk(newDocument(), "orphan", axis(dims, 0)),
+		})
+		if err == nil {
+			t.Fatal("expected an error for a chunk of a missing document")
+		}
+
+		got, err := repo.VectorSearch(ctx, doc.Id.String(), axis(dims, 0), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 0 {
+			t.Errorf("expected a failed insert to leave no chunks behind, got %+v", got)
+		}
+	})
+
+	t.Run("DeletePageChunks", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		doc, other := insertDocument(t, repo), insertDocument(t, repo)
+
+		second := newChunk(doc, "page two", axis(dims, 0))
+		second.PageFrom, second.PageTo = 2, 2
+		err := repo.InsertChunks(ctx, []models.Chunk{
+			newChunk(doc, "page one", axis(dims, 0)),
+			second,
+			newChunk(other, "other page one", axis(dims, 0)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := repo.DeletePageChunks(ctx, doc.Id.String(), 1); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := repo.VectorSearch(ctx, doc.Id.String(), axis(dims, 0), 10); len(got) != 1 || got[0].Chunk != "page two" {
+			t.Errorf("expected only the chunks of the page to be deleted, got %+v", got)
+		}
+		if got, _ := repo.VectorSearch(ctx, other.Id.String(), axis(dims, 0), 10); len(got) != 1 {
+			t.Errorf("expected the chunks of other documents to be kept, got %+v", got)
+		}
+	})
+
+	t.Run("DeleteDocument", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		doc, other := insertDocument(t, repo), insertDocument(t, repo)
+
+		err := repo.InsertChunks(ctx, []models.Chunk{
+			newChunk(doc, "deleted", axis(dims, 0)),
+			newChunk(other, "kept", axis(dims, 0)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := repo.DeleteDocument(ctx, doc.Id.String()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.RetrieveDocument(ctx, doc.Id.String()); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("expected the document to be gone, got %v", err)
+		}
+		if got, _ := repo.VectorSearch(ctx, doc.Id.String(), axis(dims, 0), 10); len(got) != 0 {
+			t.Errorf("expected the chunks of the document to be deleted with it, got %+v", got)
+		}
+		if got, _ := repo.VectorSearch(ctx, other.Id.String(), axis(dims, 0), 10); len(got) != 1 {
+			t.Errorf("expected the chunks of other documents to be kept, got %+v", got)
+		}
+
+		if err := repo.DeleteDocument(ctx, doc.Id.String()); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("expected sql.ErrNoRows deleting a missing document, got %v", err)
+		}
+	})
+
+	t.Run("VectorSearchReportsErrors", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		doc := insertDocument(t, repo)
+		if err := repo.InsertChunks(ctx, []models.Chunk{newChunk(doc, "chunk", axis(dims, 0))}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := repo.VectorSearch(ctx, doc.Id.String(), axis(dims+1, 0), 1); err == nil {
+			t.Error("expected an error for a prompt of the wrong size")
+		}
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := repo.VectorSearch(cancelled, doc.Id.String(), axis(dims, 0), 1); err == nil {
+			t.Error("expected an error for a cancelled context")
+		}
+	})
+}
+
+// bm25 scores one query term occurring tf times in a chunk of length words,
+// out of n chunks averaging average words, df of which hold the term.
+func bm25(tf, length, average, n, df float64) float64 {
+	const k1, b = 1.2, 0.75
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+	return idf * tf * (k1 + 1) / (tf + k1*(1-b+b*length/average))
+}
+
+func newDocument() models.Document {
+	return models.Document{
+		Id:        uuid.New(),
+		Url:       "uploads/" + uuid.NewString() + ".pdf",
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
+
+func insertDocument(t *testing.T, repo repository.DocumentInterface) models.Document {
+	t.Helper()
+	doc := newDocument()
+	if _, err := repo.InsertDocument(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func newChunk(doc models.Document, text string, embedding []float32) models.Chunk {
+	return models.Chunk{
+		Id:             uuid.New(),
+		DocumentId:     doc.Id,
+		Chunk:          text,
+		ChunkEmbedding: embedding,
+		PageFrom:       1,
+		PageTo:         1,
+		Strategy:       "fixed",
+	}
+}
+
+// axis returns the unit vector along dimension i. Unit vectors score the same
+// by cosine similarity and inner product.
+func axis(dims, i int) []float32 {
+	v := make([]float32, dims)
+	v[i] = 1
+	return v
+}
+
+// between returns the unit vector with weight on dimension i and the rest on j.
+func between(dims, i, j int, weight float32) []float32 {
+	v := make([]float32, dims)
+	v[i] = weight
+	v[j] = float32(math.Sqrt(float64(1 - weight*weight)))
+	return v
+}
+
+func scale(v []float32, factor float32) []float32 {
+	for i := range v {
+		v[i] *= factor
+	}
+	return v
+}
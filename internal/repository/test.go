@@ -0,0 +1,360 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrQuestionSetMismatch is returned by ReorderQuestions when the ids given
+// are not exactly the questions of the test.
+var ErrQuestionSetMismatch = errors.New("question ids do not match the questions of the test")
+
+type TestInterface interface {
+	InsertTest(ctx context.Context, test models.Test) (string, error)
+	RetrieveTest(ctx context.Context, id string) (models.Test, error)
+	ListTests(ctx context.Context, document_id string) ([]models.Test, error)
+	InsertQuestions(ctx context.Context, test_id string, questions []models.Question) ([]models.Question, error)
+	RetrieveQuestions(ctx context.Context, test_id string) ([]models.Question, error)
+	RetrieveQuestion(ctx context.Context, test_id string, id string) (models.Question, error)
+	UpdateQuestion(ctx context.Context, test_id string, question models.Question) error
+	DeleteQuestion(ctx context.Context, test_id string, id string) error
+	ReorderQuestions(ctx context.Context, test_id string, ids []string) error
+}
+
+type testRepo struct {
+	DB *pgxpool.Pool
+}
+
+func NewPostgresTestRepo(conn *pgxpool.Pool) TestInterface {
+	return &testRepo{
+		DB: conn,
+	}
+}
+
+func (m *testRepo) InsertTest(ctx context.Context, test models.Test) (string, error) {
+	var newID string
+
+	subjects, err := json.Marshal(test.Subjects)
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `
+		insert into tests (id, document, title, subjects, created_at, updated_at) values ($1, $2, $3, $4, $5, $6) returning id
+	`
+	err = m.DB.QueryRow(ctx, stmt,
+		test.Id,
+		test.DocumentId,
+		test.Title,
+		subjects,
+		test.CreatedAt,
+		test.UpdatedAt,
+	).Scan(&newID)
+	if err != nil {
+		return "", err
+	}
+
+	return newID, nil
+}
+
+func scanTest(row interface{ Scan(...any) error }) (models.Test, error) {
+	var test models.Test
+	var subjects []byte
+
+	err := row.Scan(
+		&test.Id,
+		&test.DocumentId,
+		&test.Title,
+		&subjects,
+		&test.CreatedAt,
+		&test.UpdatedAt,
+	)
+	if err != nil {
+		return test, err
+	}
+
+	err = json.Unmarshal(subjects, &test.Subjects)
+	return test, err
+}
+
+func (m *testRepo) RetrieveTest(ctx context.Context, id string) (models.Test, error) {
+	query := `
+		select id, document, title, subjects, created_at, updated_at from tests where id = $1
+	`
+
+	return scanTest(m.DB.QueryRow(ctx, query, id))
+}
+
+// ListTests returns the tests of a document, or every test when document_id
+// is empty. document_id must be a uuid.
+func (m *testRepo) ListTests(ctx context.Context, document_id string) ([]models.Test, error) {
+	tests := []models.Test{}
+
+	var document *string
+	if document_id != "" {
+		document = &document_id
+	}
+
+	query := `
+		select id, document, title, subjects, created_at, updated_at from tests
+		where $1::uuid is null or document = $1::uuid
+		order by created_at desc
+	`
+	rows, err := m.DB.Query(ctx, query, document)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		test, err := scanTest(rows)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, test)
+	}
+
+	return tests, rows.Err()
+}
+
+// InsertQuestions appends questions to the end of a test, assigning their ids
+// and positions, and returns them as saved.
+func (m *testRepo) InsertQuestions(ctx context.Context, test_id string, questions []models.Question) ([]models.Question, error) {
+	tx, err := m.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockTest(ctx, tx, test_id); err != nil {
+		return nil, err
+	}
+
+	var position int
+	err = tx.QueryRow(ctx, `
+		select coalesce(max(position), 0) from questions where test = $1
+	`, test_id).Scan(&position)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := make([]models.Question, 0, len(questions))
+	now := time.Now()
+	for _, question := range questions {
+		position++
+		if question.Id == uuid.Nil {
+			question.Id = uuid.New()
+		}
+		question.Position = position
+
+		content, err := json.Marshal(question)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(ctx, `
+			insert into questions (id, test, position, type, content, created_at, updated_at) values ($1, $2, $3, $4, $5, $6, $7)
+		`, question.Id, test_id, question.Position, question.Type, content, now, now)
+		if err != nil {
+			return nil, err
+		}
+		saved = append(saved, question)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return saved, nil
+}
+
+func scanQuestion(row interface{ Scan(...any) error }) (models.Question, error) {
+	var question models.Question
+	var id uuid.UUID
+	var position int
+	var content []byte
+
+	if err := row.Scan(&id, &position, &content); err != nil {
+		return question, err
+	}
+	if err := json.Unmarshal(content, &question); err != nil {
+		return question, err
+	}
+
+	question.Id = id
+	question.Position = position
+	return question, nil
+}
+
+func (m *testRepo) RetrieveQuestions(ctx context.Context, test_id string) ([]models.Question, error) {
+	questions := []models.Question{}
+
+	query := `
+		select id, position, content from questions where test = $1 order by position
+	`
+	rows, err := m.DB.Query(ctx, query, test_id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		question, err := scanQuestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, question)
+	}
+
+	return questions, rows.Err()
+}
+
+func (m *testRepo) RetrieveQuestion(ctx context.Context, test_id string, id string) (models.Question, error) {
+	query := `
+		select id, position, content from questions where test = $1 and id = $2
+	`
+
+	return scanQuestion(m.DB.QueryRow(ctx, query, test_id, id))
+}
+
+// UpdateQuestion replaces the content of a question, keeping its position.
+func (m *testRepo) UpdateQuestion(ctx context.Context, test_id string, question models.Question) error {
+	content, err := json.Marshal(question)
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+		update questions set type = $3, content = $4, updated_at = $5 where test = $1 and id = $2
+	`
+	tag, err := m.DB.Exec(ctx, stmt, test_id, question.Id, question.Type, content, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return expectRow(tag)
+}
+
+// DeleteQuestion removes a question and closes the gap it leaves in the
+// positions of the questions after it.
+func (m *testRepo) DeleteQuestion(ctx context.Context, test_id string, id string) error {
+	tx, err := m.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockTest(ctx, tx, test_id); err != nil {
+		return err
+	}
+
+	var position int
+	err = tx.QueryRow(ctx, `
+		delete from questions where test = $1 and id = $2 returning position
+	`, test_id, id).Scan(&position)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		update questions set position = position - 1 where test = $1 and position > $2
+	`, test_id, position)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ReorderQuestions sets the order of every question in a test. ids must list
+// each question of the test exactly once.
+func (m *testRepo) ReorderQuestions(ctx context.Context, test_id string, ids []string) error {
+	tx, err := m.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockTest(ctx, tx, test_id); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `
+		select id from questions where test = $1 for update
+	`, test_id)
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]bool{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[id.String()] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(ids) != len(existing) {
+		return ErrQuestionSetMismatch
+	}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if !existing[id] || seen[id] {
+			return ErrQuestionSetMismatch
+		}
+		seen[id] = true
+	}
+
+	for i, id := range ids {
+		_, err := tx.Exec(ctx, `
+			update questions set position = $3 where test = $1 and id = $2
+		`, test_id, id, i+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// lockTest marks a test as updated, holding the lock on its row until tx ends
+// so that changes to the positions of its questions happen one at a time. The
+// unique (test, position) constraint is checked at commit, which lets them
+// shift positions in between.
+func lockTest(ctx context.Context, tx pgx.Tx, test_id string) error {
+	tag, err := tx.Exec(ctx, `
+		update tests set updated_at = $2 where id = $1
+	`, test_id, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return expectRow(tag)
+}
+
+func expectRow(tag pgconn.CommandTag) error {
+	n := tag.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	if n > 1 {
+		return fmt.Errorf("expected one row to change, %d did", n)
+	}
+	return nil
+}
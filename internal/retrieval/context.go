@@ -0,0 +1,162 @@
+package retrieval
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+)
+
+const (
+	DefaultPerSubject    = 3
+	DefaultLambda        = 0.7
+	DefaultContextTokens = 3000
+
+	// defaultQuery stands in for the subjects when a test has none.
+	defaultQuery = "Please generate a list of questions"
+)
+
+// Context gathers the chunks to generate questions about the subjects from.
+// Each subject is searched on its own and gets up to PerSubject chunks, taken
+// in turns so that no subject crowds out the others. Within its turn a
+// subject picks by maximal marginal relevance, trading how well a chunk
+// matches the subject against how much it repeats the pages and words
+// already chosen. Chunks that would take the context over ContextTokens are
+// skipped.
+func (r *Retriever) Context(ctx context.Context, documentId string, subjects []string) ([]models.Chunk, error) {
+	queries := []string{}
+	for _, subject := range subjects {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			queries = append(queries, subject)
+		}
+	}
+	budget := r.options.PerSubject
+	if len(queries) == 0 {
+		queries = []string{defaultQuery}
+		budget = r.options.Limit
+	}
+
+	pools := make([][]models.Chunk, len(queries))
+	for i, query := range queries {
+		candidates, err := r.search(ctx, documentId, query, budget*candidatesPerResult)
+		if err != nil {
+			return nil, err
+		}
+		pools[i] = candidates
+	}
+
+	return Select(pools, budget, r.options.Lambda, r.options.ContextTokens, r.count), nil
+}
+
+func (r *Retriever) count(text string) int {
+	return len(r.tokenizer.Split(text))
+}
+
+// Select takes up to budget chunks from every pool by maximal marginal
+// relevance, the pools taking turns, until maxTokens are used up as counted
+// by count. Each pool must be ordered best first; a chunk's relevance is its
+// score relative to the best of its pool. lambda weighs relevance against
+// redundancy, 1 ignoring redundancy altogether.
+func Select(pools [][]models.Chunk, budget int, lambda float64, maxTokens int, count func(string) int) []models.Chunk {
+	selected := []models.Chunk{}
+	chosen := map[string]bool{}
+	words := [][]string{}
+	taken := make([]int, len(pools))
+	tokens := 0
+	counted := map[string]int{}
+	size := func(chunk models.Chunk) int {
+		id := chunk.Id.String()
+		if _, ok := counted[id]; !ok {
+			counted[id] = count(chunk.Chunk)
+		}
+		return counted[id]
+	}
+
+	for {
+		added := false
+		for p, pool := range pools {
+			if taken[p] >= budget {
+				continue
+			}
+
+			best, bestScore := -1, 0.0
+			for i, chunk := range pool {
+				id := chunk.Id.String()
+				if chosen[id] || tokens+size(chunk) > maxTokens {
+					continue
+				}
+
+				relevance := 1.0
+				if pool[0].Score > 0 {
+					relevance = chunk.Score / pool[0].Score
+				}
+				redundancy := 0.0
+				chunkWords := wordSet(chunk.Chunk)
+				for j, other := range selected {
+					redundancy = max(redundancy, similarity(chunk, other, chunkWords, words[j]))
+				}
+
+				score := lambda*relevance - (1-lambda)*redundancy
+				if best < 0 || score > bestScore {
+					best, bestScore = i, score
+				}
+			}
+			if best < 0 {
+				taken[p] = budget
+				continue
+			}
+
+			chunk := pool[best]
+			chosen[chunk.Id.String()] = true
+			selected = append(selected, chunk)
+			words = append(words, wordSet(chunk.Chunk))
+			tokens += size(chunk)
+			taken[p]++
+			added = true
+		}
+		if !added {
+			return selected
+		}
+	}
+}
+
+// similarity is 1 for chunks from the same pages, and otherwise how many of
+// their words they share.
+func similarity(a, b models.Chunk, aWords, bWords []string) float64 {
+	if a.PageFrom > 0 && b.PageFrom > 0 && a.PageFrom <= b.PageTo && b.PageFrom <= a.PageTo {
+		return 1
+	}
+	return jaccard(aWords, bWords)
+}
+
+func wordSet(text string) []string {
+	seen := map[string]bool{}
+	words := []string{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+func jaccard(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	set := map[string]bool{}
+	for _, word := range a {
+		set[word] = true
+	}
+	shared := 0
+	for _, word := range b {
+		if set[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
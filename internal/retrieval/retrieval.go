@@ -0,0 +1,147 @@
+// Package retrieval finds the chunks of a document relevant to a query,
+// fusing the rankings of vector and keyword search so that exact terms the
+// embedding glosses over still surface.
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/bjorndonald/test-maker-service/internal/chunker"
+	"github.com/bjorndonald/test-maker-service/internal/llm"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/bjorndonald/test-maker-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 5
+	// DefaultK is the reciprocal rank fusion constant of the original paper.
+	// Larger values flatten the difference between the top ranks.
+	DefaultK = 60
+	// candidatesPerResult is how many results of each search are fused for
+	// every result returned.
+	candidatesPerResult = 4
+)
+
+// Options configure a search. A weight of zero turns its search off, and
+// when both are zero both searches count the same. PerSubject, Lambda and
+// ContextTokens shape the context gathered for several subjects.
+type Options struct {
+	Limit         int
+	K             int
+	VectorWeight  float64
+	KeywordWeight float64
+	PerSubject    int
+	Lambda        float64
+	ContextTokens int
+}
+
+func (o Options) withDefaults() Options {
+	if o.Limit <= 0 {
+		o.Limit = DefaultLimit
+	}
+	if o.K <= 0 {
+		o.K = DefaultK
+	}
+	if o.VectorWeight <= 0 && o.KeywordWeight <= 0 {
+		o.VectorWeight, o.KeywordWeight = 1, 1
+	}
+	if o.PerSubject <= 0 {
+		o.PerSubject = DefaultPerSubject
+	}
+	if o.Lambda <= 0 || o.Lambda > 1 {
+		o.Lambda = DefaultLambda
+	}
+	if o.ContextTokens <= 0 {
+		o.ContextTokens = DefaultContextTokens
+	}
+	return o
+}
+
+type Retriever struct {
+	docuRepo  repository.DocumentInterface
+	embedder  llm.Embedder
+	tokenizer chunker.Tokenizer
+	options   Options
+}
+
+func New(docuRepo repository.DocumentInterface, embedder llm.Embedder, tokenizer chunker.Tokenizer, options Options) *Retriever {
+	return &Retriever{
+		docuRepo:  docuRepo,
+		embedder:  embedder,
+		tokenizer: tokenizer,
+		options:   options.withDefaults(),
+	}
+}
+
+// Search returns up to Limit chunks of the document for the query, best
+// first, each scored by reciprocal rank fusion.
+func (r *Retriever) Search(ctx context.Context, documentId string, query string) ([]models.Chunk, error) {
+	return r.search(ctx, documentId, query, r.options.Limit)
+}
+
+func (r *Retriever) search(ctx context.Context, documentId string, query string, limit int) ([]models.Chunk, error) {
+	candidates := limit * candidatesPerResult
+	rankings := []Ranking{}
+
+	if r.options.VectorWeight > 0 {
+		embeds, err := r.embedder.Embed(ctx, []string{query})
+		if err != nil {
+			return nil, fmt.Errorf("embedding error: %w", err)
+		}
+
+		chunks, err := r.docuRepo.VectorSearch(ctx, documentId, embeds[0], candidates)
+		if err != nil {
+			return nil, err
+		}
+		rankings = append(rankings, Ranking{Chunks: chunks, Weight: r.options.VectorWeight})
+	}
+
+	if r.options.KeywordWeight > 0 {
+		chunks, err := r.docuRepo.KeywordSearch(ctx, documentId, query, candidates)
+		if err != nil {
+			return nil, err
+		}
+		rankings = append(rankings, Ranking{Chunks: chunks, Weight: r.options.KeywordWeight})
+	}
+
+	return Fuse(r.options.K, limit, rankings...), nil
+}
+
+// Ranking is the result of one search, best first.
+type Ranking struct {
+	Chunks []models.Chunk
+	Weight float64
+}
+
+// Fuse merges rankings with reciprocal rank fusion: a chunk scores
+// weight / (k + rank) in every ranking it appears in, rank starting at 1.
+// Ties keep the order the chunks were first seen in.
+func Fuse(k int, limit int, rankings ...Ranking) []models.Chunk {
+	fused := []models.Chunk{}
+	index := map[uuid.UUID]int{}
+
+	for _, ranking := range rankings {
+		for rank, chunk := range ranking.Chunks {
+			score := ranking.Weight / float64(k+rank+1)
+			if i, ok := index[chunk.Id]; ok {
+				fused[i].Score += score
+				continue
+			}
+
+			index[chunk.Id] = len(fused)
+			chunk.Score = score
+			fused = append(fused, chunk)
+		}
+	}
+
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].Score > fused[j].Score
+	})
+	if len(fused) > limit {
+		fused = fused[:limit]
+	}
+	return fused
+}
@@ -0,0 +1,192 @@
+package retrieval
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bjorndonald/test-maker-service/internal/chunker"
+	"github.com/bjorndonald/test-maker-service/internal/llm"
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/google/uuid"
+)
+
+func chunks(names ...string) []models.Chunk {
+	result := make([]models.Chunk, len(names))
+	for i, name := range names {
+		result[i] = models.Chunk{Id: uuid.NewSHA1(uuid.Nil, []byte(name)), Chunk: name}
+	}
+	return result
+}
+
+func texts(chunks []models.Chunk) []string {
+	result := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		result[i] = chunk.Chunk
+	}
+	return result
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFuseRewardsAgreement(t *testing.T) {
+	fused := Fuse(60, 3,
+		Ranking{Chunks: chunks("a", "b", "c", "d"), Weight: 1},
+		Ranking{Chunks: chunks("c", "e", "b"), Weight: 1},
+	)
+
+	if got := texts(fused); !equal(got, []string{"c", "b", "a"}) {
+		t.Errorf("expected chunks found by both searches first, got %v", got)
+	}
+	if want := 1.0/63 + 1.0/61; fused[0].Score != want {
+		t.Errorf("expected score %v, got %v", want, fused[0].Score)
+	}
+}
+
+func TestFuseWeights(t *testing.T) {
+	fused := Fuse(60, 2,
+		Ranking{Chunks: chunks("a", "b"), Weight: 0.5},
+		Ranking{Chunks: chunks("c", "d"), Weight: 2},
+	)
+	if got := texts(fused); !equal(got, []string{"c", "d"}) {
+		t.Errorf("expected the heavier ranking to win, got %v", got)
+	}
+}
+
+type stubRepo struct {
+	vector, keyword []models.Chunk
+	queries         []string
+	limits          []int
+}
+
+func (s *stubRepo) InsertChunks(ctx context.Context, chunks []models.Chunk) error { return nil }
+
+func (s *stubRepo) InsertDocument(ctx context.Context, doc models.Document) (string, error) {
+	return "", nil
+}
+
+func (s *stubRepo) RetrieveDocument(ctx context.Context, id string) (models.Document, error) {
+	return models.Document{}, nil
+}
+
+func (s *stubRepo) VectorSearch(ctx context.Context, document_id string, prompt []float32, limit int) ([]models.Chunk, error) {
+	s.limits = append(s.limits, limit)
+	return s.vector, nil
+}
+
+func (s *stubRepo) DeleteDocument(ctx context.Context, id string) error {
+	return nil
+}
+
+func (s *stubRepo) DeletePageChunks(ctx context.Context, document_id string, page int) error {
+	return nil
+}
+
+func (s *stubRepo) KeywordSearch(ctx context.Context, document_id string, query string, limit int) ([]models.Chunk, error) {
+	s.queries = append(s.queries, query)
+	s.limits = append(s.limits, limit)
+	return s.keyword, nil
+}
+
+func TestSearch(t *testing.T) {
+	repo := &stubRepo{vector: chunks("a", "b", "c"), keyword: chunks("Krebs cycle", "a")}
+	retriever := New(repo, llm.NewFake(8), chunker.Words{}, Options{Limit: 2})
+
+	found, err := retriever.Search(context.Background(), "doc", "Krebs cycle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := texts(found); !equal(got, []string{"a", "Krebs cycle"}) {
+		t.Errorf("expected the exact term to surface, got %v", got)
+	}
+	if !equal(repo.queries, []string{"Krebs cycle"}) || len(repo.limits) != 2 || repo.limits[0] != 8 || repo.limits[1] != 8 {
+		t.Errorf("unexpected searches: queries %q, limits %v", repo.queries, repo.limits)
+	}
+}
+
+func TestSearchWithoutKeywords(t *testing.T) {
+	repo := &stubRepo{vector: chunks("a", "b"), keyword: chunks("c")}
+	retriever := New(repo, llm.NewFake(8), chunker.Words{}, Options{VectorWeight: 1})
+
+	found, err := retriever.Search(context.Background(), "doc", "cells")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := texts(found); !equal(got, []string{"a", "b"}) || len(repo.queries) != 0 {
+		t.Errorf("expected keyword search to be off, got %v", got)
+	}
+}
+
+func onPages(chunks []models.Chunk, pages ...int) []models.Chunk {
+	for i := range chunks {
+		chunks[i].PageFrom, chunks[i].PageTo = pages[i], pages[i]
+		chunks[i].Score = 1 / float64(i+1)
+	}
+	return chunks
+}
+
+func words(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestSelectTakesTurns(t *testing.T) {
+	cells := onPages(chunks("cells one", "cells two", "cells three"), 1, 2, 3)
+	energy := onPages(chunks("energy one", "energy two"), 8, 9)
+
+	selected := Select([][]models.Chunk{cells, energy}, 2, 1, 100, words)
+	if got := texts(selected); !equal(got, []string{"cells one", "energy one", "cells two", "energy two"}) {
+		t.Errorf("expected each subject to get its budget in turns, got %v", got)
+	}
+}
+
+func TestSelectPrefersOtherPages(t *testing.T) {
+	pool := onPages(chunks("a", "b", "c"), 4, 4, 5)
+
+	selected := Select([][]models.Chunk{pool}, 2, DefaultLambda, 100, words)
+	if got := texts(selected); !equal(got, []string{"a", "c"}) {
+		t.Errorf("expected the second chunk to come from another page, got %v", got)
+	}
+
+	selected = Select([][]models.Chunk{pool}, 2, 1, 100, words)
+	if got := texts(selected); !equal(got, []string{"a", "b"}) {
+		t.Errorf("expected relevance alone to decide with lambda 1, got %v", got)
+	}
+}
+
+func TestSelectKeepsToTokenBudget(t *testing.T) {
+	pool := onPages(chunks("one two three", "four five six seven", "eight"), 1, 2, 3)
+
+	selected := Select([][]models.Chunk{pool}, 3, 1, 5, words)
+	if got := texts(selected); !equal(got, []string{"one two three", "eight"}) {
+		t.Errorf("expected the chunk over budget to be skipped, got %v", got)
+	}
+}
+
+func TestContextSearchesEachSubject(t *testing.T) {
+	repo := &stubRepo{vector: onPages(chunks("a", "b", "c", "d"), 1, 2, 3, 4)}
+	retriever := New(repo, llm.NewFake(8), chunker.Words{}, Options{PerSubject: 1})
+
+	found, err := retriever.Context(context.Background(), "doc", []string{"cells", " ", "energy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(repo.queries, []string{"cells", "energy"}) {
+		t.Errorf("expected a search per subject, got %q", repo.queries)
+	}
+	if got := texts(found); !equal(got, []string{"a", "b"}) {
+		t.Errorf("expected one chunk per subject, got %v", got)
+	}
+	if repo.limits[0] <= 1 {
+		t.Errorf("expected candidates beyond the budget to choose from, got limit %d", repo.limits[0])
+	}
+}
@@ -2,18 +2,38 @@ package routes
 
 import (
 	"github.com/bjorndonald/test-maker-service/internal/bootstrap"
+	"github.com/bjorndonald/test-maker-service/internal/dedup"
 	"github.com/bjorndonald/test-maker-service/internal/handlers"
 	"github.com/bjorndonald/test-maker-service/internal/middleware"
-	"github.com/bjorndonald/test-maker-service/internal/repository"
+	"github.com/bjorndonald/test-maker-service/internal/retrieval"
 	"github.com/bjorndonald/test-maker-service/internal/validators"
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterRoutes(router *gin.RouterGroup, d *bootstrap.AppDependencies) {
-	repo := repository.NewPostgresRepo(d.DatabaseService)
-	handler := handlers.NewHandler(repo)
+	repos := d.Repositories
+	retriever := retrieval.New(repos.Documents, d.Embedder, d.Tokenizer, d.Retrieval)
+	deduplicator := dedup.New(d.Embedder, d.DuplicateThreshold)
+	handler := handlers.NewHandler(repos.Documents, repos.Jobs, repos.Tests, d.Jobs, d.Embedder, d.ChatCompleter, retriever, deduplicator, d.FlagUnsupported)
 	router.POST("/analyze", middleware.FileUploadMiddleware(), handler.AnalyzePdf)
 	router.POST("/analyze/link", validators.ValidateLinkSchema, handler.AnalyzeLink)
 	router.POST("/embed", validators.ValidatePagesSchema, handler.EmbedPages)
 	router.POST("/generate", validators.ValidateQuestionSchema, handler.GenerateQuestions)
+	router.GET("/jobs/:id", handler.GetJob)
+	router.POST("/logos", middleware.LogoUploadMiddleware(), handler.UploadLogo)
+
+	router.GET("/tests", handler.ListTests)
+	router.POST("/tests/import", handler.ImportTest)
+	router.GET("/tests/:id", handler.GetTest)
+	router.GET("/tests/:id/export", handler.ExportTest)
+	router.GET("/tests/:id/pdf", validators.ValidatePaperQuery, handler.TestPaper)
+	router.GET("/tests/:id/pdf/answer-key", validators.ValidatePaperQuery, handler.AnswerKeyPaper)
+	router.GET("/tests/:id/sources.pdf", handler.SourcesPaper)
+	router.POST("/tests/:id/variants", validators.ValidateVariantsSchema, handler.CreateVariants)
+	router.GET("/tests/:id/variants/:code", handler.DownloadVariant)
+	router.GET("/tests/:id/variants/:code/key", handler.DownloadVariantKey)
+	router.PUT("/tests/:id/order", validators.ValidateReorderSchema, handler.ReorderQuestions)
+	router.PUT("/tests/:id/questions/:questionId", validators.ValidateQuestionEditSchema, handler.UpdateQuestion)
+	router.DELETE("/tests/:id/questions/:questionId", handler.DeleteQuestion)
+	router.POST("/tests/:id/questions/:questionId/regenerate", handler.RegenerateQuestion)
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/bjorndonald/test-maker-service/internal/handlers"
 	"github.com/bjorndonald/test-maker-service/internal/helpers"
+	"github.com/bjorndonald/test-maker-service/internal/models"
 	"github.com/bjorndonald/test-maker-service/validator"
 	"github.com/gin-gonic/gin"
 )
@@ -30,6 +31,45 @@ func ValidateQuestionSchema(c *gin.Context) {
 	c.Next()
 }
 
+func ValidateQuestionEditSchema(c *gin.Context) {
+	var body models.Question
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
+
+func ValidateReorderSchema(c *gin.Context) {
+	var body handlers.ReorderInput
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
+
+func ValidateVariantsSchema(c *gin.Context) {
+	var body handlers.VariantsInput
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
+
+func ValidatePaperQuery(c *gin.Context) {
+	var query handlers.PaperInput
+	if err := c.ShouldBindQuery(&query); err != nil {
+		helpers.ReturnError(c, "Error validating input", err, http.StatusBadRequest)
+		c.Abort()
+		return
+	}
+
+	if err := validator.Validate(query); err != nil {
+		helpers.ReturnError(c, "Error validating input", err, http.StatusBadRequest)
+		c.Abort()
+		return
+	}
+
+	c.Set("validatedRequestBody", query)
+	c.Next()
+}
+
 func bindAndValidate(c *gin.Context, body interface{}) {
 	if err := c.ShouldBindJSON(body); err != nil {
 		helpers.ReturnError(c, "Error validating input", err, http.StatusBadRequest)
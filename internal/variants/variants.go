@@ -0,0 +1,193 @@
+// Package variants shuffles saved tests into variants, so that students
+// sitting next to each other get their questions, and the options of their
+// multiple choice questions, in a different order.
+package variants
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+)
+
+// MaxVariants is how many variants one seed can produce, one per letter.
+const MaxVariants = 26
+
+// MaxSeed bounds generated seeds so variant codes stay short enough to print.
+const MaxSeed = 36 * 36 * 36 * 36 * 36
+
+// fingerprintSize is the number of base36 digits of a test fingerprint.
+const fingerprintSize = 4
+
+// ErrInvalidCode is returned by ParseCode for codes Code did not produce.
+var ErrInvalidCode = errors.New("invalid variant code")
+
+// ErrTestChanged is returned when a variant code was made for an earlier
+// version of its test, so rebuilding it would not give the printed paper.
+var ErrTestChanged = errors.New("test has changed since the variant was created")
+
+// Code names the variant at index of a seed of a test with the given
+// fingerprint, such as B-1Z4K-09QF. The seed is part of the code so a variant
+// can be rebuilt from its code alone, and the fingerprint tells whether the
+// test still holds the questions it was built from.
+func Code(index int, seed int64, fingerprint string) string {
+	return string(rune('A'+index)) + "-" + strings.ToUpper(strconv.FormatInt(seed, 36)) + "-" + fingerprint
+}
+
+// ParseCode returns the index, seed and test fingerprint of a variant code.
+func ParseCode(code string) (int, int64, string, error) {
+	parts := strings.Split(strings.ToUpper(code), "-")
+	if len(parts) != 3 {
+		return 0, 0, "", ErrInvalidCode
+	}
+
+	letter, encoded, fingerprint := parts[0], parts[1], parts[2]
+	if len(letter) != 1 || letter[0] < 'A' || letter[0] >= 'A'+MaxVariants {
+		return 0, 0, "", ErrInvalidCode
+	}
+
+	seed, err := strconv.ParseInt(encoded, 36, 64)
+	if err != nil || seed < 0 {
+		return 0, 0, "", ErrInvalidCode
+	}
+
+	if len(fingerprint) != fingerprintSize {
+		return 0, 0, "", ErrInvalidCode
+	}
+	if _, err := strconv.ParseUint(fingerprint, 36, 64); err != nil {
+		return 0, 0, "", ErrInvalidCode
+	}
+	return int(letter[0] - 'A'), seed, fingerprint, nil
+}
+
+// Fingerprint hashes the ids and content of the questions of test in order.
+// Any edit, addition, removal or reordering changes it.
+func Fingerprint(test models.Test) string {
+	h := fnv.New64a()
+	for _, q := range test.Questions {
+		content, _ := json.Marshal(q)
+		h.Write(content)
+		h.Write([]byte{0})
+	}
+
+	var limit uint64 = 1
+	for i := 0; i < fingerprintSize; i++ {
+		limit *= 36
+	}
+	encoded := strings.ToUpper(strconv.FormatUint(h.Sum64()%limit, 36))
+	return strings.Repeat("0", fingerprintSize-len(encoded)) + encoded
+}
+
+// Rebuild returns the variant named by code and its answer key, or
+// ErrTestChanged when test no longer matches the fingerprint in the code.
+func Rebuild(test models.Test, code string) (models.Variant, models.AnswerKey, error) {
+	index, seed, fingerprint, err := ParseCode(code)
+	if err != nil {
+		return models.Variant{}, models.AnswerKey{}, err
+	}
+	if fingerprint != Fingerprint(test) {
+		return models.Variant{}, models.AnswerKey{}, ErrTestChanged
+	}
+	variant, key := Build(test, index, seed)
+	return variant, key, nil
+}
+
+// Build returns the variant at index of a seed with its answer key. Each
+// variant has its own random source, so it can be rebuilt without the ones
+// before it. The variant holds only what students see; the answers are in the
+// key alone.
+func Build(test models.Test, index int, seed int64) (models.Variant, models.AnswerKey) {
+	h := fnv.New64a()
+	h.Write([]byte(strconv.FormatInt(seed, 10) + ":" + strconv.Itoa(index)))
+	r := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	questions := make([]models.Question, len(test.Questions))
+	copy(questions, test.Questions)
+	r.Shuffle(len(questions), func(i, j int) {
+		questions[i], questions[j] = questions[j], questions[i]
+	})
+
+	code := Code(index, seed, Fingerprint(test))
+	variant := models.Variant{
+		TestId:    test.Id,
+		Code:      code,
+		Seed:      seed,
+		Title:     test.Title,
+		Questions: make([]models.VariantQuestion, 0, len(questions)),
+	}
+	key := models.AnswerKey{
+		TestId:  test.Id,
+		Code:    code,
+		Answers: make([]models.KeyAnswer, 0, len(questions)),
+	}
+
+	for i, q := range questions {
+		shown := models.VariantQuestion{Number: i + 1, Type: q.Type, Question: q.Question}
+		answer := models.KeyAnswer{Number: i + 1, QuestionId: q.Id, Answer: q.Answer}
+
+		switch q.Type {
+		case models.MultipleChoice:
+			order := r.Perm(len(q.Options))
+			shown.Options = pick(q.Options, order)
+			if q.CorrectIndex != nil {
+				answer.Choice = label(position(order, *q.CorrectIndex))
+			}
+		case models.Matching:
+			prompts := make([]string, len(q.Pairs))
+			matches := make([]string, len(q.Pairs))
+			for j, pair := range q.Pairs {
+				prompts[j], matches[j] = pair.Left, pair.Right
+			}
+			order := r.Perm(len(matches))
+			shown.Prompts, shown.Matches = prompts, pick(matches, order)
+
+			choices := make([]string, len(prompts))
+			for j := range prompts {
+				choices[j] = strconv.Itoa(j+1) + "-" + label(position(order, j))
+			}
+			answer.Choice = strings.Join(choices, ", ")
+		case models.Ordering:
+			order := r.Perm(len(q.Sequence))
+			shown.Items = pick(q.Sequence, order)
+
+			choices := make([]string, len(q.Sequence))
+			for j := range q.Sequence {
+				choices[j] = label(position(order, j))
+			}
+			answer.Choice = strings.Join(choices, ", ")
+		}
+
+		variant.Questions = append(variant.Questions, shown)
+		key.Answers = append(key.Answers, answer)
+	}
+
+	return variant, key
+}
+
+// pick returns the items in the order of the indices in order.
+func pick(items []string, order []int) []string {
+	picked := make([]string, len(order))
+	for i, j := range order {
+		picked[i] = items[j]
+	}
+	return picked
+}
+
+// position returns where index i ended up in order.
+func position(order []int, i int) int {
+	for p, j := range order {
+		if j == i {
+			return p
+		}
+	}
+	return -1
+}
+
+// label letters the option, match or item at position i.
+func label(i int) string {
+	return string(rune('A' + i))
+}
@@ -0,0 +1,194 @@
+package variants
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bjorndonald/test-maker-service/internal/models"
+	"github.com/google/uuid"
+)
+
+func fixture() models.Test {
+	test := models.Test{Id: uuid.New(), Title: "Biology"}
+	for i := 0; i < 10; i++ {
+		correct := i % 4
+		q := models.Question{
+			Id:           uuid.New(),
+			Position:     i + 1,
+			Type:         models.MultipleChoice,
+			Question:     fmt.Sprintf("Question %d", i+1),
+			Options:      []string{"first", "second", "third", "fourth"},
+			CorrectIndex: &correct,
+		}
+		q.Normalize()
+		test.Questions = append(test.Questions, q)
+	}
+	extra := []models.Question{
+		{Type: models.ShortAnswer, Question: "What is a cell?", Answer: "A unit of life"},
+		{Type: models.Matching, Question: "Match each organelle to its function.", Pairs: []models.MatchPair{
+			{Left: "Ribosome", Right: "Makes proteins"},
+			{Left: "Lysosome", Right: "Digests waste"},
+			{Left: "Nucleus", Right: "Stores DNA"},
+			{Left: "Mitochondrion", Right: "Releases energy"},
+		}},
+		{Type: models.Ordering, Question: "Order the phases of mitosis.", Sequence: []string{"Prophase", "Metaphase", "Anaphase", "Telophase"}},
+	}
+	for _, q := range extra {
+		q.Id = uuid.New()
+		q.Position = len(test.Questions) + 1
+		q.Normalize()
+		test.Questions = append(test.Questions, q)
+	}
+	return test
+}
+
+func TestBuildIsReproducible(t *testing.T) {
+	test := fixture()
+	variant, key := Build(test, 2, 42)
+	again, againKey := Build(test, 2, 42)
+	if !reflect.DeepEqual(variant, again) || !reflect.DeepEqual(key, againKey) {
+		t.Error("the same seed and index built different variants")
+	}
+
+	first, _ := Build(test, 0, 42)
+	second, _ := Build(test, 1, 42)
+	if reflect.DeepEqual(first.Questions, second.Questions) {
+		t.Error("variants of one seed are identical")
+	}
+	other, _ := Build(test, 0, 43)
+	if reflect.DeepEqual(first.Questions, other.Questions) {
+		t.Error("variants of different seeds are identical")
+	}
+}
+
+// labelled returns the item of items labelled by letter.
+func labelled(t *testing.T, items []string, letter string) string {
+	t.Helper()
+	i := int(letter[0] - 'A')
+	if len(letter) != 1 || i < 0 || i >= len(items) {
+		t.Fatalf("letter %q does not label one of %v", letter, items)
+	}
+	return items[i]
+}
+
+func TestBuildKeepsAnswers(t *testing.T) {
+	test := fixture()
+	original := map[uuid.UUID]models.Question{}
+	for _, q := range test.Questions {
+		original[q.Id] = q
+	}
+
+	variant, key := Build(test, 3, 7)
+	if len(variant.Questions) != len(test.Questions) || len(key.Answers) != len(test.Questions) {
+		t.Fatalf("expected %d questions, got %d and %d answers", len(test.Questions), len(variant.Questions), len(key.Answers))
+	}
+
+	for i, shown := range variant.Questions {
+		answer := key.Answers[i]
+		q := original[answer.QuestionId]
+		if shown.Number != i+1 || answer.Number != i+1 {
+			t.Errorf("question %d is numbered %d with answer %d", i, shown.Number, answer.Number)
+		}
+		if shown.Question != q.Question || answer.Answer != q.Answer {
+			t.Errorf("key entry %+v does not belong to question %+v", answer, shown)
+		}
+
+		switch q.Type {
+		case models.MultipleChoice:
+			if labelled(t, shown.Options, answer.Choice) != q.Options[*q.CorrectIndex] {
+				t.Errorf("key gives choice %s of %v for answer %q", answer.Choice, shown.Options, q.Answer)
+			}
+		case models.Matching:
+			for j, choice := range strings.Split(answer.Choice, ", ") {
+				number, letter, _ := strings.Cut(choice, "-")
+				if number != fmt.Sprint(j+1) || shown.Prompts[j] != q.Pairs[j].Left || labelled(t, shown.Matches, letter) != q.Pairs[j].Right {
+					t.Errorf("key gives %s for pair %+v of %v", choice, q.Pairs[j], shown.Matches)
+				}
+			}
+		case models.Ordering:
+			for j, letter := range strings.Split(answer.Choice, ", ") {
+				if labelled(t, shown.Items, letter) != q.Sequence[j] {
+					t.Errorf("key puts %s of %v at step %d, want %q", letter, shown.Items, j+1, q.Sequence[j])
+				}
+			}
+		}
+	}
+
+	if test.Questions[0].Options[0] != "first" || test.Questions[0].Position != 1 {
+		t.Error("building a variant changed the test")
+	}
+}
+
+func TestVariantLeavesOutAnswers(t *testing.T) {
+	test := fixture()
+	variant, _ := Build(test, 0, 11)
+	content, err := json.Marshal(variant)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{"answer", "correct_index", "is_true", "blanks", "feedback", "sequence", "pairs", "sources"} {
+		if strings.Contains(string(content), `"`+field+`"`) {
+			t.Errorf("variant holds the %s field: %s", field, content)
+		}
+	}
+	for _, shown := range variant.Questions {
+		if shown.Type == models.Ordering && reflect.DeepEqual(shown.Items, test.Questions[12].Sequence) {
+			t.Errorf("ordering items are printed in their answer order: %v", shown.Items)
+		}
+	}
+}
+
+func TestCodes(t *testing.T) {
+	for _, tc := range []struct {
+		index int
+		seed  int64
+		code  string
+	}{
+		{0, 0, "A-0-0000"},
+		{1, 35, "B-Z-0000"},
+		{25, MaxSeed - 1, "Z-ZZZZZ-0000"},
+	} {
+		if code := Code(tc.index, tc.seed, "0000"); code != tc.code {
+			t.Errorf("Code(%d, %d) = %s, want %s", tc.index, tc.seed, code, tc.code)
+		}
+		index, seed, fingerprint, err := ParseCode(tc.code)
+		if err != nil || index != tc.index || seed != tc.seed || fingerprint != "0000" {
+			t.Errorf("ParseCode(%s) = %d, %d, %s, %v", tc.code, index, seed, fingerprint, err)
+		}
+	}
+
+	for _, code := range []string{"", "A", "AB-1-0000", "1-1-0000", "A--0000", "A-!-0000", "A-1", "A-1-000", "A-1-00!0"} {
+		if _, _, _, err := ParseCode(code); err != ErrInvalidCode {
+			t.Errorf("ParseCode(%q) = %v, want ErrInvalidCode", code, err)
+		}
+	}
+}
+
+func TestRebuildRefusesEditedTests(t *testing.T) {
+	test := fixture()
+	built, builtKey := Build(test, 1, 99)
+	code := built.Code
+
+	variant, key, err := Rebuild(test, code)
+	if err != nil || !reflect.DeepEqual(variant, built) || !reflect.DeepEqual(key, builtKey) {
+		t.Fatalf("expected the variant to be rebuilt from its code, got %v", err)
+	}
+
+	edited := fixture()
+	edited.Questions = append([]models.Question{}, test.Questions...)
+	edited.Questions[3].Question = "Reworded question"
+	if _, _, err := Rebuild(edited, code); err != ErrTestChanged {
+		t.Errorf("expected ErrTestChanged after an edit, got %v", err)
+	}
+
+	reordered := fixture()
+	reordered.Questions = append([]models.Question{}, test.Questions...)
+	reordered.Questions[0], reordered.Questions[1] = reordered.Questions[1], reordered.Questions[0]
+	if _, _, err := Rebuild(reordered, code); err != ErrTestChanged {
+		t.Errorf("expected ErrTestChanged after a reorder, got %v", err)
+	}
+}
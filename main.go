@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -15,10 +16,15 @@ import (
 	"github.com/bjorndonald/test-maker-service/database"
 	"github.com/bjorndonald/test-maker-service/docs"
 	"github.com/bjorndonald/test-maker-service/internal/bootstrap"
+	"github.com/bjorndonald/test-maker-service/internal/chunker"
 	"github.com/bjorndonald/test-maker-service/internal/helpers"
+	"github.com/bjorndonald/test-maker-service/internal/llm"
+	"github.com/bjorndonald/test-maker-service/internal/repository"
+	"github.com/bjorndonald/test-maker-service/internal/retrieval"
 	"github.com/bjorndonald/test-maker-service/internal/routes"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // @title Test Maker Service
@@ -38,7 +44,10 @@ func main() {
 	constant := constants.New()
 
 	flag.Parse()
-	ctx := context.Background()
+
+	// ctx is cancelled on SIGINT or SIGTERM, which stops the job workers.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	g.Static("/assets", "./static/public")
 	g.Static("/templates", "./templates")
@@ -77,20 +86,79 @@ func main() {
 	v1 := g.Group("/api/v1")
 
 	dbConfig := database.Config{
+		URL:      constant.DbURL,
 		Host:     constant.DbHost,
 		Port:     constant.DbPort,
 		Password: constant.DbPassword,
 		User:     constant.DbUser,
 		DBName:   constant.DbName,
-		SSLMode:  constant.SSLMode,
+
+		SSLMode:     constant.SSLMode,
+		SSLRootCert: constant.SSLRootCert,
+		SSLCert:     constant.SSLCert,
+		SSLKey:      constant.SSLKey,
+
+		MaxConns:          int32(constant.DbMaxConns),
+		MinConns:          int32(constant.DbMinConns),
+		MaxConnLifetime:   constant.DbMaxConnLifetime,
+		MaxConnIdleTime:   constant.DbMaxConnIdleTime,
+		HealthCheckPeriod: constant.DbHealthCheckPeriod,
+	}
+
+	var conn *pgxpool.Pool
+	var memory *repository.MemoryRepo
+	var repos bootstrap.Repositories
+	switch constant.Storage {
+	case "memory":
+		repo, err := repository.NewMemoryRepo(repository.Metric(constant.VectorMetric), constant.MemorySnapshot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		memory = repo
+		repos = bootstrap.MemoryRepositories(repo)
+	case "postgres":
+		pool, err := database.Connect(ctx, &dbConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		conn = pool
+		repos = bootstrap.PostgresRepositories(conn)
+	default:
+		log.Fatalf("unknown storage %q", constant.Storage)
 	}
 
-	db, err := database.ConnectSQL(&dbConfig)
+	embedder, completer, err := llm.New(constant)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	dependencies := bootstrap.InitializeDependencies(db.SQL)
+	tokenizer, err := chunker.NewTokenizer(constant)
+	if err != nil {
+		log.Fatalf("error loading tokenizer for %s: %s", constant.EmbeddingModel, err)
+	}
+
+	retrievalOptions := retrieval.Options{
+		Limit:         constant.RetrievalLimit,
+		K:             constant.RRFK,
+		VectorWeight:  constant.VectorWeight,
+		KeywordWeight: constant.KeywordWeight,
+		PerSubject:    constant.SubjectLimit,
+		Lambda:        constant.MMRLambda,
+		ContextTokens: constant.ContextTokens,
+	}
+
+	dependencies := bootstrap.InitializeDependencies(conn, repos, embedder, completer, tokenizer, retrievalOptions, constant.DuplicateThreshold, constant.UnsupportedAnswers == "flag", constant.JobWorkers)
+	if err := dependencies.Jobs.Start(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	v1.GET("/health", func(c *gin.Context) {
+		if err := dependencies.Health(c); err != nil {
+			helpers.ReturnError(c, "Database is unavailable", err, http.StatusServiceUnavailable)
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
 
 	routes.Routes(v1, dependencies)
 	g.NoRoute(func(c *gin.Context) {
@@ -102,10 +170,12 @@ func main() {
 		port = constant.Port
 	}
 
-	go log.Fatal(g.Run(":" + port))
-
-	sigterm := make(chan os.Signal, 1)
-	signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
+	server := &http.Server{Addr: ":" + port, Handler: g}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
 
 	sigusr1 := make(chan os.Signal, 1)
 	signal.Notify(sigusr1, syscall.SIGUSR1)
@@ -115,13 +185,28 @@ func main() {
 	for keepRunning {
 		select {
 		case <-ctx.Done():
-			log.Println("terminating: context cancelled")
-			keepRunning = false
-		case <-sigterm:
 			log.Println("terminating: via signal")
 			keepRunning = false
 		case <-sigusr1:
 			// consumerClient.ToggleConsumptionFlow()
 		}
 	}
+
+	// Finish the requests in flight, let the workers stop at their next page
+	// and only then release the storage they write to.
+	shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdown); err != nil {
+		log.Printf("error shutting down server: %s", err)
+	}
+	dependencies.Jobs.Wait()
+
+	if memory != nil {
+		if err := memory.Close(); err != nil {
+			log.Printf("error writing memory snapshot: %s", err)
+		}
+	}
+	if conn != nil {
+		conn.Close()
+	}
 }